@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/usertoken"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+// passwordReset asks for a reset email when only Email is set, and sets the
+// new password when Token is set.
+type passwordReset struct {
+	Email           string `json:"email" validate:"required_without=Token,omitempty,email"`
+	Token           string `json:"token"`
+	Password        string `json:"password" validate:"required_with=Token"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// emailVerification asks for a verification email when only Email is set,
+// and verifies the email when Token is set.
+type emailVerification struct {
+	Email string `json:"email" validate:"required_without=Token,omitempty,email"`
+	Token string `json:"token"`
+}
+
+func (uh usersHandler) passwordReset(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.passwordReset")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var pr passwordReset
+	if err := web.Decode(r, &pr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	if pr.Token == "" {
+		if err := uh.sendToken(ctx, v.TraceID, pr.Email, usertoken.PurposePasswordReset, v.Now); err != nil {
+			return err
+		}
+
+		// The response is the same whether the email is registered or not.
+		return web.Respond(ctx, w, nil, http.StatusAccepted)
+	}
+
+	userID, err := uh.tokens.Consume(ctx, v.TraceID, pr.Token, usertoken.PurposePasswordReset, v.Now)
+	if err != nil {
+		switch err {
+		case usertoken.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "consuming reset token")
+		}
+	}
+
+	usr, err := uh.usecases.ResetPassword(ctx, v.TraceID, userID, pr.Password, v.Now)
+	if err != nil {
+		return errors.Wrapf(err, "resetting password of ID: %s", userID)
+	}
+
+	// Whoever knew the old password must not stay logged in, and the owner
+	// should not stay locked out.
+	if err := uh.sessions.RevokeUser(ctx, v.TraceID, usr.ID, v.Now); err != nil {
+		return errors.Wrapf(err, "revoking sessions of ID: %s", usr.ID)
+	}
+	if err := uh.lockout.Unlock(ctx, v.TraceID, usr.Email); err != nil {
+		return errors.Wrapf(err, "unlocking ID: %s", usr.ID)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh usersHandler) verifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.verifyEmail")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ev emailVerification
+	if err := web.Decode(r, &ev); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	if ev.Token == "" {
+		if err := uh.sendToken(ctx, v.TraceID, ev.Email, usertoken.PurposeEmailVerification, v.Now); err != nil {
+			return err
+		}
+
+		// The response is the same whether the email is registered or not.
+		return web.Respond(ctx, w, nil, http.StatusAccepted)
+	}
+
+	userID, err := uh.tokens.Consume(ctx, v.TraceID, ev.Token, usertoken.PurposeEmailVerification, v.Now)
+	if err != nil {
+		switch err {
+		case usertoken.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "consuming verification token")
+		}
+	}
+
+	if err := uh.usecases.VerifyEmail(ctx, v.TraceID, userID, v.Now); err != nil {
+		return errors.Wrapf(err, "verifying email of ID: %s", userID)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// sendToken mails a token for the purpose to the user registered with the
+// email. Nothing is sent for unknown emails, nor verification tokens for
+// emails that are already verified.
+func (uh usersHandler) sendToken(ctx context.Context, traceID string, email string, purpose string, now time.Time) error {
+	usr, err := uh.usecases.FindByEmail(ctx, traceID, email)
+	if err != nil {
+		switch err {
+		case user.ErrNotFound:
+			return nil
+		default:
+			return errors.Wrapf(err, "Email: %s", email)
+		}
+	}
+
+	if purpose == usertoken.PurposeEmailVerification && usr.DateVerified != nil {
+		return nil
+	}
+
+	return uh.mailToken(ctx, traceID, usr, purpose, now)
+}
+
+// mailToken issues a token for the purpose and mails it to the user.
+func (uh usersHandler) mailToken(ctx context.Context, traceID string, usr user.User, purpose string, now time.Time) error {
+	ttl := usertoken.EmailVerificationTTL
+	if purpose == usertoken.PurposePasswordReset {
+		ttl = usertoken.PasswordResetTTL
+	}
+
+	tkn, err := uh.tokens.Create(ctx, traceID, usr.ID, purpose, ttl, now)
+	if err != nil {
+		return errors.Wrapf(err, "issuing %s token for ID: %s", purpose, usr.ID)
+	}
+
+	if err := uh.mailer.Send(ctx, usertoken.NewMessage(purpose, usr.Name, usr.Email, tkn)); err != nil {
+		return errors.Wrapf(err, "mailing %s token to ID: %s", purpose, usr.ID)
+	}
+
+	return nil
+}
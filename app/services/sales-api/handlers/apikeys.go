@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/apikey"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+type apikeysHandler struct {
+	usecases apikey.APIKeyService
+}
+
+// issuedKey is returned once, when a key is created. The raw key can not be
+// retrieved afterwards.
+type issuedKey struct {
+	APIKey apikey.APIKey `json:"api_key"`
+	Key    string        `json:"key"`
+}
+
+func (ah apikeysHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.apikeysHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nk apikey.NewAPIKey
+	if err := web.Decode(r, &nk); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	ak, key, err := ah.usecases.Create(ctx, v.TraceID, claims, nk, v.Now)
+	if err != nil {
+		switch err {
+		case apikey.ErrInvalidScope, apikey.ErrInvalidExpiry:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case apikey.ErrUserNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case apikey.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "APIKey: %+v", &nk)
+		}
+	}
+
+	return web.Respond(ctx, w, issuedKey{APIKey: ak, Key: key}, http.StatusCreated)
+}
+
+func (ah apikeysHandler) list(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.apikeysHandler.list")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var qry struct {
+		UserID string `json:"user_id" validate:"omitempty,uuid"`
+	}
+	if err := web.DecodeQuery(r, &qry); err != nil {
+		return errors.Wrap(err, "unable to decode query")
+	}
+
+	keys, err := ah.usecases.List(ctx, v.TraceID, claims, qry.UserID)
+	if err != nil {
+		switch err {
+		case apikey.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case apikey.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "unable to query for api keys")
+		}
+	}
+
+	return web.Respond(ctx, w, keys, http.StatusOK)
+}
+
+func (ah apikeysHandler) revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.apikeysHandler.revoke")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := web.Param(r, "id")
+	if err := ah.usecases.Revoke(ctx, v.TraceID, claims, id, v.Now); err != nil {
+		switch err {
+		case apikey.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case apikey.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case apikey.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", id)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+type auditHandler struct {
+	usecases audit.AuditService
+	cursors  *cursor.Signer
+}
+
+// eventPage is the envelope for a page of audit events. NextCursor is an
+// opaque token to be sent back as the cursor query parameter for the next
+// page.
+type eventPage struct {
+	Items      []audit.Event `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	HasMore    bool          `json:"has_more"`
+}
+
+// Rows returns the audit events of the page, so it can be sent as CSV.
+func (p eventPage) Rows() interface{} {
+	return p.Items
+}
+
+func (ah auditHandler) list(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.auditHandler.list")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var qp struct {
+		audit.QueryFilter
+		Cursor string `json:"cursor"`
+		Rows   int    `json:"rows" validate:"omitempty,min=1"`
+	}
+	if err := web.DecodeQuery(r, &qp); err != nil {
+		return errors.Wrap(err, "unable to decode query")
+	}
+
+	var after *audit.Cursor
+	if qp.Cursor != "" {
+		after = &audit.Cursor{}
+		if err := ah.cursors.Decode(qp.Cursor, after); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+	}
+
+	page, err := ah.usecases.Query(ctx, v.TraceID, qp.QueryFilter, after, qp.Rows)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for audit events")
+	}
+
+	resp := eventPage{
+		Items:   page.Events,
+		HasMore: page.HasMore,
+	}
+	if resp.Items == nil {
+		resp.Items = []audit.Event{}
+	}
+	if page.Next != nil {
+		resp.NextCursor, err = ah.cursors.Encode(page.Next)
+		if err != nil {
+			return errors.Wrap(err, "encoding next cursor")
+		}
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
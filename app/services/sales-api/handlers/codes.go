@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/apikey"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/business/core/product"
+	"github.com/danielmbirochi/go-sample-service/business/core/sale"
+	"github.com/danielmbirochi/go-sample-service/business/core/session"
+	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/usertoken"
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+)
+
+// These codes are part of the API contract: clients branch on them, so they
+// must never change once published. Errors without a code here are reported
+// with a code derived from their status.
+func init() {
+	for err, code := range map[error]string{
+		user.ErrNotFound:              "user_not_found",
+		user.ErrInvalidID:             "user_invalid_id",
+		user.ErrAuthenticationFailure: "authentication_failed",
+		user.ErrUniqueEmail:           "email_taken",
+		user.ErrInvalidCursor:         "cursor_mismatch",
+		user.ErrInvalidOrderBy:        "invalid_order_by",
+		user.ErrForbidden:             "user_forbidden",
+		user.ErrUnverifiedEmail:       "email_unverified",
+		user.ErrWrongPassword:         "wrong_password",
+		user.ErrVersionConflict:       "version_conflict",
+
+		product.ErrNotFound:  "product_not_found",
+		product.ErrInvalidID: "product_invalid_id",
+		product.ErrForbidden: "product_forbidden",
+
+		sale.ErrNotFound:          "sale_not_found",
+		sale.ErrInvalidID:         "sale_invalid_id",
+		sale.ErrInsufficientStock: "insufficient_stock",
+
+		apikey.ErrNotFound:      "apikey_not_found",
+		apikey.ErrInvalidID:     "apikey_invalid_id",
+		apikey.ErrUserNotFound:  "apikey_user_not_found",
+		apikey.ErrInvalidScope:  "apikey_invalid_scope",
+		apikey.ErrInvalidExpiry: "apikey_invalid_expiry",
+		apikey.ErrForbidden:     "apikey_forbidden",
+
+		session.ErrInvalidToken:   "refresh_token_invalid",
+		session.ErrTokenReused:    "refresh_token_reused",
+		usertoken.ErrInvalidToken: "user_token_invalid",
+		lockout.ErrLocked:         "locked_out",
+		auth.ErrInvalidAPIKey:     "apikey_invalid",
+		cursor.ErrInvalid:         "cursor_invalid",
+		events.ErrClosed:          "events_unavailable",
+
+		web.ErrPreconditionFailed:   "precondition_failed",
+		web.ErrUnsupportedPatch:     "unsupported_patch",
+		web.ErrUnsupportedRows:      "unsupported_rows",
+		web.ErrNotAcceptable:        "not_acceptable",
+		web.ErrUnsupportedMediaType: "unsupported_media_type",
+	} {
+		web.RegisterCode(err, code)
+	}
+
+	for code, messages := range messages {
+		for locale, msg := range messages {
+			web.RegisterMessage(code, locale, msg)
+		}
+	}
+}
+
+// messages describe the problems of each code in the locales of the web
+// package. They are shown to end users in place of the error messages, which
+// are meant for the logs.
+var messages = map[string]map[string]string{
+	"internal_server_error": {
+		web.LocaleEN:   "something went wrong, please report the trace ID",
+		web.LocalePTBR: "algo deu errado, informe o ID de rastreamento",
+		web.LocaleES:   "algo salió mal, informe el ID de rastreo",
+		web.LocaleFR:   "une erreur est survenue, veuillez signaler l'identifiant de trace",
+		web.LocaleDE:   "etwas ist schiefgelaufen, bitte melden Sie die Trace-ID",
+	},
+	"user_not_found": {
+		web.LocaleEN:   "user not found",
+		web.LocalePTBR: "usuário não encontrado",
+		web.LocaleES:   "usuario no encontrado",
+		web.LocaleFR:   "utilisateur introuvable",
+		web.LocaleDE:   "Benutzer nicht gefunden",
+	},
+	"user_invalid_id": {
+		web.LocaleEN:   "user ID is not in its proper form",
+		web.LocalePTBR: "o ID do usuário não está no formato correto",
+		web.LocaleES:   "el ID del usuario no tiene el formato correcto",
+		web.LocaleFR:   "l'identifiant de l'utilisateur n'est pas au bon format",
+		web.LocaleDE:   "die Benutzer-ID hat nicht das richtige Format",
+	},
+	"authentication_failed": {
+		web.LocaleEN:   "authentication failed",
+		web.LocalePTBR: "falha na autenticação",
+		web.LocaleES:   "la autenticación falló",
+		web.LocaleFR:   "échec de l'authentification",
+		web.LocaleDE:   "Authentifizierung fehlgeschlagen",
+	},
+	"email_taken": {
+		web.LocaleEN:   "email is already in use",
+		web.LocalePTBR: "o e-mail já está em uso",
+		web.LocaleES:   "el correo electrónico ya está en uso",
+		web.LocaleFR:   "l'adresse e-mail est déjà utilisée",
+		web.LocaleDE:   "die E-Mail-Adresse wird bereits verwendet",
+	},
+	"cursor_mismatch": {
+		web.LocaleEN:   "cursor does not match the requested ordering",
+		web.LocalePTBR: "o cursor não corresponde à ordenação solicitada",
+		web.LocaleES:   "el cursor no coincide con el orden solicitado",
+		web.LocaleFR:   "le curseur ne correspond pas au tri demandé",
+		web.LocaleDE:   "der Cursor passt nicht zur angeforderten Sortierung",
+	},
+	"invalid_order_by": {
+		web.LocaleEN:   "invalid order by",
+		web.LocalePTBR: "ordenação inválida",
+		web.LocaleES:   "orden no válido",
+		web.LocaleFR:   "tri invalide",
+		web.LocaleDE:   "ungültige Sortierung",
+	},
+	"user_forbidden": {
+		web.LocaleEN:   "attempted action is not allowed",
+		web.LocalePTBR: "a ação não é permitida",
+		web.LocaleES:   "la acción no está permitida",
+		web.LocaleFR:   "l'action n'est pas autorisée",
+		web.LocaleDE:   "die Aktion ist nicht erlaubt",
+	},
+	"email_unverified": {
+		web.LocaleEN:   "email is not verified",
+		web.LocalePTBR: "o e-mail não foi verificado",
+		web.LocaleES:   "el correo electrónico no está verificado",
+		web.LocaleFR:   "l'adresse e-mail n'est pas vérifiée",
+		web.LocaleDE:   "die E-Mail-Adresse ist nicht bestätigt",
+	},
+	"wrong_password": {
+		web.LocaleEN:   "current password is missing or wrong",
+		web.LocalePTBR: "a senha atual está ausente ou incorreta",
+		web.LocaleES:   "la contraseña actual falta o es incorrecta",
+		web.LocaleFR:   "le mot de passe actuel est manquant ou incorrect",
+		web.LocaleDE:   "das aktuelle Passwort fehlt oder ist falsch",
+	},
+	"version_conflict": {
+		web.LocaleEN:   "user has been modified, fetch it again",
+		web.LocalePTBR: "o usuário foi modificado, busque-o novamente",
+		web.LocaleES:   "el usuario ha sido modificado, vuelva a obtenerlo",
+		web.LocaleFR:   "l'utilisateur a été modifié, récupérez-le à nouveau",
+		web.LocaleDE:   "der Benutzer wurde geändert, rufen Sie ihn erneut ab",
+	},
+	"product_not_found": {
+		web.LocaleEN:   "product not found",
+		web.LocalePTBR: "produto não encontrado",
+		web.LocaleES:   "producto no encontrado",
+		web.LocaleFR:   "produit introuvable",
+		web.LocaleDE:   "Produkt nicht gefunden",
+	},
+	"product_invalid_id": {
+		web.LocaleEN:   "product ID is not in its proper form",
+		web.LocalePTBR: "o ID do produto não está no formato correto",
+		web.LocaleES:   "el ID del producto no tiene el formato correcto",
+		web.LocaleFR:   "l'identifiant du produit n'est pas au bon format",
+		web.LocaleDE:   "die Produkt-ID hat nicht das richtige Format",
+	},
+	"product_forbidden": {
+		web.LocaleEN:   "attempted action is not allowed",
+		web.LocalePTBR: "a ação não é permitida",
+		web.LocaleES:   "la acción no está permitida",
+		web.LocaleFR:   "l'action n'est pas autorisée",
+		web.LocaleDE:   "die Aktion ist nicht erlaubt",
+	},
+	"sale_not_found": {
+		web.LocaleEN:   "sale not found",
+		web.LocalePTBR: "venda não encontrada",
+		web.LocaleES:   "venta no encontrada",
+		web.LocaleFR:   "vente introuvable",
+		web.LocaleDE:   "Verkauf nicht gefunden",
+	},
+	"sale_invalid_id": {
+		web.LocaleEN:   "sale ID is not in its proper form",
+		web.LocalePTBR: "o ID da venda não está no formato correto",
+		web.LocaleES:   "el ID de la venta no tiene el formato correcto",
+		web.LocaleFR:   "l'identifiant de la vente n'est pas au bon format",
+		web.LocaleDE:   "die Verkaufs-ID hat nicht das richtige Format",
+	},
+	"insufficient_stock": {
+		web.LocaleEN:   "insufficient stock",
+		web.LocalePTBR: "estoque insuficiente",
+		web.LocaleES:   "existencias insuficientes",
+		web.LocaleFR:   "stock insuffisant",
+		web.LocaleDE:   "unzureichender Lagerbestand",
+	},
+	"apikey_not_found": {
+		web.LocaleEN:   "API key not found",
+		web.LocalePTBR: "chave de API não encontrada",
+		web.LocaleES:   "clave de API no encontrada",
+		web.LocaleFR:   "clé d'API introuvable",
+		web.LocaleDE:   "API-Schlüssel nicht gefunden",
+	},
+	"apikey_invalid_id": {
+		web.LocaleEN:   "API key ID is not in its proper form",
+		web.LocalePTBR: "o ID da chave de API não está no formato correto",
+		web.LocaleES:   "el ID de la clave de API no tiene el formato correcto",
+		web.LocaleFR:   "l'identifiant de la clé d'API n'est pas au bon format",
+		web.LocaleDE:   "die ID des API-Schlüssels hat nicht das richtige Format",
+	},
+	"apikey_user_not_found": {
+		web.LocaleEN:   "user of the API key not found",
+		web.LocalePTBR: "usuário da chave de API não encontrado",
+		web.LocaleES:   "usuario de la clave de API no encontrado",
+		web.LocaleFR:   "utilisateur de la clé d'API introuvable",
+		web.LocaleDE:   "Benutzer des API-Schlüssels nicht gefunden",
+	},
+	"apikey_invalid_scope": {
+		web.LocaleEN:   "scope is not a valid action",
+		web.LocalePTBR: "o escopo não é uma ação válida",
+		web.LocaleES:   "el alcance no es una acción válida",
+		web.LocaleFR:   "la portée n'est pas une action valide",
+		web.LocaleDE:   "der Geltungsbereich ist keine gültige Aktion",
+	},
+	"apikey_invalid_expiry": {
+		web.LocaleEN:   "expiry date must be in the future",
+		web.LocalePTBR: "a data de expiração deve estar no futuro",
+		web.LocaleES:   "la fecha de caducidad debe estar en el futuro",
+		web.LocaleFR:   "la date d'expiration doit être dans le futur",
+		web.LocaleDE:   "das Ablaufdatum muss in der Zukunft liegen",
+	},
+	"apikey_forbidden": {
+		web.LocaleEN:   "attempted action is not allowed",
+		web.LocalePTBR: "a ação não é permitida",
+		web.LocaleES:   "la acción no está permitida",
+		web.LocaleFR:   "l'action n'est pas autorisée",
+		web.LocaleDE:   "die Aktion ist nicht erlaubt",
+	},
+	"refresh_token_invalid": {
+		web.LocaleEN:   "invalid refresh token",
+		web.LocalePTBR: "token de atualização inválido",
+		web.LocaleES:   "token de actualización no válido",
+		web.LocaleFR:   "jeton de rafraîchissement invalide",
+		web.LocaleDE:   "ungültiges Aktualisierungstoken",
+	},
+	"refresh_token_reused": {
+		web.LocaleEN:   "refresh token reused, sign in again",
+		web.LocalePTBR: "token de atualização reutilizado, entre novamente",
+		web.LocaleES:   "token de actualización reutilizado, inicie sesión de nuevo",
+		web.LocaleFR:   "jeton de rafraîchissement réutilisé, reconnectez-vous",
+		web.LocaleDE:   "Aktualisierungstoken wiederverwendet, melden Sie sich erneut an",
+	},
+	"user_token_invalid": {
+		web.LocaleEN:   "invalid or expired token",
+		web.LocalePTBR: "token inválido ou expirado",
+		web.LocaleES:   "token no válido o caducado",
+		web.LocaleFR:   "jeton invalide ou expiré",
+		web.LocaleDE:   "ungültiges oder abgelaufenes Token",
+	},
+	"locked_out": {
+		web.LocaleEN:   "too many failed attempts, try again later",
+		web.LocalePTBR: "muitas tentativas falharam, tente novamente mais tarde",
+		web.LocaleES:   "demasiados intentos fallidos, inténtelo más tarde",
+		web.LocaleFR:   "trop de tentatives échouées, réessayez plus tard",
+		web.LocaleDE:   "zu viele fehlgeschlagene Versuche, versuchen Sie es später erneut",
+	},
+	"apikey_invalid": {
+		web.LocaleEN:   "invalid API key",
+		web.LocalePTBR: "chave de API inválida",
+		web.LocaleES:   "clave de API no válida",
+		web.LocaleFR:   "clé d'API invalide",
+		web.LocaleDE:   "ungültiger API-Schlüssel",
+	},
+	"cursor_invalid": {
+		web.LocaleEN:   "invalid cursor",
+		web.LocalePTBR: "cursor inválido",
+		web.LocaleES:   "cursor no válido",
+		web.LocaleFR:   "curseur invalide",
+		web.LocaleDE:   "ungültiger Cursor",
+	},
+	"events_unavailable": {
+		web.LocaleEN:   "events are unavailable while the service shuts down",
+		web.LocalePTBR: "os eventos estão indisponíveis enquanto o serviço é encerrado",
+		web.LocaleES:   "los eventos no están disponibles mientras el servicio se detiene",
+		web.LocaleFR:   "les événements sont indisponibles pendant l'arrêt du service",
+		web.LocaleDE:   "Ereignisse sind nicht verfügbar, während der Dienst herunterfährt",
+	},
+	"precondition_failed": {
+		web.LocaleEN:   "the resource has changed since it was fetched",
+		web.LocalePTBR: "o recurso foi alterado desde que foi obtido",
+		web.LocaleES:   "el recurso ha cambiado desde que se obtuvo",
+		web.LocaleFR:   "la ressource a changé depuis sa récupération",
+		web.LocaleDE:   "die Ressource wurde seit dem Abruf geändert",
+	},
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// DefaultHeartbeat is how often an idle event stream gets a heartbeat when
+// none is configured.
+const DefaultHeartbeat = 15 * time.Second
+
+// EventReset is the type of the event telling subscribers that events were
+// lost since the last one they got, so they must reload the records they
+// follow.
+const EventReset = "reset"
+
+// eventAccess maps the records publishing events, named by the prefix of
+// the event types, to the action and the resource reading an event takes.
+// Events about other records are shown to nobody.
+var eventAccess = map[string]func(ev events.Event) (string, auth.Resource){
+	"user": func(ev events.Event) (string, auth.Resource) {
+		return auth.ActionUserRead, auth.Resource{OwnerID: ev.TargetID}
+	},
+}
+
+type eventsHandler struct {
+	broker    *events.Broker
+	policy    *auth.Policy
+	heartbeat time.Duration
+	log       *zap.SugaredLogger
+}
+
+// stream pushes the events the claims may read as Server-Sent Events, for
+// as long as the client stays connected. Clients sending the Last-Event-ID
+// header resume after that event, and get a reset event first when it is
+// no longer kept. Clients falling behind are disconnected, and resume the
+// same way when they reconnect.
+func (eh eventsHandler) stream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.eventsHandler.stream")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	sub, err := eh.broker.Subscribe(r.Header.Get("Last-Event-ID"), func(ev events.Event) bool {
+		access, ok := eventAccess[strings.SplitN(ev.Type, ".", 2)[0]]
+		if !ok {
+			return false
+		}
+		action, resource := access(ev)
+		return eh.policy.Allows(claims, action, resource)
+	})
+	if err != nil {
+		if err == events.ErrClosed {
+			return web.NewRequestError(err, http.StatusServiceUnavailable)
+		}
+		return errors.Wrap(err, "subscribing to events")
+	}
+	defer sub.Close()
+
+	stream, err := web.NewEventStream(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	if sub.Missed {
+		if err := stream.Send("", EventReset, nil); err != nil {
+			return err
+		}
+	}
+
+	heartbeat := eh.heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case ev, ok := <-sub.Events():
+			if !ok {
+				eh.log.Infow("events", "traceid", v.TraceID, "status", "subscription ended", "subject", claims.Subject, "reason", sub.Err())
+				return nil
+			}
+			err = stream.Send(ev.ID, ev.Type, ev.Data)
+
+		case <-ticker.C:
+			err = stream.Heartbeat()
+		}
+
+		// Writing fails once the client goes away, which is no failure.
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
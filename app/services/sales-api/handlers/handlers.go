@@ -6,37 +6,147 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/apikey"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/business/core/product"
+	"github.com/danielmbirochi/go-sample-service/business/core/sale"
+	"github.com/danielmbirochi/go-sample-service/business/core/session"
 	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/user/stores/userdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/usertoken"
 	middleware "github.com/danielmbirochi/go-sample-service/business/middlewares"
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+	"github.com/danielmbirochi/go-sample-service/foundation/mail"
 	"github.com/danielmbirochi/go-sample-service/foundation/web"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
+// APIConfig contains all the mandatory systems required by handlers.
+type APIConfig struct {
+	Build    string
+	Shutdown chan os.Signal
+	Log      *zap.SugaredLogger
+	Auth     *auth.Auth
+	DB       *sqlx.DB
+	Cursors  *cursor.Signer
+	Policy   *auth.Policy
+	Lockout  lockout.LockoutService
+	Mailer   mail.Mailer
+	Events   *events.Broker
+
+	// Heartbeat is how often idle event streams get a heartbeat, every
+	// DefaultHeartbeat when zero.
+	Heartbeat time.Duration
+
+	// RequireVerifiedEmail refuses tokens to users who have not verified
+	// their email yet.
+	RequireVerifiedEmail bool
+}
+
 // API construct an http.Handler with all application routes defined.
-func API(build string, shutdown chan os.Signal, log *zap.SugaredLogger, a *auth.Auth, db *sqlx.DB) *web.App {
-	app := web.NewApp(shutdown, middleware.Logger(log), middleware.Errors(log), middleware.Metrics(), middleware.Panics(log))
+func API(cfg APIConfig) *web.App {
+	log, a, db, p := cfg.Log, cfg.Auth, cfg.DB, cfg.Policy
+
+	app := web.NewApp(cfg.Shutdown, middleware.Logger(log), middleware.Errors(log), middleware.Metrics(), middleware.Panics(log))
 
 	// Register the healthcheck endpoint
 	c := check{
-		build: build,
+		build: cfg.Build,
 		db:    db,
 	}
 	app.Handle(http.MethodGet, "/v1/healthcheck", c.readiness)
 
+	// Register the endpoint publishing the token verification keys.
+	kh := keysHandler{
+		auth: a,
+	}
+	app.Handle(http.MethodGet, "/.well-known/jwks.json", kh.jwks)
+
 	// Register endpoints for accessing user service.
+	us := user.New(log, userdb.NewStore(log, db), p).PublishEvents(cfg.Events)
+	if cfg.RequireVerifiedEmail {
+		us = us.RequireVerifiedEmail()
+	}
 	uh := usersHandler{
-		usecases: user.New(log, db),
+		usecases: us,
+		sessions: session.New(log, db),
+		lockout:  cfg.Lockout,
+		tokens:   usertoken.New(log, db),
+		mailer:   cfg.Mailer,
 		auth:     a,
+		cursors:  cfg.Cursors,
+		policy:   p,
+		log:      log,
 	}
-	app.Handle(http.MethodGet, "/v1/users/:page/:rows", uh.list, middleware.Authenticate(a), middleware.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/v1/users", uh.list, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserList))
+	app.Handle(http.MethodGet, "/v1/users/token", uh.token)
 	app.Handle(http.MethodGet, "/v1/users/token/:kid", uh.token)
-	app.Handle(http.MethodGet, "/v1/users/:id", uh.queryByID, middleware.Authenticate(a))
-	app.Handle(http.MethodPost, "/v1/users", uh.create, middleware.Authenticate(a), middleware.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodPut, "/v1/users/:id", uh.update, middleware.Authenticate(a), middleware.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodDelete, "/v1/users/:id", uh.delete, middleware.Authenticate(a), middleware.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/v1/users/token/refresh", uh.refresh)
+	app.Handle(http.MethodPost, "/v1/users/logout", uh.logout, middleware.Authenticate(a))
+	app.Handle(http.MethodPost, "/v1/users/password-reset", uh.passwordReset)
+	app.Handle(http.MethodPost, "/v1/users/verify-email", uh.verifyEmail)
+	app.Handle(http.MethodGet, "/v1/users/me", uh.queryMe, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserRead))
+	app.Handle(http.MethodPut, "/v1/users/me", uh.updateMe, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserUpdate))
+	app.Handle(http.MethodGet, "/v1/users/:id", uh.queryByID, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserRead))
+	app.Handle(http.MethodPost, "/v1/users/import", uh.importUsers, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserCreate))
+	app.Handle(http.MethodGet, "/v1/users/export", uh.exportUsers, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserList))
+	app.Handle(http.MethodPost, "/v1/users", uh.create, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserCreate))
+	app.Handle(http.MethodPut, "/v1/users/:id", uh.update, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserUpdate))
+	app.Handle(http.MethodPatch, "/v1/users/:id", uh.patch, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserUpdate))
+	app.Handle(http.MethodDelete, "/v1/users/:id", uh.delete, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserDelete))
+	app.Handle(http.MethodPost, "/v1/users/:id/restore", uh.restore, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionUserRestore))
+	app.Handle(http.MethodPost, "/v1/users/:id/unlock", uh.unlock, middleware.Authenticate(a), middleware.Require(p, auth.ActionUserUnlock))
+
+	// Register endpoints for managing the API keys of machine clients. Keys
+	// can not be used to manage keys, so these routes require a bearer token.
+	ah := apikeysHandler{
+		usecases: apikey.New(log, db, p, auditdb.TranStorer(log)),
+	}
+	app.Handle(http.MethodPost, "/v1/apikeys", ah.create, middleware.Authenticate(a), middleware.Require(p, auth.ActionAPIKeyCreate))
+	app.Handle(http.MethodGet, "/v1/apikeys", ah.list, middleware.Authenticate(a), middleware.Require(p, auth.ActionAPIKeyList))
+	app.Handle(http.MethodDelete, "/v1/apikeys/:id", ah.revoke, middleware.Authenticate(a), middleware.Require(p, auth.ActionAPIKeyRevoke))
+
+	// Register endpoints for accessing product service.
+	ph := productsHandler{
+		usecases: product.New(log, db, p, auditdb.TranStorer(log)),
+	}
+	app.Handle(http.MethodGet, "/v1/products/:page/:rows", ph.list, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionProductList))
+	app.Handle(http.MethodGet, "/v1/products/:id", ph.queryByID, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionProductRead))
+	app.Handle(http.MethodPost, "/v1/products", ph.create, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionProductCreate))
+	app.Handle(http.MethodPut, "/v1/products/:id", ph.update, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionProductUpdate))
+	app.Handle(http.MethodDelete, "/v1/products/:id", ph.delete, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionProductDelete))
+
+	// Register endpoints for accessing sale service.
+	sh := salesHandler{
+		usecases: sale.New(log, db, auditdb.TranStorer(log)),
+	}
+	app.Handle(http.MethodPost, "/v1/products/:id/sales", sh.create, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionSaleCreate))
+	app.Handle(http.MethodGet, "/v1/products/:id/sales", sh.queryByProduct, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionSaleRead))
+
+	// Register the endpoint for reviewing the changes made to the system.
+	adh := auditHandler{
+		usecases: audit.New(log, auditdb.NewStore(log, db)),
+		cursors:  cfg.Cursors,
+	}
+	app.Handle(http.MethodGet, "/v1/audit", adh.list, middleware.AuthenticateAPIKey(a), middleware.Require(p, auth.ActionAuditList))
+
+	// Register the stream of the changes made to the system. Every
+	// authenticated subject can subscribe, and gets the events about the
+	// records it can read.
+	eh := eventsHandler{
+		broker:    cfg.Events,
+		policy:    p,
+		heartbeat: cfg.Heartbeat,
+		log:       log,
+	}
+	app.HandleStream(http.MethodGet, "/v1/events", eh.stream, middleware.AuthenticateAPIKey(a))
 
 	return app
 }
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+)
+
+type keysHandler struct {
+	auth *auth.Auth
+}
+
+// jwks publishes the public keys used to sign access tokens so other
+// services can verify them without sharing the private keys.
+func (h keysHandler) jwks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return web.Respond(ctx, w, h.auth.JWKS(), http.StatusOK)
+}
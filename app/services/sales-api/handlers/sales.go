@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/sale"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+)
+
+type salesHandler struct {
+	usecases sale.SaleService
+}
+
+func (sh salesHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.salesHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var ns sale.NewSale
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	id := web.Param(r, "id")
+	sl, err := sh.usecases.Create(ctx, v.TraceID, claims, id, ns, v.Now)
+	if err != nil {
+		switch err {
+		case sale.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case sale.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case sale.ErrInsufficientStock:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s  Sale: %+v", id, &ns)
+		}
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusCreated)
+}
+
+func (sh salesHandler) queryByProduct(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.salesHandler.queryByProduct")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	id := web.Param(r, "id")
+	sales, err := sh.usecases.QueryByProduct(ctx, v.TraceID, id)
+	if err != nil {
+		switch err {
+		case sale.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", id)
+		}
+	}
+
+	return web.Respond(ctx, w, sales, http.StatusOK)
+}
@@ -2,20 +2,60 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/business/core/session"
 	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/usertoken"
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
+	"github.com/danielmbirochi/go-sample-service/foundation/mail"
 	"github.com/danielmbirochi/go-sample-service/foundation/web"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
 )
 
 type usersHandler struct {
 	usecases user.UserService
+	sessions session.SessionService
+	lockout  lockout.LockoutService
+	tokens   usertoken.UserTokenService
+	mailer   mail.Mailer
 	auth     *auth.Auth
+	cursors  *cursor.Signer
+	policy   *auth.Policy
+	log      *zap.SugaredLogger
+}
+
+// tokenPair is the response for every token issuance. The refresh token is
+// single-use and must be exchanged for a new pair before it expires.
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshRequest carries the refresh token for the refresh and logout
+// endpoints.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// userPage is the envelope for a page of users. NextCursor is an opaque
+// token to be sent back as the cursor query parameter for the next page.
+type userPage struct {
+	Items      []user.User `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+}
+
+// Rows returns the users of the page, so it can be sent as CSV.
+func (p userPage) Rows() interface{} {
+	return p.Items
 }
 
 func (uh usersHandler) list(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -27,23 +67,59 @@ func (uh usersHandler) list(ctx context.Context, w http.ResponseWriter, r *http.
 		return web.NewShutdownError("web value missing from context")
 	}
 
-	page := web.Param(r, "page")
-	pageNumber, err := strconv.Atoi(page)
-	if err != nil {
-		return web.NewRequestError(fmt.Errorf("invalid page format: %s", page), http.StatusBadRequest)
+	var qp struct {
+		user.QueryFilter
+		OrderBy string `json:"order_by"`
+		Cursor  string `json:"cursor"`
+		Rows    int    `json:"rows" validate:"omitempty,min=1"`
+	}
+	if err := web.DecodeQuery(r, &qp); err != nil {
+		return errors.Wrap(err, "unable to decode query")
 	}
-	rows := web.Param(r, "rows")
-	rowsPerPage, err := strconv.Atoi(rows)
+
+	orderBy, err := user.ParseOrderBy(qp.OrderBy)
 	if err != nil {
-		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", rows), http.StatusBadRequest)
+		return &web.Error{
+			Err:    errors.New("field validation error"),
+			Status: http.StatusBadRequest,
+			Code:   web.CodeValidation,
+			Fields: []web.FieldError{{Field: "order_by", Error: err.Error()}},
+		}
 	}
 
-	users, err := uh.usecases.List(ctx, v.TraceID, pageNumber, rowsPerPage)
+	var after *user.Cursor
+	if qp.Cursor != "" {
+		after = &user.Cursor{}
+		if err := uh.cursors.Decode(qp.Cursor, after); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+	}
+
+	page, err := uh.usecases.List(ctx, v.TraceID, qp.QueryFilter, orderBy, after, qp.Rows)
 	if err != nil {
-		return errors.Wrap(err, "unable to query for users")
+		switch errors.Cause(err) {
+		case user.ErrInvalidCursor, user.ErrInvalidOrderBy:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "unable to query for users")
+		}
 	}
 
-	return web.Respond(ctx, w, users, http.StatusOK)
+	resp := userPage{
+		Items:   page.Users,
+		HasMore: page.HasMore,
+	}
+	if resp.Items == nil {
+		resp.Items = []user.User{}
+	}
+	if page.Next != nil {
+		resp.NextCursor, err = uh.cursors.Encode(page.Next)
+		if err != nil {
+			return errors.Wrap(err, "encoding next cursor")
+		}
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
 }
 
 func (uh usersHandler) queryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -75,6 +151,7 @@ func (uh usersHandler) queryByID(ctx context.Context, w http.ResponseWriter, r *
 		}
 	}
 
+	web.SetVersion(w, usr.Version)
 	return web.Respond(ctx, w, usr, http.StatusOK)
 }
 
@@ -87,23 +164,172 @@ func (uh usersHandler) create(ctx context.Context, w http.ResponseWriter, r *htt
 		return web.NewShutdownError("web value missing from context")
 	}
 
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
 	var nu user.NewUser
 	if err := web.Decode(r, &nu); err != nil {
 		return errors.Wrapf(err, "unable to decode payload")
 	}
 
-	usr, err := uh.usecases.Create(ctx, v.TraceID, nu, v.Now)
+	usr, err := uh.usecases.Create(ctx, v.TraceID, claims, nu, v.Now)
 	if err != nil {
-		return errors.Wrapf(err, "User: %+v", &usr)
+		switch err {
+		case user.ErrUniqueEmail:
+			return web.NewRequestError(err, http.StatusConflict)
+		case user.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "User: %+v", &usr)
+		}
+	}
+
+	// The user is created even if the verification email can not be sent,
+	// since a new one can be asked for.
+	if err := uh.mailToken(ctx, v.TraceID, usr, usertoken.PurposeEmailVerification, v.Now); err != nil {
+		uh.log.Errorw("mail", "traceid", v.TraceID, "status", "sending verification email", "ERROR", err)
 	}
 
 	return web.Respond(ctx, w, usr, http.StatusCreated)
 }
 
+// importUsers creates users in bulk from a CSV or NDJSON body. The report
+// lists every rejected row, in which case no user is created. Imported
+// users are not sent a verification email, but can ask for one.
+func (uh usersHandler) importUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.importUsers")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var qp struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if err := web.DecodeQuery(r, &qp); err != nil {
+		return errors.Wrap(err, "unable to decode query")
+	}
+
+	rows, err := web.NewRowDecoder(r.Body, r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	rows.Locale = web.Locale(r)
+
+	report, err := uh.usecases.Import(ctx, v.TraceID, claims, rows, qp.DryRun, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrUniqueEmail:
+			return web.NewRequestError(user.ErrUniqueEmail, http.StatusConflict)
+		default:
+			return errors.Wrap(err, "importing users")
+		}
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		return web.Respond(ctx, w, report, http.StatusUnprocessableEntity)
+	case report.DryRun:
+		return web.Respond(ctx, w, report, http.StatusOK)
+	default:
+		return web.Respond(ctx, w, report, http.StatusCreated)
+	}
+}
+
+// exportUsers streams the users matching the query filter as NDJSON, or as
+// a JSON array or CSV when the client prefers it.
+func (uh usersHandler) exportUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.exportUsers")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var filter user.QueryFilter
+	if err := web.DecodeQuery(r, &filter); err != nil {
+		return errors.Wrap(err, "unable to decode query")
+	}
+
+	it, err := uh.usecases.Stream(ctx, v.TraceID, filter)
+	if err != nil {
+		return errors.Wrap(err, "unable to export users")
+	}
+	defer it.Close()
+
+	users := web.IteratorFunc(func() (interface{}, error) {
+		return it.Next()
+	})
+	if err := web.RespondStream(ctx, w, users, http.StatusOK); err != nil {
+		return errors.Wrap(err, "exporting users")
+	}
+
+	return nil
+}
+
 func (uh usersHandler) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.update")
 	defer span.End()
 
+	return uh.updateUser(ctx, w, r, web.Param(r, "id"))
+}
+
+func (uh usersHandler) queryMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.queryMe")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	usr, err := uh.usecases.GetById(ctx, v.TraceID, claims, claims.Subject)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID, user.ErrNotFound:
+			return web.NewRequestError(user.ErrNotFound, http.StatusNotFound)
+		case user.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	web.SetVersion(w, usr.Version)
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+func (uh usersHandler) updateMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.updateMe")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	return uh.updateUser(ctx, w, r, claims.Subject)
+}
+
+// updateUser applies the update in the request body to the user with the
+// given ID, on behalf of the claims in the context. An If-Match header makes
+// the update conditional on the version the client read.
+func (uh usersHandler) updateUser(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) error {
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
 	if !ok {
 		return web.NewShutdownError("web value missing from context")
@@ -119,8 +345,33 @@ func (uh usersHandler) update(ctx context.Context, w http.ResponseWriter, r *htt
 		return errors.Wrapf(err, "unable to decode payload")
 	}
 
+	version, conditional, err := web.IfMatch(r)
+	if err != nil {
+		return err
+	}
+	if conditional {
+		upd.Version = &version
+	}
+
+	return uh.saveUser(ctx, w, v, claims, id, upd, conditional)
+}
+
+func (uh usersHandler) patch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.patch")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
 	id := web.Param(r, "id")
-	err := uh.usecases.Update(ctx, v.TraceID, claims, id, upd, v.Now)
+	usr, err := uh.usecases.GetById(ctx, v.TraceID, claims, id)
 	if err != nil {
 		switch err {
 		case user.ErrInvalidID:
@@ -129,6 +380,63 @@ func (uh usersHandler) update(ctx context.Context, w http.ResponseWriter, r *htt
 			return web.NewRequestError(err, http.StatusNotFound)
 		case user.ErrForbidden:
 			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", id)
+		}
+	}
+
+	version, conditional, err := web.IfMatch(r)
+	if err != nil {
+		return err
+	}
+	if conditional && version != usr.Version {
+		return web.NewRequestError(user.ErrVersionConflict, http.StatusPreconditionFailed)
+	}
+
+	doc := user.PatchUser{
+		Name:  usr.Name,
+		Email: usr.Email,
+		Roles: usr.Roles,
+	}
+	var pu user.PatchUser
+	if err := web.DecodePatch(r, doc, &pu); err != nil {
+		return errors.Wrapf(err, "unable to decode patch")
+	}
+
+	// The patch was applied to the version read above, so the update must
+	// not overwrite a newer one.
+	upd := user.UpdateUser{
+		Name:    &pu.Name,
+		Email:   &pu.Email,
+		Roles:   pu.Roles,
+		Version: &usr.Version,
+	}
+
+	return uh.saveUser(ctx, w, v, claims, id, upd, conditional)
+}
+
+// saveUser applies an update to the user with the given ID. The update is
+// conditional when the client asked for a specific version of the user.
+func (uh usersHandler) saveUser(ctx context.Context, w http.ResponseWriter, v *web.Values, claims auth.Claims, id string, upd user.UpdateUser, conditional bool) error {
+	err := uh.usecases.Update(ctx, v.TraceID, claims, id, upd, v.Now)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrForbidden, user.ErrWrongPassword:
+			return web.NewRequestError(err, http.StatusForbidden)
+		case user.ErrUniqueEmail:
+			return web.NewRequestError(err, http.StatusConflict)
+		case user.ErrVersionConflict:
+
+			// Without a precondition, the user changed between reading and
+			// writing it during this very request.
+			if !conditional {
+				return web.NewRequestError(err, http.StatusConflict)
+			}
+			return web.NewRequestError(err, http.StatusPreconditionFailed)
 		default:
 			return errors.Wrapf(err, "ID: %s  User: %+v", id, &upd)
 		}
@@ -146,8 +454,13 @@ func (uh usersHandler) delete(ctx context.Context, w http.ResponseWriter, r *htt
 		return web.NewShutdownError("web value missing from context")
 	}
 
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
 	id := web.Param(r, "id")
-	err := uh.usecases.Delete(ctx, v.TraceID, id)
+	err := uh.usecases.Delete(ctx, v.TraceID, claims, id, v.Now)
 	if err != nil {
 		switch err {
 		case user.ErrInvalidID:
@@ -164,6 +477,40 @@ func (uh usersHandler) delete(ctx context.Context, w http.ResponseWriter, r *htt
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
+func (uh usersHandler) restore(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.restore")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := web.Param(r, "id")
+	err := uh.usecases.Restore(ctx, v.TraceID, claims, id, v.Now)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrUniqueEmail:
+			return web.NewRequestError(err, http.StatusConflict)
+		case user.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", id)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
 func (uh usersHandler) token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
@@ -177,26 +524,193 @@ func (uh usersHandler) token(ctx context.Context, w http.ResponseWriter, r *http
 		return web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
+	// Locked emails and addresses are turned away before checking the
+	// password, so guesses made during a lock cost nothing and tell nothing.
+	addr := remoteAddr(r)
+	retryAfter, err := uh.lockout.Check(ctx, v.TraceID, v.Now, email, addr)
+	if err != nil {
+		switch err {
+		case lockout.ErrLocked:
+			return tooManyRequests(w, err, retryAfter)
+		default:
+			return errors.Wrap(err, "checking lockout")
+		}
+	}
+
 	claims, err := uh.usecases.Authenticate(ctx, v.TraceID, v.Now, email, pass)
 	if err != nil {
 		switch err {
+		case user.ErrUnverifiedEmail:
+			return web.NewRequestError(err, http.StatusForbidden)
 		case user.ErrAuthenticationFailure:
+			if err := uh.lockout.Fail(ctx, v.TraceID, v.Now, email, addr); err != nil {
+				return errors.Wrap(err, "recording failed attempt")
+			}
 			return web.NewRequestError(err, http.StatusUnauthorized)
 		default:
 			return errors.Wrap(err, "authenticating")
 		}
 	}
 
+	if err := uh.lockout.Succeed(ctx, v.TraceID, email); err != nil {
+		return errors.Wrap(err, "clearing failed attempts")
+	}
+
+	// Clients may still ask for a specific key. Without a kid in the path the
+	// token is signed with the active key.
 	kid := web.Param(r, "kid")
 
-	var tkn struct {
-		Token string `json:"token"`
-	}
+	var tkn tokenPair
 	tkn.Token, err = uh.auth.GenerateToken(kid, claims)
 	if err != nil {
 		return errors.Wrap(err, "generating token")
 	}
 
+	tkn.RefreshToken, err = uh.sessions.Create(ctx, v.TraceID, claims.Subject, v.Now)
+	if err != nil {
+		return errors.Wrap(err, "creating session")
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+func (uh usersHandler) unlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.unlock")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := web.Param(r, "id")
+	usr, err := uh.usecases.GetById(ctx, v.TraceID, claims, id)
+	if err != nil {
+		switch err {
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", id)
+		}
+	}
+
+	if !uh.policy.Can(claims, auth.ActionUserUnlock, auth.Resource{OwnerID: usr.ID}) {
+		return web.NewRequestError(user.ErrForbidden, http.StatusForbidden)
+	}
+
+	if err := uh.lockout.Unlock(ctx, v.TraceID, usr.Email); err != nil {
+		return errors.Wrapf(err, "unlocking ID: %s", id)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh usersHandler) refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.refresh")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rr refreshRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	// The access token is issued within the exchange, so a user who can no
+	// longer sign in, or a failure to sign the token, leaves the refresh
+	// token unused.
+	var tkn tokenPair
+	_, refreshToken, err := uh.sessions.Refresh(ctx, v.TraceID, rr.RefreshToken, v.Now, func(userID string) error {
+		claims, err := uh.usecases.Reauthenticate(ctx, v.TraceID, v.Now, userID)
+		if err != nil {
+			return err
+		}
+
+		tkn.Token, err = uh.auth.GenerateToken("", claims)
+		if err != nil {
+			return errors.Wrap(err, "generating token")
+		}
+		return nil
+	})
+	if err != nil {
+		switch err {
+		case session.ErrInvalidToken, session.ErrTokenReused, user.ErrAuthenticationFailure:
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return errors.Wrap(err, "refreshing session")
+		}
+	}
+	tkn.RefreshToken = refreshToken
+
 	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+func (uh usersHandler) logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "handlers.usersHandler.logout")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var rr refreshRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	if err := uh.sessions.Revoke(ctx, v.TraceID, claims.Subject, rr.RefreshToken, v.Now); err != nil {
+		switch err {
+		case session.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "revoking session")
+		}
+	}
+
+	// Also revoke the access token used for this request, so it can not be
+	// used until it expires.
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := uh.sessions.RevokeAccess(ctx, v.TraceID, claims.ID, claims.ExpiresAt.Time, v.Now); err != nil {
+			return errors.Wrap(err, "revoking access token")
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// remoteAddr returns the IP address of the client. Forwarding headers are
+// ignored since any client can set them.
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tooManyRequests fails a request with a 429 telling the client how many
+// seconds to wait before trying again.
+func tooManyRequests(w http.ResponseWriter, err error, retryAfter time.Duration) error {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
 
+	return web.NewRequestError(err, http.StatusTooManyRequests)
 }
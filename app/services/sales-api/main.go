@@ -2,23 +2,34 @@ package main
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto/rand"
 	"expvar"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ardanlabs/conf"
 	"github.com/danielmbirochi/go-sample-service/app/services/sales-api/handlers"
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/apikey"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout/stores/lockoutdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout/stores/lockoutmem"
+	"github.com/danielmbirochi/go-sample-service/business/core/session"
+	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
 	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+	"github.com/danielmbirochi/go-sample-service/foundation/keystore"
 	"github.com/danielmbirochi/go-sample-service/foundation/logger"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/danielmbirochi/go-sample-service/foundation/mail"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -69,11 +80,41 @@ func run(log *zap.SugaredLogger) error {
 			ReadTimeout     time.Duration `conf:"default:5s"`
 			WriteTimeout    time.Duration `conf:"default:5s"`
 			ShutdownTimeout time.Duration `conf:"default:5s"`
+			CursorSecret    string        `conf:"mask"`
+		}
+		Events struct {
+			BufferSize int           `conf:"default:1024"`
+			QueueSize  int           `conf:"default:64"`
+			Heartbeat  time.Duration `conf:"default:15s"`
 		}
 		Auth struct {
-			KeyID          string `conf:"default:32bc1165-24t2-61a7-af3e-9da4agf2h1p1"`
-			PrivateKeyFile string `conf:"default:/app/private.pem"`
-			Algorithm      string `conf:"default:RS256"`
+			KeysFolder       string        `conf:"default:/app/keys/"`
+			KeysPollInterval time.Duration `conf:"default:30s"`
+			ActiveKID        string
+			Algorithm        string `conf:"default:RS256"`
+			Secret           string `conf:"mask"`
+			PolicyFile       string
+
+			// RequireVerifiedEmail refuses tokens to users who have not
+			// verified their email yet.
+			RequireVerifiedEmail bool `conf:"default:false"`
+		}
+		Lockout struct {
+			Store          string        `conf:"default:postgres"`
+			EmailThreshold int           `conf:"default:5"`
+			AddrThreshold  int           `conf:"default:20"`
+			BaseDelay      time.Duration `conf:"default:1s"`
+			MaxDelay       time.Duration `conf:"default:15m"`
+			Window         time.Duration `conf:"default:24h"`
+		}
+		Mail struct {
+			Mailer       string `conf:"default:log"`
+			From         string `conf:"default:no-reply@example.com"`
+			Folder       string `conf:"default:/tmp/mail/"`
+			SMTPHost     string `conf:"default:localhost"`
+			SMTPPort     int    `conf:"default:587"`
+			SMTPUser     string
+			SMTPPassword string `conf:"mask"`
 		}
 		DB struct {
 			User       string `conf:"default:testuser"`
@@ -135,30 +176,85 @@ func run(log *zap.SugaredLogger) error {
 	// Initialize authentication support
 	log.Infow("startup", "status", "initializing authentication support")
 
-	privatePEM, err := ioutil.ReadFile(cfg.Auth.PrivateKeyFile)
-	if err != nil {
-		return errors.Wrap(err, "reading auth pem file")
-	}
+	var a *auth.Auth
+	switch {
+	case strings.HasPrefix(cfg.Auth.Algorithm, "HS"):
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-	if err != nil {
-		return errors.Wrap(err, "parsing auth pem file")
-	}
+		// The HMAC algorithms sign and verify with a shared secret, so there
+		// is no keys folder to load and nothing to publish.
+		if cfg.Auth.ActiveKID == "" || cfg.Auth.Secret == "" {
+			return errors.Errorf("an active kid and a secret are required by %s", cfg.Auth.Algorithm)
+		}
+
+		a, err = auth.NewHMAC(cfg.Auth.Algorithm, auth.Secrets{cfg.Auth.ActiveKID: []byte(cfg.Auth.Secret)})
+		if err != nil {
+			return errors.Wrap(err, "initializing auth service")
+		}
 
-	// In production the Key pair set (Auth.PrivateKeyFile) would be get from external services.
-	// For local development env we`re using the local file system for that.
-	lookupKey := func(kid string) (*rsa.PublicKey, error) {
-		switch kid {
-		case cfg.Auth.KeyID:
-			return &privateKey.PublicKey, nil
+	default:
+
+		// Every *.pem file in the keys folder is loaded as a private key, using the
+		// file name as its kid. Verifiers fetch the public keys from the JWKS endpoint.
+		keysFS := os.DirFS(cfg.Auth.KeysFolder)
+		ks, err := keystore.NewFS(keysFS)
+		if err != nil {
+			return errors.Wrap(err, "reading keys")
+		}
+
+		a, err = auth.New(cfg.Auth.Algorithm, nil, ks.Keys())
+		if err != nil {
+			return errors.Wrap(err, "initializing auth service")
+		}
+
+		// New tokens are signed with the configured kid. When none is configured
+		// the most recently written key file is used, so dropping a new file in
+		// the keys folder is enough to rotate the signing key.
+		activeKID := func(ks *keystore.KeyStore) string {
+			if _, err := ks.PrivateKey(cfg.Auth.ActiveKID); err == nil {
+				return cfg.Auth.ActiveKID
+			}
+			return ks.Newest()
+		}
+		if err := a.SetActiveKID(activeKID(ks)); err != nil {
+			return errors.Wrap(err, "selecting the signing key")
 		}
-		return nil, errors.Errorf("no key pair found for the specified kid: %s", kid)
+
+		// Reload the keys when the folder changes. Keys removed from the folder
+		// keep validating the tokens they signed until those expire.
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+
+		go keystore.Watch(watchCtx, keysFS, cfg.Auth.KeysPollInterval, func(ks *keystore.KeyStore, err error) {
+			if err != nil {
+				log.Errorw("keys", "status", "reloading keys", "ERROR", err)
+				return
+			}
+
+			kid := activeKID(ks)
+			if err := a.Rotate(ks.Keys(), kid, time.Now().Add(user.AccessTokenTTL)); err != nil {
+				log.Errorw("keys", "status", "rotating keys", "ERROR", err)
+				return
+			}
+			log.Infow("keys", "status", "keys reloaded", "activeKID", kid)
+		})
 	}
 
-	auth, err := auth.New(cfg.Auth.Algorithm, lookupKey, auth.Keys{cfg.Auth.KeyID: privateKey})
-	if err != nil {
-		return errors.Wrap(err, "initializing auth service")
+	// Authorization rules come from the policy file when one is configured.
+	// Every denied request is logged for auditing.
+	policy := auth.DefaultPolicy()
+	if cfg.Auth.PolicyFile != "" {
+		policy, err = auth.LoadPolicy(cfg.Auth.PolicyFile)
+		if err != nil {
+			return errors.Wrap(err, "loading authorization policy")
+		}
 	}
+	policy.SetAuditor(func(claims auth.Claims, action string, resource *auth.Resource) {
+		var owner string
+		if resource != nil {
+			owner = resource.OwnerID
+		}
+		log.Warnw("audit", "status", "access denied", "subject", claims.Subject, "jti", claims.ID, "roles", claims.Roles, "action", action, "owner", owner)
+	})
 
 	// =========================================================================
 	// Start Database
@@ -182,6 +278,58 @@ func run(log *zap.SugaredLogger) error {
 		db.Close()
 	}()
 
+	// Access tokens revoked on logout are kept in the database until they expire.
+	a.SetRevocationList(session.New(log, db))
+
+	// Machine clients authenticate with API keys stored in the database.
+	a.SetAPIKeyValidator(apikey.New(log, db, policy, auditdb.TranStorer(log)))
+
+	// Failed token requests are counted in the database by default, so every
+	// replica enforces the same locks. The in-memory store suits a single
+	// instance.
+	var lockoutStore lockout.Storer
+	switch cfg.Lockout.Store {
+	case "postgres":
+		lockoutStore = lockoutdb.NewStore(log, db)
+	case "memory":
+		lockoutStore = lockoutmem.NewStore()
+	default:
+		return errors.Errorf("unknown lockout store %q", cfg.Lockout.Store)
+	}
+	lockouts := lockout.New(log, lockoutStore, lockout.Config{
+		EmailThreshold: cfg.Lockout.EmailThreshold,
+		AddrThreshold:  cfg.Lockout.AddrThreshold,
+		BaseDelay:      cfg.Lockout.BaseDelay,
+		MaxDelay:       cfg.Lockout.MaxDelay,
+		Window:         cfg.Lockout.Window,
+	})
+
+	// =========================================================================
+	// Start Mail Support
+
+	// Password reset and verification emails are only logged by default. The
+	// file mailer writes them to a folder, to be opened with a mail client.
+	var mailer mail.Mailer
+	switch cfg.Mail.Mailer {
+	case "log":
+		mailer = mail.NewLog(log, cfg.Mail.From)
+	case "file":
+		if err := os.MkdirAll(cfg.Mail.Folder, 0700); err != nil {
+			return errors.Wrap(err, "creating mail folder")
+		}
+		mailer = mail.NewFile(cfg.Mail.Folder, cfg.Mail.From)
+	case "smtp":
+		mailer = mail.NewSMTP(mail.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUser,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		})
+	default:
+		return errors.Errorf("unknown mailer %q", cfg.Mail.Mailer)
+	}
+
 	// =========================================================================
 	// Start Tracing Support
 
@@ -241,16 +389,56 @@ func run(log *zap.SugaredLogger) error {
 	// Start API Service
 	log.Infow("startup", "status", "initializing API support")
 
+	// Pagination cursors are signed so clients can not forge positions. Every
+	// replica must share the same secret, otherwise a cursor issued by one
+	// instance is rejected by the others.
+	cursorSecret := []byte(cfg.Web.CursorSecret)
+	if len(cursorSecret) == 0 {
+		log.Infow("startup", "status", "no cursor secret configured, generating a random one")
+		cursorSecret = make([]byte, 32)
+		if _, err := rand.Read(cursorSecret); err != nil {
+			return errors.Wrap(err, "generating cursor secret")
+		}
+	}
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	// The broker keeps the last BufferSize events for subscribers resuming
+	// a stream, and up to QueueSize events for each subscriber before
+	// dropping it. Events are only delivered to the subscribers of this
+	// instance.
+	broker := events.NewBroker(cfg.Events.BufferSize, cfg.Events.QueueSize)
+	defer broker.Close()
+
+	app := handlers.API(handlers.APIConfig{
+		Build:    build,
+		Shutdown: shutdown,
+		Log:      log,
+		Auth:     a,
+		DB:       db,
+		Cursors:  cursor.NewSigner(cursorSecret),
+		Policy:   policy,
+		Lockout:  lockouts,
+		Mailer:   mailer,
+		Events:   broker,
+
+		Heartbeat:            cfg.Events.Heartbeat,
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+	})
+
+	// Event streams are exempted from the timeouts through the connection
+	// stored by ConnContext, and ended when shutdown starts, since they
+	// would otherwise keep it waiting until the deadline.
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      handlers.API(build, shutdown, log, auth, db),
+		Handler:      app,
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 		ErrorLog:     zap.NewStdLog(log.Desugar()),
+		ConnContext:  web.ConnContext,
 	}
+	api.RegisterOnShutdown(app.StopStreams)
 
 	// Make a channel for listening errors coming from the API Http listener. Use a
 	// buffered channel so the goroutine can exit if we don't collect this error.
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+)
+
+// AccountTests holds methods for each password reset and email verification
+// subtest. This type allows passing dependencies for tests.
+type AccountTests struct {
+	app        http.Handler
+	mailbox    string
+	adminToken string
+}
+
+// TestAccounts is the entry point for testing the flows driven by emails.
+func TestAccounts(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	tests := AccountTests{
+		app:        newAPI(test, shutdown),
+		mailbox:    test.Mailbox,
+		adminToken: test.Token("admin@example.com", "gophers"),
+	}
+
+	t.Run("passwordReset", tests.passwordReset)
+	t.Run("verifyEmail", tests.verifyEmail)
+}
+
+// tokenPattern matches the tokens sent by email.
+var tokenPattern = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}\r?$`)
+
+// lastToken returns the token of the latest email and how many emails were
+// sent so far.
+func (at *AccountTests) lastToken(t *testing.T) (string, int) {
+	files, err := filepath.Glob(filepath.Join(at.mailbox, "*.eml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("\t%s\tShould find an email in the mailbox : %v", tests.Failed, err)
+	}
+
+	data, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to read the email : %v", tests.Failed, err)
+	}
+
+	tkn := strings.TrimSpace(tokenPattern.FindString(string(data)))
+	if tkn == "" {
+		t.Fatalf("\t%s\tShould find a token in the email : %s", tests.Failed, data)
+	}
+
+	return tkn, len(files)
+}
+
+// post sends a json body to the api.
+func (at *AccountTests) post(path string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	at.app.ServeHTTP(w, r)
+
+	return w
+}
+
+// token asks the api for a token with basic auth.
+func (at *AccountTests) token(email string, password string) int {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+	w := httptest.NewRecorder()
+
+	r.SetBasicAuth(email, password)
+	at.app.ServeHTTP(w, r)
+
+	return w.Code
+}
+
+// passwordReset resets the password of the seeded user.
+func (at *AccountTests) passwordReset(t *testing.T) {
+	t.Log("Given the need to reset a forgotten password.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the seeded user email.", testID)
+		{
+			if w := at.post("/v1/users/password-reset", `{"email": "user@example.com"}`); w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the request : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the request.", tests.Success, testID)
+
+			tkn, sent := at.lastToken(t)
+
+			if w := at.post("/v1/users/password-reset", `{"email": "nobody@example.com"}`); w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for an unknown email : %v", tests.Failed, testID, w.Code)
+			}
+			if _, n := at.lastToken(t); n != sent {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT send an email to an unknown email : %d emails", tests.Failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT send an email to an unknown email.", tests.Success, testID)
+
+			body := `{"token": "` + tkn + `", "password": "gophers2", "password_confirm": "gophers2"}`
+			if w := at.post("/v1/users/password-reset", body); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the reset : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the reset.", tests.Success, testID)
+
+			if w := at.post("/v1/users/password-reset", body); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT reset twice with the same token : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT reset twice with the same token.", tests.Success, testID)
+
+			if code := at.token("user@example.com", "gophers"); code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT authenticate with the old password : %v", tests.Failed, testID, code)
+			}
+			if code := at.token("user@example.com", "gophers2"); code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate with the new password : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate with the new password.", tests.Success, testID)
+		}
+	}
+}
+
+// verifyEmail verifies the email of a new user.
+func (at *AccountTests) verifyEmail(t *testing.T) {
+	body := `{"name": "Bill Kennedy", "email": "bill@ardanlabs.com", "roles": ["USER"], "password": "gophers", "password_confirm": "gophers"}`
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+at.adminToken)
+	at.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to verify the email of new users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating a new user.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the user : %v", tests.Failed, testID, w.Code)
+			}
+
+			tkn, _ := at.lastToken(t)
+			t.Logf("\t%s\tTest %d:\tShould mail a verification token.", tests.Success, testID)
+
+			if w := at.post("/v1/users/password-reset", `{"token": "`+tkn+`", "password": "x", "password_confirm": "x"}`); w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT reset a password with a verification token : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT reset a password with a verification token.", tests.Success, testID)
+
+			if w := at.post("/v1/users/verify-email", `{"token": "`+tkn+`"}`); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the verification : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the verification.", tests.Success, testID)
+
+			_, sent := at.lastToken(t)
+			if w := at.post("/v1/users/verify-email", `{"email": "bill@ardanlabs.com"}`); w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for a new verification : %v", tests.Failed, testID, w.Code)
+			}
+			if _, n := at.lastToken(t); n != sent {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT mail a verified email again : %d emails", tests.Failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT mail a verified email again.", tests.Success, testID)
+		}
+	}
+}
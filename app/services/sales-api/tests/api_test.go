@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/app/services/sales-api/handlers"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout/stores/lockoutmem"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+	"github.com/danielmbirochi/go-sample-service/foundation/mail"
+)
+
+// newAPI constructs the application routes on top of the systems owned by
+// an integration test.
+func newAPI(test *tests.Test, shutdown chan os.Signal) http.Handler {
+	return handlers.API(handlers.APIConfig{
+		Build:    "develop",
+		Shutdown: shutdown,
+		Log:      test.Log,
+		Auth:     test.Auth,
+		DB:       test.DB,
+		Cursors:  cursor.NewSigner([]byte("test-cursor-secret")),
+		Policy:   test.Policy,
+		Lockout: lockout.New(test.Log, lockoutmem.NewStore(), lockout.Config{
+			EmailThreshold: 3,
+			AddrThreshold:  10,
+			BaseDelay:      time.Minute,
+			MaxDelay:       time.Hour,
+			Window:         24 * time.Hour,
+		}),
+		Mailer:    mail.NewFile(test.Mailbox, "no-reply@example.com"),
+		Events:    events.NewBroker(events.DefaultSize, events.DefaultBuffer),
+		Heartbeat: time.Second,
+	})
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+)
+
+// APIKeyTests holds methods for each api key subtest. This type allows
+// passing dependencies for tests.
+type APIKeyTests struct {
+	app        http.Handler
+	adminToken string
+}
+
+// TestAPIKeys is the entry point for testing api key management functions.
+func TestAPIKeys(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	tests := APIKeyTests{
+		app:        newAPI(test, shutdown),
+		adminToken: test.Token("admin@example.com", "gophers"),
+	}
+
+	t.Run("apiKeyLifecycle", tests.apiKeyLifecycle)
+}
+
+// do sends a request to the api with the given authorization header.
+func (at *APIKeyTests) do(method string, path string, body string, authorization string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", authorization)
+	at.app.ServeHTTP(w, r)
+
+	return w
+}
+
+// apiKeyLifecycle issues a scoped key, uses it and revokes it.
+func (at *APIKeyTests) apiKeyLifecycle(t *testing.T) {
+
+	// Seeded regular user and product.
+	const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	const productID = "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
+
+	t.Log("Given the need to authenticate machine clients with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen issuing a key scoped to reading products.", testID)
+		{
+			body := `{"user_id": "` + userID + `", "name": "reporting", "scopes": ["product:read", "product:list"]}`
+			w := at.do(http.MethodPost, "/v1/apikeys", body, "Bearer "+at.adminToken)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			var issued struct {
+				APIKey struct {
+					ID     string `json:"id"`
+					Prefix string `json:"prefix"`
+				} `json:"api_key"`
+				Key string `json:"key"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&issued); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if !strings.HasPrefix(issued.Key, issued.APIKey.Prefix) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the raw key once : %+v", tests.Failed, testID, issued)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the raw key once.", tests.Success, testID)
+
+			if w := at.do(http.MethodGet, "/v1/products/"+productID, "", "ApiKey "+issued.Key); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould read a product with the key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould read a product with the key.", tests.Success, testID)
+
+			body = `{"name": "Comic Books", "cost": 25, "quantity": 60}`
+			if w := at.do(http.MethodPost, "/v1/products", body, "ApiKey "+issued.Key); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT create a product outside of the key scopes : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT create a product outside of the key scopes.", tests.Success, testID)
+
+			w = at.do(http.MethodGet, "/v1/apikeys?user_id="+userID, "", "Bearer "+at.adminToken)
+			var keys []struct {
+				ID           string  `json:"id"`
+				DateLastUsed *string `json:"date_last_used"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&keys); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list the keys : %v %v", tests.Failed, testID, w.Code, err)
+			}
+			if len(keys) != 1 || keys[0].ID != issued.APIKey.ID || keys[0].DateLastUsed == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list the used key : %+v", tests.Failed, testID, keys)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the used key.", tests.Success, testID)
+
+			if w := at.do(http.MethodPost, "/v1/apikeys", `{}`, "ApiKey "+issued.Key); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT manage keys with a key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT manage keys with a key.", tests.Success, testID)
+
+			if w := at.do(http.MethodDelete, "/v1/apikeys/"+issued.APIKey.ID, "", "Bearer "+at.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the key.", tests.Success, testID)
+
+			if w := at.do(http.MethodGet, "/v1/products/"+productID, "", "ApiKey "+issued.Key); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a revoked key : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a revoked key.", tests.Success, testID)
+		}
+	}
+}
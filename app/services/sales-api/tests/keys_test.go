@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+)
+
+// TestKeys is the entry point for testing the publication of signing keys.
+func TestKeys(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	app := newAPI(test, shutdown)
+
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	t.Log("Given the need to publish the token verification keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen fetching the key set without authentication.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got auth.JWKS
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unmarshal the response.", tests.Success, testID)
+
+			if len(got.Keys) != 1 || got.Keys[0].KeyID != test.KID {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the key used to sign tokens : %+v", tests.Failed, testID, got.Keys)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the key used to sign tokens.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/product"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/google/go-cmp/cmp"
+)
+
+// ProductTests holds methods for each product subtest. This type allows
+// passing dependencies for tests.
+type ProductTests struct {
+	app        http.Handler
+	userToken  string
+	adminToken string
+}
+
+// TestProducts is the entry point for testing product management functions.
+func TestProducts(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	tests := ProductTests{
+		app:        newAPI(test, shutdown),
+		userToken:  test.Token("user@example.com", "gophers"),
+		adminToken: test.Token("admin@example.com", "gophers"),
+	}
+
+	t.Run("crudProduct", tests.crudProduct)
+}
+
+// crudProduct performs a complete test of CRUD against the api.
+func (pt *ProductTests) crudProduct(t *testing.T) {
+	p := pt.postProduct201(t)
+	defer pt.deleteProduct204(t, p.ID)
+
+	pt.getProduct200(t, p.ID)
+	pt.putProduct204(t, p.ID)
+	pt.putProduct403(t, p.ID)
+
+	free := pt.postFreeProduct201(t)
+	pt.deleteProduct204(t, free.ID)
+}
+
+// postProduct201 tests the endpoint for creating products.
+func (pt *ProductTests) postProduct201(t *testing.T) product.Product {
+	np := product.NewProduct{
+		Name:     "Comic Books",
+		Cost:     25,
+		Quantity: 60,
+	}
+
+	body, err := json.Marshal(&np)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/products", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.adminToken)
+	pt.app.ServeHTTP(w, r)
+
+	// This is the return value for performing other tests.
+	var got product.Product
+
+	t.Log("Given the need to create a new product with the products endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the declared product value.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Assign trusted values to pass in the validation: ID, owner, dates.
+			exp := got
+
+			// Assign the actual values to be compared.
+			exp.Name = "Comic Books"
+			exp.Cost = 25
+			exp.Quantity = 60
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+
+	return got
+}
+
+// postFreeProduct201 tests the endpoint accepts products given away at no
+// cost.
+func (pt *ProductTests) postFreeProduct201(t *testing.T) product.Product {
+	np := product.NewProduct{
+		Name:     "Stickers",
+		Cost:     0,
+		Quantity: 100,
+	}
+
+	body, err := json.Marshal(&np)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/products", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.adminToken)
+	pt.app.ServeHTTP(w, r)
+
+	var got product.Product
+
+	t.Log("Given the need to create a product at no cost.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a cost of 0.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v : %s", tests.Failed, testID, w.Code, w.Body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if got.Cost != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould get back a cost of 0 : %d", tests.Failed, testID, got.Cost)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back a cost of 0.", tests.Success, testID)
+		}
+	}
+
+	return got
+}
+
+// deleteProduct204 tests the endpoint for deleting persisted product.
+func (pt *ProductTests) deleteProduct204(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodDelete, "/v1/products/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.adminToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate deleting a product that does exist.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getProduct200 tests endpoint for fetching product by a given id.
+func (pt *ProductTests) getProduct200(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/products/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate getting a product that exists.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the new product %s.", testID, id)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got product.Product
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Assign trusted values to pass in the validation: owner, dates.
+			exp := got
+
+			// Assign the actual values to be compared.
+			exp.ID = id
+			exp.Name = "Comic Books"
+			exp.Cost = 25
+			exp.Quantity = 60
+
+			if diff := cmp.Diff(got, exp); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the expected result. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected result.", tests.Success, testID)
+		}
+	}
+}
+
+// putProduct204 tests endpoint for updating product record by its owner.
+func (pt *ProductTests) putProduct204(t *testing.T, id string) {
+	body := `{"name": "Graphic Novels"}`
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/products/"+id, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.adminToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to update a product with the products endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the modified product value.", testID)
+		{
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/products/"+id, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+pt.adminToken)
+			pt.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the retrieve.", tests.Success, testID)
+
+			var ru product.Product
+			if err := json.NewDecoder(w.Body).Decode(&ru); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if ru.Name != "Graphic Novels" {
+				t.Fatalf("\t%s\tTest %d:\tShould see an updated Name : got %q want %q", tests.Failed, testID, ru.Name, "Graphic Novels")
+			}
+			t.Logf("\t%s\tTest %d:\tShould see an updated Name.", tests.Success, testID)
+
+			if ru.Quantity != 60 {
+				t.Fatalf("\t%s\tTest %d:\tShould not affect other fields like Quantity : got %d want %d", tests.Failed, testID, ru.Quantity, 60)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not affect other fields like Quantity.", tests.Success, testID)
+		}
+	}
+}
+
+// putProduct403 tests endpoint for updating a product the caller does not own.
+func (pt *ProductTests) putProduct403(t *testing.T, id string) {
+	body := `{"name": "Action Figures"}`
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/products/"+id, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to update a product with the products endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a non-owner user makes a request", testID)
+		{
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/sale"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+)
+
+// SaleTests holds methods for each sale subtest. This type allows passing
+// dependencies for tests.
+type SaleTests struct {
+	app       http.Handler
+	userToken string
+}
+
+// TestSales is the entry point for testing sale management functions.
+func TestSales(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+	tests := SaleTests{
+		app:       newAPI(test, shutdown),
+		userToken: test.Token("user@example.com", "gophers"),
+	}
+
+	t.Run("postSale", tests.postSale)
+}
+
+// postSale buys units of a seeded product until the stock runs out.
+func (st *SaleTests) postSale(t *testing.T) {
+
+	// Seeded product with 42 units available.
+	const id = "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
+
+	st.postSale201(t, id)
+	st.postSale409(t, id)
+	st.getSales200(t, id)
+}
+
+// postSale201 tests the endpoint for recording a sale.
+func (st *SaleTests) postSale201(t *testing.T, id string) {
+	body := `{"quantity": 40}`
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/products/"+id+"/sales", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+st.userToken)
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to record a sale with the sales endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the product has enough stock.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			var got sale.Sale
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if got.Paid != 40*50 {
+				t.Fatalf("\t%s\tTest %d:\tShould charge the product cost : got %d want %d", tests.Failed, testID, got.Paid, 40*50)
+			}
+			t.Logf("\t%s\tTest %d:\tShould charge the product cost.", tests.Success, testID)
+		}
+	}
+}
+
+// postSale409 tests the endpoint for recording a sale without enough stock.
+func (st *SaleTests) postSale409(t *testing.T, id string) {
+	body := `{"quantity": 3}`
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/products/"+id+"/sales", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+st.userToken)
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to record a sale with the sales endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the product does not have enough stock.", testID)
+		{
+			if w.Code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getSales200 tests the endpoint for listing the sales of a product.
+func (st *SaleTests) getSales200(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/products/"+id+"/sales", nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+st.userToken)
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to list the sales of a product.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the seeded product %s.", testID, id)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			var got []sale.Sale
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Two seeded sales plus the one recorded by postSale201.
+			if len(got) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould get every sale of the product : got %d want %d", tests.Failed, testID, len(got), 3)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get every sale of the product.", tests.Success, testID)
+		}
+	}
+}
@@ -2,17 +2,21 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/danielmbirochi/go-sample-service/app/services/sales-api/handlers"
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
 	"github.com/danielmbirochi/go-sample-service/business/core/user"
 	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -20,6 +24,7 @@ import (
 // dependencies for tests.
 type UserTests struct {
 	app        http.Handler
+	scopedApp  http.Handler
 	kid        string
 	userToken  string
 	adminToken string
@@ -30,15 +35,30 @@ func TestUsers(t *testing.T) {
 	test := tests.NewIntegration(t)
 	t.Cleanup(test.Teardown)
 
+	// The scoped api only lets users unlock their own account.
+	policy, err := auth.LoadPolicy("testdata/scoped_policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scoped := *test
+	scoped.Policy = policy
+
 	shutdown := make(chan os.Signal, 1)
 	tests := UserTests{
-		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB),
+		app:        newAPI(test, shutdown),
+		scopedApp:  newAPI(&scoped, shutdown),
 		kid:        test.KID,
 		userToken:  test.Token("user@example.com", "gophers"),
 		adminToken: test.Token("admin@example.com", "gophers"),
 	}
 
 	t.Run("crudUser", tests.crudUser)
+	t.Run("listUsers", tests.listUsers)
+	t.Run("tokenSession", tests.tokenSession)
+	t.Run("tokenLockout", tests.tokenLockout)
+	t.Run("scopedUnlock", tests.scopedUnlock)
+	t.Run("meUser", tests.meUser)
+	t.Run("bulkUsers", tests.bulkUsers)
 
 }
 
@@ -50,6 +70,357 @@ func (ut *UserTests) crudUser(t *testing.T) {
 	ut.getUser200(t, nu.ID)
 	ut.putUser204(t, nu.ID)
 	ut.putUser403(t, nu.ID)
+	ut.putUser412(t, nu.ID)
+	ut.patchUser(t, nu.ID)
+	ut.restoreUser(t, nu.ID)
+	ut.auditUser(t, nu.ID)
+	ut.eventsUser(t, nu.ID)
+}
+
+// listUsers walks through the seeded users one page at a time.
+func (ut *UserTests) listUsers(t *testing.T) {
+	next := ut.getUsers200(t, "", true)
+	ut.getUsers200(t, next, false)
+	ut.getUsers400(t, "cursor="+next[1:])
+	ut.getUsers400(t, "password_hash=x")
+	ut.getUsers400(t, "order_by=password_hash")
+	ut.getUsersNegotiated(t)
+}
+
+// getUsers200 tests the endpoint for listing users with a page of one row.
+func (ut *UserTests) getUsers200(t *testing.T, cursor string, hasMore bool) string {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users?rows=1&cursor="+cursor, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	var got struct {
+		Items      []user.User `json:"items"`
+		NextCursor string      `json:"next_cursor"`
+		HasMore    bool        `json:"has_more"`
+	}
+
+	t.Log("Given the need to page through users with the users endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the cursor %q.", testID, cursor)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if len(got.Items) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould get a single user : got %d", tests.Failed, testID, len(got.Items))
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a single user.", tests.Success, testID)
+
+			if got.HasMore != hasMore || (got.NextCursor != "") != hasMore {
+				t.Fatalf("\t%s\tTest %d:\tShould report if there are more pages : got %v want %v", tests.Failed, testID, got.HasMore, hasMore)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report if there are more pages.", tests.Success, testID)
+		}
+	}
+
+	return got.NextCursor
+}
+
+// getUsers400 tests the endpoint for listing users with invalid parameters.
+func (ut *UserTests) getUsers400(t *testing.T, query string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users?"+query, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to page through users with the users endpoint.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the query %q.", testID, query)
+		{
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getUsersNegotiated tests the endpoint for listing users in the media types
+// clients ask for.
+func (ut *UserTests) getUsersNegotiated(t *testing.T) {
+	t.Log("Given the need to list users in several media types.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen accepting CSV.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?rows=2", nil)
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Accept", "text/csv, application/json;q=0.5")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != web.CSVType {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a CSV document : %v %s", tests.Failed, testID, w.Code, w.Header().Get("Content-Type"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a CSV document.", tests.Success, testID)
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil || len(records) != 3 || records[0][0] != "id" {
+				t.Fatalf("\t%s\tTest %d:\tShould get a header and a row per user : %v %v", tests.Failed, testID, err, records)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a header and a row per user.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen accepting nothing the users can be encoded in.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Accept", "text/html")
+			ut.app.ServeHTTP(w, r)
+
+			var problem web.Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the problem : %v", tests.Failed, testID, err)
+			}
+			if w.Code != http.StatusNotAcceptable || problem.Code != "not_acceptable" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 406 for the response : %v %+v", tests.Failed, testID, w.Code, problem)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 406 for the response.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen sending a body of an unsupported media type.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader("name=Bill"))
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnsupportedMediaType {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 415 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 415 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// tokenSession performs a login, refresh and logout against the api.
+func (ut *UserTests) tokenSession(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+	w := httptest.NewRecorder()
+
+	r.SetBasicAuth("user@example.com", "gophers")
+	ut.app.ServeHTTP(w, r)
+
+	var tkn struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	t.Log("Given the need to keep a session alive and end it.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the seeded user credentials.", testID)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the token : %v", tests.Failed, testID, w.Code)
+			}
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil || tkn.RefreshToken == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get a refresh token : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a refresh token.", tests.Success, testID)
+
+			body := `{"refresh_token": "` + tkn.RefreshToken + `"}`
+			r = httptest.NewRequest(http.MethodPost, "/v1/users/token/refresh", strings.NewReader(body))
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the refresh : %v", tests.Failed, testID, w.Code)
+			}
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the refresh : %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the refresh.", tests.Success, testID)
+
+			body = `{"refresh_token": "` + tkn.RefreshToken + `"}`
+			r = httptest.NewRequest(http.MethodPost, "/v1/users/logout", strings.NewReader(body))
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+tkn.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the logout : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the logout.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+tkn.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the revoked access token : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the revoked access token.", tests.Success, testID)
+		}
+	}
+}
+
+// tokenLockout guesses the password of a user until the account is locked,
+// then has an admin unlock it.
+func (ut *UserTests) tokenLockout(t *testing.T) {
+	token := func(password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+
+		r.SetBasicAuth("user@example.com", password)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Log("Given the need to stop password guessing.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen guessing the password of the seeded user.", testID)
+		{
+			// The api used by the tests locks an email after 3 failures.
+			for i := 0; i < 3; i++ {
+				if w := token("guess"); w.Code != http.StatusUnauthorized {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for a wrong password : %v", tests.Failed, testID, w.Code)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for a wrong password.", tests.Success, testID)
+
+			w := token("gophers")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 429 once locked : %v", tests.Failed, testID, w.Code)
+			}
+			if w.Header().Get("Retry-After") != "60" {
+				t.Fatalf("\t%s\tTest %d:\tShould tell when to retry : %q", tests.Failed, testID, w.Header().Get("Retry-After"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 429 once locked.", tests.Success, testID)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f/unlock", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.userToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT let a user unlock an account : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT let a user unlock an account.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/v1/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f/unlock", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the unlock : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the unlock.", tests.Success, testID)
+
+			if w := token("gophers"); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould get a token once unlocked : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a token once unlocked.", tests.Success, testID)
+		}
+	}
+}
+
+// scopedUnlock has a user with a grant scoped to their own account unlock
+// another account.
+func (ut *UserTests) scopedUnlock(t *testing.T) {
+	unlock := func(id string) int {
+		r := httptest.NewRequest(http.MethodPost, "/v1/users/"+id+"/unlock", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.scopedApp.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	t.Log("Given the need to hold scoped grants to the accounts of the user.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a user can only unlock their own account.", testID)
+		{
+			if code := unlock("5cf37266-3473-4006-984f-9325122678b7"); code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for another account : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for another account.", tests.Success, testID)
+
+			if code := unlock("45b5fbd3-755f-4379-8f07-a58d4a30fa2f"); code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for their own account : %v", tests.Failed, testID, code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for their own account.", tests.Success, testID)
+		}
+	}
+}
+
+// meUser reads and updates the profile of the seeded user.
+func (ut *UserTests) meUser(t *testing.T) {
+	me := func(method string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/v1/users/me", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Log("Given the need to manage one's own profile.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the seeded user token.", testID)
+		{
+			w := me(http.MethodGet, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the profile : %v", tests.Failed, testID, w.Code)
+			}
+
+			var got user.User
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.ID != "45b5fbd3-755f-4379-8f07-a58d4a30fa2f" {
+				t.Fatalf("\t%s\tTest %d:\tShould get own user : %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get own user.", tests.Success, testID)
+
+			if w := me(http.MethodPut, `{"name": "User Gopher Jr"}`); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for a new name : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for a new name.", tests.Success, testID)
+
+			if w := me(http.MethodPut, `{"roles": ["ADMIN", "USER"]}`); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to grant roles to oneself : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to grant roles to oneself.", tests.Success, testID)
+
+			body := `{"password": "gophers2", "password_confirm": "gophers2", "current_password": "wrong"}`
+			if w := me(http.MethodPut, body); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT change the password with a wrong current one : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT change the password with a wrong current one.", tests.Success, testID)
+
+			body = `{"password": "gophers", "password_confirm": "gophers", "current_password": "gophers"}`
+			if w := me(http.MethodPut, body); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould change the password with the current one : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change the password with the current one.", tests.Success, testID)
+		}
+	}
 }
 
 // postUser201 tests the endpoint for creating users.
@@ -108,6 +479,414 @@ func (ut *UserTests) postUser201(t *testing.T) user.User {
 	return got
 }
 
+// putUser412 tests that updates conditioned on a stale version of a user
+// are refused.
+func (ut *UserTests) putUser412(t *testing.T, id string) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	ut.app.ServeHTTP(w, r)
+
+	etag := w.Header().Get("ETag")
+
+	t.Log("Given the need to keep concurrent updates from overwriting each other.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the ETag %s of the user %s.", testID, etag, id)
+		{
+			if etag == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive an ETag for the user.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive an ETag for the user.", tests.Success, testID)
+
+			for _, status := range []int{http.StatusNoContent, http.StatusPreconditionFailed} {
+				r := httptest.NewRequest(http.MethodPut, "/v1/users/"+id, strings.NewReader(`{"name": "Gavin Wood"}`))
+				w := httptest.NewRecorder()
+
+				r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+				r.Header.Set("If-Match", etag)
+				ut.app.ServeHTTP(w, r)
+
+				if w.Code != status {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of %d for the response : %v", tests.Failed, testID, status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of %d for the response.", tests.Success, testID, status)
+			}
+		}
+	}
+}
+
+// patchUser tests partial updates of a user with both patch formats.
+func (ut *UserTests) patchUser(t *testing.T, id string) {
+	t.Log("Given the need to partially update a user.")
+	{
+		for testID, tt := range []struct {
+			contentType string
+			patch       string
+			status      int
+		}{
+			{"application/merge-patch+json", `{"name": "Ken Thompson"}`, http.StatusNoContent},
+			{"application/json-patch+json", `[{"op": "test", "path": "/name", "value": "Ken Thompson"}, {"op": "add", "path": "/roles/-", "value": "OPERATOR"}]`, http.StatusNoContent},
+			{"application/json-patch+json", `[{"op": "test", "path": "/name", "value": "Gavin Wood"}]`, http.StatusConflict},
+			{"application/merge-patch+json", `{"email": "not an email"}`, http.StatusBadRequest},
+			{"application/json", `{"name": "Rob Pike"}`, http.StatusUnsupportedMediaType},
+		} {
+			t.Logf("\tTest %d:\tWhen using the %s patch %s.", testID, tt.contentType, tt.patch)
+			{
+				r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+id, strings.NewReader(tt.patch))
+				w := httptest.NewRecorder()
+
+				r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+				r.Header.Set("Content-Type", tt.contentType)
+				ut.app.ServeHTTP(w, r)
+
+				if w.Code != tt.status {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of %d for the response : %v", tests.Failed, testID, tt.status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of %d for the response.", tests.Success, testID, tt.status)
+			}
+		}
+
+		testID := 5
+		t.Logf("\tTest %d:\tWhen reading the patched user %s.", testID, id)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			var got user.User
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			if got.Name != "Ken Thompson" || !cmp.Equal([]string(got.Roles), []string{auth.RoleAdmin, auth.RoleOperator}) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the patched user : %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the patched user.", tests.Success, testID)
+		}
+	}
+}
+
+// restoreUser soft deletes a user, finds it again through the listing and
+// brings it back with the restore endpoint.
+func (ut *UserTests) restoreUser(t *testing.T, id string) {
+	ut.deleteUser204(t, id)
+
+	t.Log("Given the need to restore a deleted user.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the deleted user %s.", testID, id)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Accept-Language", "pt-PT, en;q=0.8")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT find a deleted user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT find a deleted user.", tests.Success, testID)
+
+			var problem web.Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the problem : %v", tests.Failed, testID, err)
+			}
+			if w.Header().Get("Content-Type") != web.ProblemType || problem.Code != "user_not_found" || problem.Instance != "/v1/users/"+id {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the problem with a stable code : %+v", tests.Failed, testID, problem)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the problem with a stable code.", tests.Success, testID)
+
+			if problem.Detail != "usuário não encontrado" || w.Header().Get("Content-Language") != web.LocalePTBR {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the problem in the language of the client : %q", tests.Failed, testID, problem.Detail)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the problem in the language of the client.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users?include_deleted=true&email=dmbirochi", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			var got struct {
+				Items []user.User `json:"items"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+			if len(got.Items) != 1 || got.Items[0].ID != id || got.Items[0].DateDeleted == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list the deleted user on demand : %+v", tests.Failed, testID, got.Items)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the deleted user on demand.", tests.Success, testID)
+
+			body, err := json.Marshal(user.NewUser{
+				Name:            "Twin Gopher",
+				Email:           got.Items[0].Email,
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewBuffer(body))
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			var twin user.User
+			if err := json.NewDecoder(w.Body).Decode(&twin); err != nil || w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to take the email of the deleted user : %v %v", tests.Failed, testID, w.Code, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to take the email of the deleted user.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodPost, "/v1/users/"+id+"/restore", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 while the email is taken : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 while the email is taken.", tests.Success, testID)
+
+			ut.deleteUser204(t, twin.ID)
+
+			for _, status := range []int{http.StatusNoContent, http.StatusNotFound} {
+				r = httptest.NewRequest(http.MethodPost, "/v1/users/"+id+"/restore", nil)
+				w = httptest.NewRecorder()
+				r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+				ut.app.ServeHTTP(w, r)
+
+				if w.Code != status {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of %d for the restore : %v", tests.Failed, testID, status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of %d for the restore.", tests.Success, testID, status)
+			}
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould find a restored user : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find a restored user.", tests.Success, testID)
+		}
+	}
+}
+
+// auditUser tests that the changes made to a user can be reviewed by admins
+// only.
+func (ut *UserTests) auditUser(t *testing.T, id string) {
+	t.Log("Given the need to review the changes made to a user.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the changed user %s.", testID, id)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/audit?target_type=user&target_id="+id, nil)
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.userToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT let users review events : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT let users review events.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/audit?target_type=user&target_id="+id, nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+
+			var got struct {
+				Items []audit.Event `json:"items"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", tests.Failed, testID, err)
+			}
+
+			// Events are listed from the newest, so the creation comes last.
+			if len(got.Items) == 0 || got.Items[len(got.Items)-1].Action != auth.ActionUserCreate || got.Items[0].Action != auth.ActionUserRestore {
+				t.Fatalf("\t%s\tTest %d:\tShould list every change from the newest : %+v", tests.Failed, testID, got.Items)
+			}
+			for _, ev := range got.Items {
+				if ev.ActorID == "" || ev.TargetID != id {
+					t.Fatalf("\t%s\tTest %d:\tShould record the actor of every change : %+v", tests.Failed, testID, ev)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould list every change from the newest.", tests.Success, testID)
+		}
+	}
+}
+
+// bulkUsers imports users from CSV and NDJSON and finds them in the export.
+func (ut *UserTests) bulkUsers(t *testing.T) {
+	t.Log("Given the need to import and export users in bulk.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen importing users.", testID)
+		{
+			for _, tt := range []struct {
+				query       string
+				contentType string
+				body        string
+				status      int
+			}{
+				{
+					"?dry_run=true",
+					"application/x-ndjson",
+					`{"name": "Bulk", "email": "bulk@example.com", "roles": ["OPERATOR"], "password": "gophers", "password_confirm": "gophers"}` + "\n",
+					http.StatusOK,
+				},
+				{
+					"",
+					"text/csv",
+					"name,email,roles,password,password_confirm\nBulk,bulk@example.com,OPERATOR,gophers,gophers\nAdmin,admin@example.com,ADMIN,gophers,gophers\n",
+					http.StatusUnprocessableEntity,
+				},
+				{
+					"",
+					"text/csv",
+					"name,email,roles,password,password_confirm\nBulk,bulk@example.com,OPERATOR,gophers,gophers\n",
+					http.StatusCreated,
+				},
+				{
+					"",
+					"application/json",
+					"[]",
+					http.StatusUnsupportedMediaType,
+				},
+			} {
+				r := httptest.NewRequest(http.MethodPost, "/v1/users/import"+tt.query, strings.NewReader(tt.body))
+				w := httptest.NewRecorder()
+				r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+				r.Header.Set("Content-Type", tt.contentType)
+				ut.app.ServeHTTP(w, r)
+
+				if w.Code != tt.status {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of %d for %s : %v %s", tests.Failed, testID, tt.status, tt.contentType, w.Code, w.Body)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of %d for %s.", tests.Success, testID, tt.status, tt.contentType)
+			}
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen exporting users.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/export?email=bulk", nil)
+			w := httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Accept", "text/csv")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a CSV document : %v %s", tests.Failed, testID, w.Code, w.Header().Get("Content-Type"))
+			}
+
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+			if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,name,email,roles") || !strings.Contains(lines[1], "bulk@example.com") {
+				t.Fatalf("\t%s\tTest %d:\tShould export the imported user : %q", tests.Failed, testID, lines)
+			}
+			if strings.Contains(w.Body.String(), "password") {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT export password hashes : %q", tests.Failed, testID, lines)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export the imported user.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/export?email=bulk", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			r.Header.Set("Accept", "application/json")
+			ut.app.ServeHTTP(w, r)
+
+			var exported []user.User
+			if err := json.NewDecoder(w.Body).Decode(&exported); err != nil || w.Header().Get("Content-Type") != web.JSONType {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a JSON array : %v %s", tests.Failed, testID, err, w.Header().Get("Content-Type"))
+			}
+			if len(exported) != 1 || exported[0].Email != "bulk@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould stream the imported user : %+v", tests.Failed, testID, exported)
+			}
+			t.Logf("\t%s\tTest %d:\tShould stream the imported user.", tests.Success, testID)
+		}
+	}
+}
+
+// eventsUser tests that the changes made to a user are streamed to the
+// subscribers allowed to read it. Resuming from an unknown event replays
+// every event kept after a reset.
+func (ut *UserTests) eventsUser(t *testing.T, id string) {
+	stream := func(token string) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/events", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("Last-Event-ID", "0-0")
+		ut.app.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Log("Given the need to follow the changes made to users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen subscribing with the admin token.", testID)
+		{
+			w := stream(ut.adminToken)
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != web.EventStreamType {
+				t.Fatalf("\t%s\tTest %d:\tShould receive an event stream : %v %s", tests.Failed, testID, w.Code, w.Header().Get("Content-Type"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive an event stream.", tests.Success, testID)
+
+			body := w.Body.String()
+			if !strings.HasPrefix(body, "event: reset\n") {
+				t.Fatalf("\t%s\tTest %d:\tShould be told to reload before the events kept : %q", tests.Failed, testID, body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be told to reload before the events kept.", tests.Success, testID)
+
+			for _, typ := range []string{user.EventCreated, user.EventUpdated, user.EventDeleted} {
+				if !strings.Contains(body, "event: "+typ+"\n"+`data: {"id":"`+id+`"`) {
+					t.Fatalf("\t%s\tTest %d:\tShould receive the %s event of the user : %q", tests.Failed, testID, typ, body)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the events of the user.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen subscribing with the user token.", testID)
+		{
+			w := stream(ut.userToken)
+			if w.Code != http.StatusOK || strings.Contains(w.Body.String(), id) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT receive the events of other users : %v %q", tests.Failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT receive the events of other users.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen subscribing without a token.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401.", tests.Success, testID)
+		}
+	}
+}
+
 // deleteUser204 tests the endpoint for deleting persisted user.
 func (ut *UserTests) deleteUser204(t *testing.T, id string) {
 	r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+id, nil)
@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -11,12 +15,63 @@ import (
 	"github.com/pkg/errors"
 )
 
+// These are the key types supported by KeyGen, with the token signing
+// algorithm each of them is used with.
+const (
+	KeyTypeRSA     = "rsa"     // RS256
+	KeyTypeECDSA   = "ecdsa"   // ES256
+	KeyTypeEd25519 = "ed25519" // EdDSA
+)
+
 // KeyGen creates an x509 private/public key for auth tokens.
-func KeyGen() error {
+func KeyGen(keyType string) error {
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return errors.Wrap(err, "generating private key value")
+	var privateKey crypto.Signer
+	var privateBlock pem.Block
+	switch keyType {
+	case KeyTypeRSA:
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return errors.Wrap(err, "generating private key value")
+		}
+		privateKey = rsaKey
+		privateBlock = pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+		}
+
+	case KeyTypeECDSA:
+		ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return errors.Wrap(err, "generating private key value")
+		}
+		der, err := x509.MarshalECPrivateKey(ecdsaKey)
+		if err != nil {
+			return errors.Wrap(err, "marshaling private key")
+		}
+		privateKey = ecdsaKey
+		privateBlock = pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+
+	case KeyTypeEd25519:
+		_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return errors.Wrap(err, "generating private key value")
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+		if err != nil {
+			return errors.Wrap(err, "marshaling private key")
+		}
+		privateKey = ed25519Key
+		privateBlock = pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+
+	default:
+		return errors.Errorf("unsupported key type %q, use %s, %s or %s", keyType, KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519)
 	}
 
 	// Create a file for the private key information in PEM form.
@@ -26,17 +81,12 @@ func KeyGen() error {
 	}
 	defer privateFile.Close()
 
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-
 	if err := pem.Encode(privateFile, &privateBlock); err != nil {
 		return errors.Wrap(err, "encoding to private file")
 	}
 
 	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
 		return errors.Wrap(err, "marshaling public key")
 	}
@@ -46,12 +96,15 @@ func KeyGen() error {
 	if err != nil {
 		return errors.Wrap(err, "creating public file")
 	}
-	defer privateFile.Close()
+	defer publicFile.Close()
 
 	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  "PUBLIC KEY",
 		Bytes: asn1Bytes,
 	}
+	if keyType == KeyTypeRSA {
+		publicBlock.Type = "RSA PUBLIC KEY"
+	}
 
 	if err := pem.Encode(publicFile, &publicBlock); err != nil {
 		return errors.Wrap(err, "encoding to public file")
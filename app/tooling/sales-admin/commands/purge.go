@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/user/stores/userdb"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Purge permanently removes the users that were soft deleted longer ago
+// than the retention period.
+func Purge(cfg database.Config, retention time.Duration) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return errors.Wrap(err, "connect database")
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	log := zap.NewNop().Sugar()
+	u := user.New(log, userdb.NewStore(log, db), auth.DefaultPolicy())
+
+	traceID := "00000000-0000-0000-0000-000000000000"
+	now := time.Now()
+	n, err := u.Purge(ctx, traceID, now.Add(-retention), now)
+	if err != nil {
+		return errors.Wrap(err, "purging users")
+	}
+
+	fmt.Printf("\npurged %d users deleted more than %s ago\n", n, retention)
+	return nil
+}
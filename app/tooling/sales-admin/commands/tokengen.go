@@ -1,12 +1,14 @@
 package commands
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/foundation/keystore"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/pkg/errors"
 )
@@ -15,7 +17,7 @@ import (
 func TokenGen(id string, privateKeyFile string, algorithm string) error {
 	if id == "" || privateKeyFile == "" || algorithm == "" {
 		fmt.Println("help: gentoken <id> <private_key_file> <algorithm>")
-		fmt.Println("algorithm: RS256, HS256")
+		fmt.Println("algorithm: RS256, ES256, EdDSA, HS256 (the file holds the secret)")
 		return nil
 	}
 
@@ -24,11 +26,6 @@ func TokenGen(id string, privateKeyFile string, algorithm string) error {
 		return errors.Wrap(err, "reading PEM private key file")
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-	if err != nil {
-		return errors.Wrap(err, "parsing PEM into private key")
-	}
-
 	// In a production system, a key id (KID) would be assigned to
 	// a key pair that will be used for generating JWT with a set of Claims. A
 	// keyLookupFunc is provided to perform the task of retrieving a key pair for
@@ -37,17 +34,33 @@ func TokenGen(id string, privateKeyFile string, algorithm string) error {
 	// Here we`re using an arbitrary KID and the hardcoded key lookup function to fetch the right
 	// key pair given a KID.
 	keyID := "32bc1165-24t2-61a7-af3e-9da4agf2h1p1"
-	keyLookupFunc := func(publicKID string) (*rsa.PublicKey, error) {
-		switch publicKID {
-		case keyID:
-			return &privateKey.PublicKey, nil
+
+	var a *auth.Auth
+	switch {
+	case strings.HasPrefix(algorithm, "HS"):
+		a, err = auth.NewHMAC(algorithm, auth.Secrets{keyID: privatePEM})
+		if err != nil {
+			return errors.Wrap(err, "constructing auth")
 		}
-		return nil, fmt.Errorf("no public key found for the specified kid: %s", publicKID)
-	}
 
-	a, err := auth.New(algorithm, keyLookupFunc, auth.Keys{keyID: privateKey})
-	if err != nil {
-		return errors.Wrap(err, "constructing auth")
+	default:
+		privateKey, err := keystore.ParsePrivateKey(privatePEM)
+		if err != nil {
+			return errors.Wrap(err, "parsing PEM into private key")
+		}
+
+		keyLookupFunc := func(publicKID string) (crypto.PublicKey, error) {
+			switch publicKID {
+			case keyID:
+				return privateKey.Public(), nil
+			}
+			return nil, fmt.Errorf("no public key found for the specified kid: %s", publicKID)
+		}
+
+		a, err = auth.New(algorithm, keyLookupFunc, auth.Keys{keyID: privateKey})
+		if err != nil {
+			return errors.Wrap(err, "constructing auth")
+		}
 	}
 
 	claims := auth.Claims{
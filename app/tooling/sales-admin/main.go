@@ -2,9 +2,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ardanlabs/conf"
 	"github.com/danielmbirochi/go-sample-service/app/tooling/sales-admin/commands"
@@ -52,10 +54,13 @@ func run() error {
 			}
 			fmt.Println(usage)
 			fmt.Println("\n\n========================== SUPPORTED FLAGS ==========================")
-			fmt.Println("\n-keygen: generate a set of private/public key files")
+			fmt.Println("\n-keygen [-type rsa|ecdsa|ed25519]: generate a set of private/public key files")
 			fmt.Println("\n-tokengen: generate a JWT for a user with claims")
 			fmt.Println("\n-migrate: create the schema in the database")
 			fmt.Println("\n-seed: add data to the database")
+			fmt.Println("\n-purge [-retention 720h]: remove users deleted longer ago than the retention")
+			fmt.Println("\n-users import [-dry-run] <file.csv|file.ndjson>: create users in bulk")
+			fmt.Println("\n-users export [file.csv|file.ndjson]: write every user to a file, or to stdout")
 			return nil
 		case conf.ErrVersionWanted:
 			version, err := conf.VersionString(prefix, &cfg)
@@ -89,7 +94,13 @@ func run() error {
 
 	switch cfg.Args.Num(0) {
 	case "keygen":
-		if err := commands.KeyGen(); err != nil {
+		flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+		keyType := flags.String("type", commands.KeyTypeRSA, "key type: rsa, ecdsa (P-256) or ed25519")
+		if err := flags.Parse(cfg.Args[1:]); err != nil {
+			return errors.Wrap(err, "parsing keygen flags")
+		}
+
+		if err := commands.KeyGen(*keyType); err != nil {
 			return errors.Wrap(err, "key genereration")
 		}
 
@@ -111,12 +122,51 @@ func run() error {
 			return errors.Wrap(err, "seeding database")
 		}
 
+	case "purge":
+		flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+		retention := flags.Duration("retention", 30*24*time.Hour, "how long deleted users are kept")
+		if err := flags.Parse(cfg.Args[1:]); err != nil {
+			return errors.Wrap(err, "parsing purge flags")
+		}
+
+		if err := commands.Purge(dbConfig, *retention); err != nil {
+			return errors.Wrap(err, "purging users")
+		}
+
+	case "users":
+		switch cfg.Args.Num(1) {
+		case "import":
+			flags := flag.NewFlagSet("users import", flag.ContinueOnError)
+			dryRun := flags.Bool("dry-run", false, "check the rows without creating users")
+			if err := flags.Parse(cfg.Args[2:]); err != nil {
+				return errors.Wrap(err, "parsing users import flags")
+			}
+			if flags.NArg() != 1 {
+				return errors.New("users import takes the file to import")
+			}
+
+			if err := commands.UsersImport(dbConfig, flags.Arg(0), *dryRun); err != nil {
+				return errors.Wrap(err, "importing users")
+			}
+
+		case "export":
+			if err := commands.UsersExport(dbConfig, cfg.Args.Num(2)); err != nil {
+				return errors.Wrap(err, "exporting users")
+			}
+
+		default:
+			return errors.Errorf("unknown users command %q, expected import or export", cfg.Args.Num(1))
+		}
+
 	default:
 		fmt.Println("\n\n========================== SUPPORTED FLAGS ==========================")
-		fmt.Println("\n-keygen: generate a set of private/public key files")
+		fmt.Println("\n-keygen [-type rsa|ecdsa|ed25519]: generate a set of private/public key files")
 		fmt.Println("\n-tokengen: generate a JWT for a user with claims")
 		fmt.Println("\n-migrate: create the schema in the database")
 		fmt.Println("\n-seed: add data to the database")
+		fmt.Println("\n-purge [-retention 720h]: remove users deleted longer ago than the retention")
+		fmt.Println("\n-users import [-dry-run] <file.csv|file.ndjson>: create users in bulk")
+		fmt.Println("\n-users export [file.csv|file.ndjson]: write every user to a file, or to stdout")
 		return nil
 	}
 
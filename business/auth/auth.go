@@ -2,7 +2,13 @@
 package auth
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/pkg/errors"
@@ -22,9 +28,12 @@ type ctxKey int
 const Key ctxKey = 1
 
 // Claims represents the authorization claims transmitted via a JWT.
+// Scopes, when present, restrict the actions a Policy grants to the roles.
+// They are set for API keys.
 type Claims struct {
 	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // Valid is called for validating parsed tokens.
@@ -50,8 +59,28 @@ func (c Claims) HasRole(roles ...string) bool {
 	return false
 }
 
-// Keys represents an in memory store of keys.
-type Keys map[string]*rsa.PrivateKey
+// Keys represents an in memory store of private keys, used by the
+// asymmetric algorithms (RS256 | ES256 | EdDSA).
+type Keys map[string]crypto.Signer
+
+// Secrets represents an in memory store of shared secrets, used by the
+// HMAC algorithms (HS256).
+type Secrets map[string][]byte
+
+// key holds what is needed to sign and verify tokens under a kid. For the
+// asymmetric algorithms these are a crypto.Signer and its public key, for
+// HMAC both are the same shared secret.
+type key struct {
+	sign   interface{}
+	verify interface{}
+}
+
+// retiredKey is the verification part of a key that was rotated out. It is
+// kept to validate the tokens it signed until the last of them expires.
+type retiredKey struct {
+	verify interface{}
+	until  time.Time
+}
 
 // KeyLookupFunc defines the signature of a function to lookup public keys.
 //
@@ -67,15 +96,41 @@ type Keys map[string]*rsa.PrivateKey
 //
 // * KID to public key resolution is usually accomplished via a public JWKS
 // endpoint. See https://auth0.com/docs/jwks for more details.
-type PublicKeyLookup func(publicKID string) (*rsa.PublicKey, error)
+type PublicKeyLookup func(publicKID string) (crypto.PublicKey, error)
+
+// ErrInvalidAPIKey is returned when an API key is unknown, expired or revoked.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyValidator declares the behavior for resolving an API key into the
+// claims of its owner. Implementations return ErrInvalidAPIKey when the key
+// can not be used.
+type APIKeyValidator interface {
+	ValidateAPIKey(ctx context.Context, traceID string, key string, now time.Time) (Claims, error)
+}
+
+// RevocationList declares the behavior for checking if an access token,
+// identified by its jti claim, was revoked before expiring.
+type RevocationList interface {
+	IsRevoked(ctx context.Context, traceID string, jti string) (bool, error)
+}
 
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
+//
+// Auth is safe for concurrent use, so keys can be rotated while requests
+// are in flight.
 type Auth struct {
 	algorithm     string
+	method        jwt.SigningMethod
 	lookupKeyFunc func(t *jwt.Token) (interface{}, error)
 	parser        *jwt.Parser
-	keys          Keys
+	revoked       RevocationList
+	apiKeys       APIKeyValidator
+
+	mu      sync.RWMutex
+	keys    map[string]key
+	retired map[string]retiredKey
+	active  string
 }
 
 // New creates an *Authenticator.
@@ -83,21 +138,79 @@ type Auth struct {
 // An authenticator maintains the state required to handle JWT processing.
 //
 // It requires a set of keys (Keys) for generating tokens. The algorithms
-// to use (RS256 | HS256), and the key lookup function to perform the job
-// of retrieving a public key for a given KID.
+// to use (RS256 | ES256 | EdDSA), and the key lookup function to perform
+// the job of retrieving a public key for a given KID. A nil lookup function
+// makes the authenticator resolve public keys from the keys it holds,
+// including the ones retired by Rotate. Every key must match the algorithm.
+//
+// When keys holds a single key it becomes the active signing key.
 func New(algorithm string, lookupFunc PublicKeyLookup, keys Keys) (*Auth, error) {
+	a, err := newAuth(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := a.method.(*jwt.SigningMethodHMAC); ok {
+		return nil, errors.Errorf("algorithm %s requires secrets, use NewHMAC", algorithm)
+	}
 
-	lookupKey := func(t *jwt.Token) (interface{}, error) {
-		kid, ok := t.Header["kid"]
-		if !ok {
-			return nil, errors.New("token header is missing key id (kid")
+	for kid, signer := range keys {
+		k, err := a.newKey(signer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "kid: %s", kid)
 		}
-		kidID, ok := kid.(string)
-		if !ok {
-			return nil, errors.New("kid must be string")
+		a.keys[kid] = k
+		a.active = kid
+	}
+	if len(keys) != 1 {
+		a.active = ""
+	}
+
+	lookup := a.verifyKey
+	if lookupFunc != nil {
+		lookup = func(kid string) (interface{}, error) {
+			return lookupFunc(kid)
+		}
+	}
+	a.lookupKeyFunc = newLookupKeyFunc(lookup)
+
+	return a, nil
+}
+
+// NewHMAC creates an *Authenticator for the HMAC algorithms (HS256). The
+// same secret signs and verifies tokens, so it never leaves the service
+// and is not published by JWKS.
+//
+// When secrets holds a single secret it becomes the active signing key.
+func NewHMAC(algorithm string, secrets Secrets) (*Auth, error) {
+	a, err := newAuth(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := a.method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.Errorf("algorithm %s does not use secrets, use New", algorithm)
+	}
+
+	for kid, secret := range secrets {
+		if len(secret) == 0 {
+			return nil, errors.Errorf("kid: %s: secret is empty", kid)
 		}
+		a.keys[kid] = key{sign: secret, verify: secret}
+		a.active = kid
+	}
+	if len(secrets) != 1 {
+		a.active = ""
+	}
 
-		return lookupFunc(kidID)
+	a.lookupKeyFunc = newLookupKeyFunc(a.verifyKey)
+
+	return a, nil
+}
+
+// newAuth constructs the parts of an authenticator shared by all algorithms.
+func newAuth(algorithm string) (*Auth, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, errors.Errorf("unsupported algorithm: %s", algorithm)
 	}
 
 	// Create the token parser. The Algorithm used to sign the JWT must be validated
@@ -108,38 +221,212 @@ func New(algorithm string, lookupFunc PublicKeyLookup, keys Keys) (*Auth, error)
 	}
 
 	a := Auth{
-		algorithm:     algorithm,
-		lookupKeyFunc: lookupKey,
-		parser:        &parser,
-		keys:          keys,
+		algorithm: algorithm,
+		method:    method,
+		parser:    &parser,
+		keys:      make(map[string]key),
+		retired:   make(map[string]retiredKey),
 	}
 
 	return &a, nil
 }
 
+// newLookupKeyFunc adapts a kid lookup to the signature used by the parser.
+func newLookupKeyFunc(lookup func(kid string) (interface{}, error)) func(t *jwt.Token) (interface{}, error) {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, ok := t.Header["kid"]
+		if !ok {
+			return nil, errors.New("token header is missing key id (kid")
+		}
+		kidID, ok := kid.(string)
+		if !ok {
+			return nil, errors.New("kid must be string")
+		}
+
+		return lookup(kidID)
+	}
+}
+
+// newKey checks that the private key can be used with the algorithm of
+// the authenticator.
+func (a *Auth) newKey(signer crypto.Signer) (key, error) {
+	var ok bool
+	switch m := a.method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok = signer.(*rsa.PrivateKey)
+
+	case *jwt.SigningMethodECDSA:
+		var privateKey *ecdsa.PrivateKey
+		privateKey, ok = signer.(*ecdsa.PrivateKey)
+		ok = ok && privateKey.Curve.Params().BitSize == m.CurveBits
+
+	case *jwt.SigningMethodEd25519:
+		_, ok = signer.(ed25519.PrivateKey)
+	}
+
+	if !ok {
+		return key{}, errors.Errorf("a %T can not be used with %s", signer, a.algorithm)
+	}
+
+	return key{sign: signer, verify: signer.Public()}, nil
+}
+
+// SetRevocationList sets the list used by IsRevoked. It must be called
+// before the authenticator is used to validate tokens.
+func (a *Auth) SetRevocationList(rl RevocationList) {
+	a.revoked = rl
+}
+
+// IsRevoked reports whether the access token identified by jti was revoked.
+// Without a revocation list no token is considered revoked.
+func (a *Auth) IsRevoked(ctx context.Context, traceID string, jti string) (bool, error) {
+	if a.revoked == nil {
+		return false, nil
+	}
+	return a.revoked.IsRevoked(ctx, traceID, jti)
+}
+
+// SetAPIKeyValidator sets the validator used by ValidateAPIKey. It must be
+// called before the authenticator is used to validate API keys.
+func (a *Auth) SetAPIKeyValidator(v APIKeyValidator) {
+	a.apiKeys = v
+}
+
+// ValidateAPIKey recreates the claims of the owner of an API key. Without
+// a validator every key is rejected.
+func (a *Auth) ValidateAPIKey(ctx context.Context, traceID string, key string, now time.Time) (Claims, error) {
+	if a.apiKeys == nil {
+		return Claims{}, ErrInvalidAPIKey
+	}
+	return a.apiKeys.ValidateAPIKey(ctx, traceID, key, now)
+}
+
 // AddKey adds a private key and kid to the local store.
-func (a *Auth) AddKey(privateKey *rsa.PrivateKey, kid string) {
-	a.keys[kid] = privateKey
+func (a *Auth) AddKey(privateKey crypto.Signer, kid string) error {
+	k, err := a.newKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.keys[kid] = k
+	delete(a.retired, kid)
+
+	return nil
 }
 
 // RemoveKey removes from local storage a key based on the provided kid.
+// Tokens signed by the key stop validating immediately, which is what is
+// needed when a key is compromised. Use Rotate for a graceful rotation.
 func (a *Auth) RemoveKey(kid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	delete(a.keys, kid)
+	delete(a.retired, kid)
+	if a.active == kid {
+		a.active = ""
+	}
 }
 
-// GenerateToken generates a JWT using the provided claims based on a given KID
-func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
-	method := jwt.GetSigningMethod(a.algorithm)
+// Rotate replaces the set of keys and the active signing kid in a single
+// step. Keys missing from the new set are retired: they can no longer sign
+// tokens, but the tokens they signed keep validating until retireUntil.
+func (a *Auth) Rotate(keys Keys, activeKID string, retireUntil time.Time) error {
+	if _, ok := keys[activeKID]; !ok {
+		return errors.Errorf("active kid %q is not in the key set", activeKID)
+	}
 
-	token := jwt.NewWithClaims(method, claims)
-	token.Header["kid"] = kid
+	newKeys := make(map[string]key, len(keys))
+	for kid, signer := range keys {
+		k, err := a.newKey(signer)
+		if err != nil {
+			return errors.Wrapf(err, "kid: %s", kid)
+		}
+		newKeys[kid] = k
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for kid, rk := range a.retired {
+		if _, ok := newKeys[kid]; ok || now.After(rk.until) {
+			delete(a.retired, kid)
+		}
+	}
+
+	for kid, k := range a.keys {
+		if _, ok := newKeys[kid]; !ok {
+			a.retired[kid] = retiredKey{
+				verify: k.verify,
+				until:  retireUntil,
+			}
+		}
+	}
+
+	a.keys = newKeys
+	a.active = activeKID
+
+	return nil
+}
+
+// ActiveKID returns the kid of the key used to sign new tokens.
+func (a *Auth) ActiveKID() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.active
+}
+
+// SetActiveKID designates the key used to sign new tokens.
+func (a *Auth) SetActiveKID(kid string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.keys[kid]; !ok {
+		return errors.Errorf("no key found for the specified kid: %s", kid)
+	}
+	a.active = kid
+
+	return nil
+}
+
+// verifyKey resolves the verification key of a held or retired key.
+func (a *Auth) verifyKey(kid string) (interface{}, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if k, ok := a.keys[kid]; ok {
+		return k.verify, nil
+	}
+	if rk, ok := a.retired[kid]; ok && time.Now().Before(rk.until) {
+		return rk.verify, nil
+	}
+
+	return nil, errors.Errorf("no public key found for the specified kid: %s", kid)
+}
+
+// GenerateToken generates a JWT using the provided claims based on a given KID.
+// An empty kid signs the token with the active key.
+func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
+	a.mu.RLock()
+	if kid == "" {
+		kid = a.active
+	}
+	k, ok := a.keys[kid]
+	a.mu.RUnlock()
 
-	privateKey, ok := a.keys[kid]
 	if !ok {
 		return "", errors.New("kid lookup failed")
 	}
 
-	tokenStr, err := token.SignedString(privateKey)
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = kid
+
+	tokenStr, err := token.SignedString(k.sign)
 	if err != nil {
 		return "", errors.Wrap(err, "signing token")
 	}
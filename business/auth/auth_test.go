@@ -1,13 +1,22 @@
 package auth_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 )
 
@@ -30,7 +39,7 @@ func TestAuthenticator(t *testing.T) {
 
 			// Sample kid
 			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
-			keyLookupFunc := func(publicKID string) (*rsa.PublicKey, error) {
+			keyLookupFunc := func(publicKID string) (crypto.PublicKey, error) {
 				if publicKID != keyID {
 					return nil, errors.New("no public key found")
 				}
@@ -82,3 +91,454 @@ func TestAuthenticator(t *testing.T) {
 		}
 	}
 }
+
+func TestJWKS(t *testing.T) {
+	t.Log("Given the need to publish the public keys of the authenticator.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen holding a single private key.", testID)
+		{
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a private key: %v", failed, testID, err)
+			}
+
+			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+			a, err := auth.New("RS256", nil, auth.Keys{keyID: privateKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			set := a.JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish one key: got %d", failed, testID, len(set.Keys))
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish one key.", success, testID)
+
+			jwk := set.Keys[0]
+			if jwk.KeyID != keyID || jwk.KeyType != "RSA" || jwk.Algorithm != "RS256" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the key: %+v", failed, testID, jwk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the key.", success, testID)
+
+			n, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the modulus: %v", failed, testID, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the exponent: %v", failed, testID, err)
+			}
+
+			publicKey := rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+			if !publicKey.Equal(&privateKey.PublicKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the matching public key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the matching public key.", success, testID)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	t.Log("Given the need to rotate the signing keys while tokens are in use.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen replacing the active key.", testID)
+		{
+			oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate the old key: %v", failed, testID, err)
+			}
+			newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate the new key: %v", failed, testID, err)
+			}
+
+			const oldKID, newKID = "old", "new"
+			a, err := auth.New("RS256", nil, auth.Keys{oldKID: oldKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			if got := a.ActiveKID(); got != oldKID {
+				t.Fatalf("\t%s\tTest %d:\tShould activate the only key: got %q", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould activate the only key.", success, testID)
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "0x01",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			oldToken, err := a.GenerateToken("", claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sign with the active key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to sign with the active key.", success, testID)
+
+			if err := a.Rotate(auth.Keys{newKID: newKey}, newKID, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the keys: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate the keys.", success, testID)
+
+			if _, err := a.ValidateToken(oldToken); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould still validate a token signed by the retired key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould still validate a token signed by the retired key.", success, testID)
+
+			if _, err := a.GenerateToken(oldKID, claims); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT sign with the retired key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT sign with the retired key.", success, testID)
+
+			newToken, err := a.GenerateToken("", claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sign with the new key: %v", failed, testID, err)
+			}
+			if _, err := a.ValidateToken(newToken); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould validate a token signed by the new key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould validate a token signed by the new key.", success, testID)
+
+			if got := len(a.JWKS().Keys); got != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the new and the retired keys: got %d", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the new and the retired keys.", success, testID)
+
+			if err := a.Rotate(auth.Keys{newKID: newKey}, newKID, time.Now().Add(-time.Second)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the keys again: %v", failed, testID, err)
+			}
+			if _, err := a.ValidateToken(oldToken); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the first retirement deadline: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the first retirement deadline.", success, testID)
+
+			a.RemoveKey(oldKID)
+			if _, err := a.ValidateToken(oldToken); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject tokens of a removed key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject tokens of a removed key.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen rotating while tokens are issued and validated.", testID)
+		{
+			keys := make([]*rsa.PrivateKey, 2)
+			for i := range keys {
+				privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a key: %v", failed, testID, err)
+				}
+				keys[i] = privateKey
+			}
+
+			a, err := auth.New("RS256", nil, auth.Keys{"0": keys[0]})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "0x01",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				},
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 8)
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 20; i++ {
+						token, err := a.GenerateToken("", claims)
+						if err != nil {
+							errs <- err
+							return
+						}
+						if _, err := a.ValidateToken(token); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}()
+			}
+
+			for i := 0; i < 20; i++ {
+				kid := strconv.Itoa(i % 2)
+				if err := a.Rotate(auth.Keys{kid: keys[i%2]}, kid, time.Now().Add(time.Hour)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the keys: %v", failed, testID, err)
+				}
+			}
+
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatalf("\t%s\tTest %d:\tShould keep issuing valid tokens during rotation: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep issuing valid tokens during rotation.", success, testID)
+		}
+	}
+}
+
+func TestAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating rsa key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+
+	const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+
+	tt := []struct {
+		algorithm string
+		new       func() (*auth.Auth, error)
+		kty       string
+	}{
+		{
+			algorithm: "RS256",
+			new:       func() (*auth.Auth, error) { return auth.New("RS256", nil, auth.Keys{keyID: rsaKey}) },
+			kty:       "RSA",
+		},
+		{
+			algorithm: "ES256",
+			new:       func() (*auth.Auth, error) { return auth.New("ES256", nil, auth.Keys{keyID: ecdsaKey}) },
+			kty:       "EC",
+		},
+		{
+			algorithm: "EdDSA",
+			new:       func() (*auth.Auth, error) { return auth.New("EdDSA", nil, auth.Keys{keyID: ed25519Key}) },
+			kty:       "OKP",
+		},
+		{
+			algorithm: "HS256",
+			new:       func() (*auth.Auth, error) { return auth.NewHMAC("HS256", auth.Secrets{keyID: []byte("secret")}) },
+		},
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "0x01",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	t.Log("Given the need to sign tokens with different algorithms.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen using %s.", testID, tst.algorithm)
+			{
+				a, err := tst.new()
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to create an authenticator.", success, testID)
+
+				token, err := a.GenerateToken("", claims)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to generate a JWT.", success, testID)
+
+				parsedClaims, err := a.ValidateToken(token)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the claims: %v", failed, testID, err)
+				}
+				if parsedClaims.Subject != claims.Subject {
+					t.Fatalf("\t%s\tTest %d:\tShould get back the subject: got %q", failed, testID, parsedClaims.Subject)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to parse the claims.", success, testID)
+
+				set := a.JWKS()
+				switch tst.kty {
+				case "":
+					if len(set.Keys) != 0 {
+						t.Fatalf("\t%s\tTest %d:\tShould NOT publish the secret: %+v", failed, testID, set.Keys)
+					}
+					t.Logf("\t%s\tTest %d:\tShould NOT publish the secret.", success, testID)
+
+				default:
+					if len(set.Keys) != 1 || set.Keys[0].KeyType != tst.kty || set.Keys[0].Algorithm != tst.algorithm {
+						t.Fatalf("\t%s\tTest %d:\tShould publish a %s key: %+v", failed, testID, tst.kty, set.Keys)
+					}
+					t.Logf("\t%s\tTest %d:\tShould publish a %s key.", success, testID, tst.kty)
+				}
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen the keys do not match the algorithm.", testID)
+		{
+			if _, err := auth.New("ES256", nil, auth.Keys{keyID: rsaKey}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept an RSA key for ES256.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept an RSA key for ES256.", success, testID)
+
+			p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a P-384 key: %v", failed, testID, err)
+			}
+			if _, err := auth.New("ES256", nil, auth.Keys{keyID: p384}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a P-384 key for ES256.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a P-384 key for ES256.", success, testID)
+
+			if _, err := auth.New("HS256", nil, auth.Keys{keyID: rsaKey}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould require secrets for HS256.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require secrets for HS256.", success, testID)
+
+			if _, err := auth.New("XX999", nil, auth.Keys{keyID: rsaKey}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an unknown algorithm.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an unknown algorithm.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a token is signed with a different algorithm.", testID)
+		{
+			hs, err := auth.NewHMAC("HS256", auth.Secrets{keyID: []byte("secret")})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+			token, err := hs.GenerateToken("", claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			rs, err := auth.New("RS256", nil, auth.Keys{keyID: rsaKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+			if _, err := rs.ValidateToken(token); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a token signed with another algorithm.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a token signed with another algorithm.", success, testID)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	t.Log("Given the need to authorize actions with a declarative policy.")
+	{
+		for testID, file := range []string{"testdata/policy.yaml", "testdata/policy.json"} {
+			t.Logf("\tTest %d:\tWhen loading the policy from %s.", testID, file)
+			{
+				p, err := auth.LoadPolicy(file)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to load the policy: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to load the policy.", success, testID)
+
+				var denials []string
+				p.SetAuditor(func(claims auth.Claims, action string, resource *auth.Resource) {
+					denials = append(denials, claims.Subject+" "+action)
+				})
+
+				admin := auth.Claims{Roles: []string{auth.RoleAdmin}}
+				admin.Subject = "admin"
+				operator := auth.Claims{Roles: []string{auth.RoleOperator}}
+				operator.Subject = "operator"
+				user := auth.Claims{Roles: []string{"USER"}}
+				user.Subject = "user"
+
+				tt := []struct {
+					claims   auth.Claims
+					action   string
+					resource auth.Resource
+					exp      bool
+				}{
+					{admin, auth.ActionUserDelete, auth.Resource{OwnerID: "user"}, true},
+					{operator, auth.ActionProductDelete, auth.Resource{OwnerID: "user"}, true},
+					{operator, auth.ActionUserRead, auth.Resource{OwnerID: "operator"}, true},
+					{operator, auth.ActionUserRead, auth.Resource{OwnerID: "user"}, false},
+					{user, auth.ActionProductRead, auth.Resource{OwnerID: "operator"}, true},
+					{user, auth.ActionProductUpdate, auth.Resource{OwnerID: "user"}, true},
+					{user, auth.ActionProductUpdate, auth.Resource{OwnerID: "operator"}, false},
+					{user, auth.ActionProductDelete, auth.Resource{OwnerID: "user"}, false},
+					{user, auth.ActionUserRead, auth.Resource{}, false},
+				}
+
+				for _, tst := range tt {
+					if got := p.Can(tst.claims, tst.action, tst.resource); got != tst.exp {
+						t.Fatalf("\t%s\tTest %d:\tShould decide %s %s on %q: exp %v, got %v", failed, testID, tst.claims.Subject, tst.action, tst.resource.OwnerID, tst.exp, got)
+					}
+				}
+				t.Logf("\t%s\tTest %d:\tShould decide every action as expected.", success, testID)
+
+				scoped := admin
+				scoped.Scopes = []string{"product:read", "product:update:own"}
+				if !p.Can(scoped, auth.ActionProductRead, auth.Resource{}) ||
+					!p.Can(scoped, auth.ActionProductUpdate, auth.Resource{OwnerID: "admin"}) ||
+					p.Can(scoped, auth.ActionProductUpdate, auth.Resource{OwnerID: "user"}) ||
+					p.Can(scoped, auth.ActionUserDelete, auth.Resource{}) {
+					t.Fatalf("\t%s\tTest %d:\tShould limit scoped claims to their scopes.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould limit scoped claims to their scopes.", success, testID)
+
+				if !p.CanAny(user, auth.ActionProductUpdate) || p.CanAny(user, auth.ActionProductDelete) {
+					t.Fatalf("\t%s\tTest %d:\tShould count scoped grants when the target is unknown.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould count scoped grants when the target is unknown.", success, testID)
+
+				exp := []string{
+					"operator user:read",
+					"user product:update",
+					"user product:delete",
+					"user user:read",
+					"admin product:update",
+					"admin user:delete",
+					"user product:delete",
+				}
+				if diff := cmp.Diff(exp, denials); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould audit every denial. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould audit every denial.", success, testID)
+
+				for _, tst := range tt {
+					if got := p.Allows(tst.claims, tst.action, tst.resource); got != tst.exp {
+						t.Fatalf("\t%s\tTest %d:\tShould filter %s %s on %q like Can: exp %v, got %v", failed, testID, tst.claims.Subject, tst.action, tst.resource.OwnerID, tst.exp, got)
+					}
+				}
+				if len(denials) != len(exp) {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT audit what is filtered out: %v", failed, testID, denials[len(exp):])
+				}
+				t.Logf("\t%s\tTest %d:\tShould filter like Can without auditing.", success, testID)
+			}
+		}
+
+		testID := 2
+		t.Logf("\tTest %d:\tWhen the policy is malformed.", testID)
+		{
+			for _, action := range []string{"user", "user:read:mine", "user::read", ""} {
+				if _, err := auth.NewPolicy(map[string][]string{auth.RoleAdmin: {action}}); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the action %q.", failed, testID, action)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject malformed actions.", success, testID)
+
+			for _, action := range []string{"user:list:self", "user:create:self", "audit:list:own", "sale:create:own"} {
+				if _, err := auth.NewPolicy(map[string][]string{auth.AnyRole: {action}}); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the scoped action %q.", failed, testID, action)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject scoping actions whose target has no owner.", success, testID)
+		}
+	}
+}
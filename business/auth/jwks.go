@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// JWK represents the public part of a signing key as described by RFC 7517.
+// RSA keys are described by their modulus and exponent, EC and Ed25519 keys
+// by their curve and coordinates.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n,omitempty"`
+	Exponent  string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS represents a JSON Web Key Set. It is what verifiers in other services
+// fetch to validate the tokens issued by this authenticator.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every private key held by the
+// authenticator, plus the retired keys whose tokens may still be valid,
+// sorted by kid. HMAC secrets are never published.
+func (a *Auth) JWKS() JWKS {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	publicKeys := make(map[string]interface{}, len(a.keys)+len(a.retired))
+	now := time.Now()
+	for kid, rk := range a.retired {
+		if now.Before(rk.until) {
+			publicKeys[kid] = rk.verify
+		}
+	}
+	for kid, k := range a.keys {
+		publicKeys[kid] = k.verify
+	}
+
+	kids := make([]string, 0, len(publicKeys))
+	for kid := range publicKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKS{
+		Keys: make([]JWK, 0, len(kids)),
+	}
+	for _, kid := range kids {
+		if jwk, ok := newJWK(kid, a.algorithm, publicKeys[kid]); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
+
+// newJWK encodes a public key using the base64url representation of its
+// parameters. It reports false for keys that must not be published.
+func newJWK(kid string, algorithm string, publicKey interface{}) (JWK, bool) {
+	jwk := JWK{
+		KeyID:     kid,
+		Use:       "sig",
+		Algorithm: algorithm,
+	}
+
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.Modulus = encode(pk.N.Bytes())
+		jwk.Exponent = encode(big.NewInt(int64(pk.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		// Coordinates are padded to the size of the curve as required by RFC 7518.
+		size := (pk.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pk.Curve.Params().Name
+		jwk.X = encode(pk.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(pk.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encode(pk)
+
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// encode returns the unpadded base64url form of b.
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
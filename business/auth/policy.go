@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// These are the actions checked against a Policy. An action is made of a
+// resource and a verb. Policies may grant an action for any target, or
+// only for the targets owned by the subject by appending a scope: for
+// instance "product:delete:own" or, reading better for users,
+// "user:update:self". Changing the roles of a user takes ActionUserGrant on
+// top of ActionUserUpdate. Actions also name the events of the audit log,
+// which is how ActionUserPurge, only performed by the admin tooling, is used.
+const (
+	ActionUserCreate  = "user:create"
+	ActionUserRead    = "user:read"
+	ActionUserList    = "user:list"
+	ActionUserUpdate  = "user:update"
+	ActionUserDelete  = "user:delete"
+	ActionUserUnlock  = "user:unlock"
+	ActionUserRestore = "user:restore"
+	ActionUserGrant   = "user:grant"
+	ActionUserPurge   = "user:purge"
+
+	ActionProductCreate = "product:create"
+	ActionProductRead   = "product:read"
+	ActionProductList   = "product:list"
+	ActionProductUpdate = "product:update"
+	ActionProductDelete = "product:delete"
+
+	ActionSaleCreate = "sale:create"
+	ActionSaleRead   = "sale:read"
+
+	ActionAPIKeyCreate = "apikey:create"
+	ActionAPIKeyList   = "apikey:list"
+	ActionAPIKeyRevoke = "apikey:revoke"
+
+	ActionAuditList = "audit:list"
+)
+
+// These are the scopes restricting a grant to the targets owned by the
+// subject. Both have the same meaning.
+const (
+	ScopeOwn  = "own"
+	ScopeSelf = "self"
+)
+
+// AnyRole is the role key granting actions to every authenticated subject.
+const AnyRole = "*"
+
+// ownedActions are the actions checked against the owner of their target.
+// A scoped grant of any other action would pass Require with nothing left
+// to check the owner, so policies can not scope them.
+var ownedActions = map[string]bool{
+	ActionUserRead:      true,
+	ActionUserUpdate:    true,
+	ActionUserDelete:    true,
+	ActionUserUnlock:    true,
+	ActionUserRestore:   true,
+	ActionProductUpdate: true,
+	ActionProductDelete: true,
+	ActionAPIKeyCreate:  true,
+	ActionAPIKeyList:    true,
+	ActionAPIKeyRevoke:  true,
+}
+
+// Resource describes the target of an action. OwnerID is the subject owning
+// it: the user itself for a user record, the creator of a product.
+type Resource struct {
+	OwnerID string
+}
+
+// Auditor is called with every request denied by a Policy. A nil resource
+// means the action was checked without a target.
+type Auditor func(claims Claims, action string, resource *Resource)
+
+// Policy maps the actions of the system to the roles allowed to perform
+// them. It is safe for concurrent use once constructed.
+type Policy struct {
+	grants  map[string]map[string]struct{}
+	auditor Auditor
+}
+
+// policyFile is the document a policy is loaded from, for example:
+//
+//	roles:
+//	  ADMIN: ["*"]
+//	  "*": ["user:read:self", "product:update:own"]
+type policyFile struct {
+	Roles map[string][]string `json:"roles" yaml:"roles"`
+}
+
+// NewPolicy constructs a Policy from the actions granted to each role. An
+// action of "*" grants everything and "<resource>:*" every action on the
+// resource. The AnyRole key grants actions to every authenticated subject.
+// Only the actions whose target has an owner can be scoped.
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	p := Policy{
+		grants: make(map[string]map[string]struct{}, len(roles)),
+	}
+
+	for role, actions := range roles {
+		set := make(map[string]struct{}, len(actions))
+		for _, action := range actions {
+			if err := ValidateAction(action); err != nil {
+				return nil, errors.Wrapf(err, "role %s", role)
+			}
+			if i := strings.LastIndex(action, ":"); strings.Count(action, ":") == 2 && !ownedActions[action[:i]] {
+				return nil, errors.Errorf("role %s: action %q can not be scoped, its target has no owner", role, action)
+			}
+			set[action] = struct{}{}
+		}
+		p.grants[role] = set
+	}
+
+	return &p, nil
+}
+
+// DefaultPolicy returns the policy used when none is configured. Admins can
+// do everything, and any other user can read and update their own record,
+// manage their own products and buy products. Only admins can grant roles.
+func DefaultPolicy() *Policy {
+	p, err := NewPolicy(map[string][]string{
+		RoleAdmin: {"*"},
+		AnyRole: {
+			ActionUserRead + ":" + ScopeSelf,
+			ActionUserUpdate + ":" + ScopeSelf,
+			ActionProductCreate,
+			ActionProductRead,
+			ActionProductList,
+			ActionProductUpdate + ":" + ScopeOwn,
+			ActionProductDelete + ":" + ScopeOwn,
+			ActionSaleCreate,
+			ActionSaleRead,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// LoadPolicy reads a policy from a YAML or a JSON file, chosen by the file
+// extension.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading policy file")
+	}
+
+	var pf policyFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &pf)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &pf)
+	default:
+		return nil, errors.Errorf("unsupported policy file extension %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding policy file")
+	}
+
+	return NewPolicy(pf.Roles)
+}
+
+// SetAuditor sets the function called with every denied request. It must be
+// called before the policy is used.
+func (p *Policy) SetAuditor(auditor Auditor) {
+	p.auditor = auditor
+}
+
+// Can reports whether the claims allow the action on the resource. Grants
+// scoped to "own" or "self" only apply when the subject owns the resource.
+// Claims carrying scopes are also limited to the actions the scopes match.
+func (p *Policy) Can(claims Claims, action string, resource Resource) bool {
+	owned := resource.OwnerID != "" && resource.OwnerID == claims.Subject
+	if p.allowed(claims, action, owned) {
+		return true
+	}
+
+	p.deny(claims, action, &resource)
+	return false
+}
+
+// CanAny reports whether the claims allow the action on at least some
+// resource, counting scoped grants. It is meant for checks done before the
+// target is known, which must be followed by a call to Can.
+func (p *Policy) CanAny(claims Claims, action string) bool {
+	if p.allowed(claims, action, true) {
+		return true
+	}
+
+	p.deny(claims, action, nil)
+	return false
+}
+
+// Allows is like Can, but denials are not reported to the auditor. It is
+// meant for filtering what the claims are shown, such as the events of a
+// stream, where being left out of a target is not a refused request.
+func (p *Policy) Allows(claims Claims, action string, resource Resource) bool {
+	owned := resource.OwnerID != "" && resource.OwnerID == claims.Subject
+	return p.allowed(claims, action, owned)
+}
+
+// allowed looks for a grant of the action to any of the roles of the claims,
+// and when the claims are scoped, for a scope matching the action too.
+func (p *Policy) allowed(claims Claims, action string, owned bool) bool {
+	candidates := []string{"*", action}
+	if i := strings.Index(action, ":"); i > 0 {
+		candidates = append(candidates, action[:i]+":*")
+	}
+	if owned {
+		candidates = append(candidates, action+":"+ScopeOwn, action+":"+ScopeSelf)
+	}
+
+	if len(claims.Scopes) > 0 && !matches(claims.Scopes, candidates) {
+		return false
+	}
+
+	roles := append([]string{AnyRole}, claims.Roles...)
+	for _, role := range roles {
+		grants, ok := p.grants[role]
+		if !ok {
+			continue
+		}
+		for _, candidate := range candidates {
+			if _, ok := grants[candidate]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matches reports whether any of the actions is one of the candidates.
+func matches(actions []string, candidates []string) bool {
+	for _, action := range actions {
+		for _, candidate := range candidates {
+			if action == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deny reports a denied request to the auditor.
+func (p *Policy) deny(claims Claims, action string, resource *Resource) {
+	if p.auditor != nil {
+		p.auditor(claims, action, resource)
+	}
+}
+
+// ValidateAction checks an action has the form "*", "<resource>:*",
+// "<resource>:<verb>" or "<resource>:<verb>:<scope>".
+func ValidateAction(action string) error {
+	if action == "*" {
+		return nil
+	}
+
+	parts := strings.Split(action, ":")
+	for _, part := range parts {
+		if part == "" {
+			return errors.Errorf("malformed action %q", action)
+		}
+	}
+
+	switch {
+	case len(parts) == 2:
+		return nil
+	case len(parts) == 3 && (parts[2] == ScopeOwn || parts[2] == ScopeSelf):
+		return nil
+	}
+
+	return errors.Errorf("malformed action %q", action)
+}
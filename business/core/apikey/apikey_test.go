@@ -0,0 +1,170 @@
+package apikey_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/apikey"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditmem"
+	"github.com/danielmbirochi/go-sample-service/business/data/schema"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestAPIKey(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	// Users can manage their own keys, admins the keys of everyone. Events
+	// are recorded in memory, to check every change is audited.
+	policy, err := auth.NewPolicy(map[string][]string{
+		auth.RoleAdmin: {"*"},
+		auth.AnyRole:   {"apikey:create:own", "apikey:list:own", "apikey:revoke:own"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := auditmem.NewStore()
+	as := apikey.New(log, db, policy, func(database.Executor) audit.Storer {
+		return events
+	})
+
+	// Seeded admin and regular user.
+	admin := auth.Claims{Roles: []string{auth.RoleAdmin, "USER"}}
+	admin.Subject = "5cf37266-3473-4006-984f-9325122678b7"
+	owner := auth.Claims{Roles: []string{"USER"}}
+	owner.Subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	t.Log("Given the need to work with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single key.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			userID := owner.Subject
+
+			if err := schema.DeleteAll(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete all data : %s.", tests.Failed, testID, err)
+			}
+			if err := schema.Seed(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to seed the database : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to seed the database.", tests.Success, testID)
+
+			expires := now.Add(24 * time.Hour)
+			nk := apikey.NewAPIKey{
+				UserID:      userID,
+				Name:        "reporting",
+				Scopes:      []string{auth.ActionProductRead},
+				DateExpires: &expires,
+			}
+
+			other := nk
+			other.UserID = admin.Subject
+			if _, _, err := as.Create(ctx, traceID, owner, other, now); errors.Cause(err) != apikey.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create a key for another user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a key for another user.", tests.Success, testID)
+
+			ak, key, err := as.Create(ctx, traceID, owner, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a key : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a key.", tests.Success, testID)
+
+			claims, err := as.ValidateAPIKey(ctx, traceID, key, now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to validate the key : %s.", tests.Failed, testID, err)
+			}
+			if claims.Subject != userID || claims.ID != ak.ID || len(claims.Scopes) != 1 || claims.Scopes[0] != auth.ActionProductRead {
+				t.Fatalf("\t%s\tTest %d:\tShould get the claims of the key owner : %+v.", tests.Failed, testID, claims)
+			}
+			if !claims.HasRole("USER") {
+				t.Fatalf("\t%s\tTest %d:\tShould get the roles of the key owner : %v.", tests.Failed, testID, claims.Roles)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the claims of the key owner.", tests.Success, testID)
+
+			if _, err := as.List(ctx, traceID, owner, ""); errors.Cause(err) != apikey.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to list the keys of every user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to list the keys of every user.", tests.Success, testID)
+
+			keys, err := as.List(ctx, traceID, owner, userID)
+			if err != nil || len(keys) != 1 || keys[0].DateLastUsed == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list the key with its last use : %+v %v.", tests.Failed, testID, keys, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the key with its last use.", tests.Success, testID)
+
+			if _, err := as.ValidateAPIKey(ctx, traceID, key, expires); errors.Cause(err) != auth.ErrInvalidAPIKey {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT validate an expired key : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT validate an expired key.", tests.Success, testID)
+
+			intruder := owner
+			intruder.Subject = "b4a0ecd6-5e28-4c67-a5a6-7f0d1f9ad0c2"
+			if err := as.Revoke(ctx, traceID, intruder, ak.ID, now); errors.Cause(err) != apikey.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to revoke the key of another user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to revoke the key of another user.", tests.Success, testID)
+
+			if err := as.Revoke(ctx, traceID, admin, ak.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the key : %s.", tests.Failed, testID, err)
+			}
+			if _, err := as.ValidateAPIKey(ctx, traceID, key, now.Add(time.Minute)); errors.Cause(err) != auth.ErrInvalidAPIKey {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT validate a revoked key : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT validate a revoked key.", tests.Success, testID)
+
+			var got []string
+			for _, ev := range events.Events() {
+				if ev.TargetType != apikey.AuditTarget || ev.TargetID != ak.ID {
+					t.Fatalf("\t%s\tTest %d:\tShould record the events of the key : %+v.", tests.Failed, testID, ev)
+				}
+				for _, c := range ev.Changes {
+					if c.Field == "key_hash" {
+						t.Fatalf("\t%s\tTest %d:\tShould NOT record the hash of the key : %+v.", tests.Failed, testID, ev)
+					}
+				}
+				got = append(got, ev.ActorID+" "+ev.Action)
+			}
+			exp := []string{owner.Subject + " " + auth.ActionAPIKeyCreate, admin.Subject + " " + auth.ActionAPIKeyRevoke}
+			if diff := cmp.Diff(exp, got); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould record every change in the audit log. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record every change in the audit log.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen issuing invalid keys.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nk := apikey.NewAPIKey{
+				UserID: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				Name:   "broken",
+				Scopes: []string{"product"},
+			}
+			if _, _, err := as.Create(ctx, traceID, owner, nk, now); errors.Cause(err) != apikey.ErrInvalidScope {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT issue a key with a malformed scope : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT issue a key with a malformed scope.", tests.Success, testID)
+
+			nk.Scopes = []string{auth.ActionProductRead}
+			nk.UserID = "b4a0ecd6-5e28-4c67-a5a6-7f0d1f9ad0c2"
+			if _, _, err := as.Create(ctx, traceID, admin, nk, now); errors.Cause(err) != apikey.ErrUserNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT issue a key for an unknown user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT issue a key for an unknown user.", tests.Success, testID)
+		}
+	}
+}
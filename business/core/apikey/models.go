@@ -0,0 +1,31 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey represents a long-lived credential issued to a machine client. The
+// key acts on behalf of its owner, limited to its scopes.
+type APIKey struct {
+	ID           string         `db:"key_id" json:"id"`
+	UserID       string         `db:"user_id" json:"user_id"`
+	Name         string         `db:"name" json:"name"`
+	Prefix       string         `db:"prefix" json:"prefix"`
+	KeyHash      string         `db:"key_hash" json:"-"`
+	Scopes       pq.StringArray `db:"scopes" json:"scopes"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateExpires  *time.Time     `db:"date_expires" json:"date_expires,omitempty"`
+	DateLastUsed *time.Time     `db:"date_last_used" json:"date_last_used,omitempty"`
+	DateRevoked  *time.Time     `db:"date_revoked" json:"date_revoked,omitempty"`
+}
+
+// NewAPIKey contains information needed to issue a new APIKey. Scopes are
+// policy actions such as "product:create" or "product:*".
+type NewAPIKey struct {
+	UserID      string     `json:"user_id" validate:"required,uuid"`
+	Name        string     `json:"name" validate:"required"`
+	Scopes      []string   `json:"scopes" validate:"required,min=1"`
+	DateExpires *time.Time `json:"date_expires"`
+}
@@ -0,0 +1,307 @@
+// Package apikey contains usecases for issuing, listing and revoking API
+// keys, and for authenticating the machine clients using them.
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/danielmbirochi/go-sample-service/foundation/token"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// Prefix starts every API key, so leaked keys are easy to spot.
+const Prefix = "sk_"
+
+var (
+	// ErrNotFound is used when a specific APIKey is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form (UUID).
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrUserNotFound occurs when a key is issued for a user that does not exist.
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrInvalidScope occurs when a scope is not a well formed policy action.
+	ErrInvalidScope = errors.New("scope is not a valid action")
+
+	// ErrInvalidExpiry occurs when a key is issued with an expiry in the past.
+	ErrInvalidExpiry = errors.New("expiry date must be in the future")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// AuditTarget is the type of target of the audit events about API keys.
+const AuditTarget = "apikey"
+
+type APIKeyService struct {
+	db     database.Executor
+	policy *auth.Policy
+	audits audit.TranStorer
+	log    *zap.SugaredLogger
+}
+
+// New is a factory method for constructing api key service. Passing a
+// *sqlx.Tx makes the service join that transaction. The policy decides who
+// can manage the keys of a user, and audits where the changes are recorded.
+func New(log *zap.SugaredLogger, db database.Executor, policy *auth.Policy, audits audit.TranStorer) APIKeyService {
+	return APIKeyService{
+		db:     db,
+		policy: policy,
+		audits: audits,
+		log:    log,
+	}
+}
+
+// Create issues a new API key on behalf of the claims subject and returns it
+// along with its raw value. Only a hash of the key is stored, so the raw
+// value can not be recovered later.
+func (as APIKeyService) Create(ctx context.Context, traceID string, claims auth.Claims, nk NewAPIKey, now time.Time) (APIKey, string, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.apikey.Create")
+	defer span.End()
+
+	if !as.policy.Can(claims, auth.ActionAPIKeyCreate, auth.Resource{OwnerID: nk.UserID}) {
+		return APIKey{}, "", ErrForbidden
+	}
+
+	for _, scope := range nk.Scopes {
+		if err := auth.ValidateAction(scope); err != nil {
+			return APIKey{}, "", ErrInvalidScope
+		}
+	}
+	if nk.DateExpires != nil && !nk.DateExpires.After(now) {
+		return APIKey{}, "", ErrInvalidExpiry
+	}
+
+	secret, err := token.New()
+	if err != nil {
+		return APIKey{}, "", errors.Wrap(err, "generating api key")
+	}
+	key := Prefix + secret
+
+	ak := APIKey{
+		ID:          uuid.New().String(),
+		UserID:      nk.UserID,
+		Name:        nk.Name,
+		Prefix:      key[:len(Prefix)+6],
+		KeyHash:     token.Hash(key),
+		Scopes:      nk.Scopes,
+		DateCreated: now.UTC(),
+	}
+	if nk.DateExpires != nil {
+		expires := nk.DateExpires.UTC()
+		ak.DateExpires = &expires
+	}
+
+	const q = `
+	INSERT INTO api_keys
+		(key_id, user_id, name, prefix, key_hash, scopes, date_created, date_expires)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	as.log.Infof("%s : %s : query : %s", traceID, "apikey.Create",
+		database.Log(q, ak.ID, ak.UserID, ak.Name, ak.Prefix, ak.KeyHash, ak.Scopes, ak.DateCreated, ak.DateExpires),
+	)
+
+	err = database.WithinTran(ctx, as.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, q, ak.ID, ak.UserID, ak.Name, ak.Prefix, ak.KeyHash, ak.Scopes, ak.DateCreated, ak.DateExpires); err != nil {
+			if pqErr, ok := errors.Cause(err).(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrUserNotFound
+			}
+			return errors.Wrap(err, "inserting api key")
+		}
+		return as.record(ctx, tx, traceID, claims, auth.ActionAPIKeyCreate, ak.ID, nil, ak, now)
+	})
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	return ak, key, nil
+}
+
+// List retrieves the API keys, newest first. An empty userID lists the keys
+// of every user, which takes a grant that is not scoped to the subject.
+func (as APIKeyService) List(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]APIKey, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.apikey.List")
+	defer span.End()
+
+	if userID != "" {
+		if _, err := uuid.Parse(userID); err != nil {
+			return nil, ErrInvalidID
+		}
+	}
+
+	if !as.policy.Can(claims, auth.ActionAPIKeyList, auth.Resource{OwnerID: userID}) {
+		return nil, ErrForbidden
+	}
+
+	const q = `
+	SELECT * 
+		FROM api_keys 
+			WHERE $1 = '' OR user_id::TEXT = $1
+		ORDER BY date_created DESC, key_id
+	`
+
+	as.log.Infof("%s : %s : query : %s", traceID, "apikey.List",
+		database.Log(q, userID),
+	)
+
+	keys := []APIKey{}
+	if err := as.db.SelectContext(ctx, &keys, q, userID); err != nil {
+		return nil, errors.Wrap(err, "selecting api keys")
+	}
+
+	return keys, nil
+}
+
+// Revoke disables the API key identified by the given ID. Revoking a key
+// twice keeps the first revocation date.
+func (as APIKeyService) Revoke(ctx context.Context, traceID string, claims auth.Claims, id string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.apikey.Revoke")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	return database.WithinTran(ctx, as.db, func(tx *sqlx.Tx) error {
+		const qGet = `
+		SELECT * 
+			FROM api_keys 
+				WHERE key_id = $1
+		FOR UPDATE
+		`
+
+		as.log.Infof("%s : %s : query : %s", traceID, "apikey.Revoke",
+			database.Log(qGet, id),
+		)
+
+		var old APIKey
+		if err := tx.GetContext(ctx, &old, qGet, id); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return errors.Wrapf(err, "selecting api key %q", id)
+		}
+
+		if !as.policy.Can(claims, auth.ActionAPIKeyRevoke, auth.Resource{OwnerID: old.UserID}) {
+			return ErrForbidden
+		}
+		if old.DateRevoked != nil {
+			return nil
+		}
+
+		ak := old
+		revoked := now.UTC()
+		ak.DateRevoked = &revoked
+
+		const q = `
+		UPDATE api_keys
+			SET
+				"date_revoked" = $2
+			WHERE key_id = $1
+		`
+
+		as.log.Infof("%s : %s : query : %s", traceID, "apikey.Revoke",
+			database.Log(q, id, revoked),
+		)
+
+		if _, err := tx.ExecContext(ctx, q, id, revoked); err != nil {
+			return errors.Wrapf(err, "revoking api key %s", id)
+		}
+		return as.record(ctx, tx, traceID, claims, auth.ActionAPIKeyRevoke, id, old, ak, now)
+	})
+}
+
+// ValidateAPIKey finds the API key, records its use and returns the claims
+// of its owner restricted to the key scopes. It implements
+// auth.APIKeyValidator, returning auth.ErrInvalidAPIKey for a key that is
+// unknown, expired or revoked.
+func (as APIKeyService) ValidateAPIKey(ctx context.Context, traceID string, key string, now time.Time) (auth.Claims, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.apikey.ValidateAPIKey")
+	defer span.End()
+
+	const q = `
+	UPDATE api_keys AS k
+		SET
+			"date_last_used" = $2
+		FROM users AS u
+		WHERE 
+			k.user_id = u.user_id AND
+			u.date_deleted IS NULL AND
+			k.key_hash = $1 AND
+			k.date_revoked IS NULL AND
+			(k.date_expires IS NULL OR k.date_expires > $2)
+	RETURNING k.key_id, k.user_id, k.scopes, k.date_expires, u.roles
+	`
+
+	hash := token.Hash(key)
+	as.log.Infof("%s : %s : query : %s", traceID, "apikey.ValidateAPIKey",
+		database.Log(q, hash, now.UTC()),
+	)
+
+	var row struct {
+		ID          string         `db:"key_id"`
+		UserID      string         `db:"user_id"`
+		Scopes      pq.StringArray `db:"scopes"`
+		DateExpires *time.Time     `db:"date_expires"`
+		Roles       pq.StringArray `db:"roles"`
+	}
+	if err := as.db.GetContext(ctx, &row, q, hash, now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return auth.Claims{}, auth.ErrInvalidAPIKey
+		}
+		return auth.Claims{}, errors.Wrap(err, "selecting api key")
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       row.ID,
+			Issuer:   "go-sample-service",
+			Subject:  row.UserID,
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+		Roles:  row.Roles,
+		Scopes: row.Scopes,
+	}
+	if row.DateExpires != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*row.DateExpires)
+	}
+
+	return claims, nil
+}
+
+// record adds an event about a change to an API key to the audit log, within
+// the transaction of the change. A nil old key stands for a key being
+// issued. The hash of the key is never recorded.
+func (as APIKeyService) record(ctx context.Context, tx *sqlx.Tx, traceID string, claims auth.Claims, action string, id string, old interface{}, new interface{}, now time.Time) error {
+	changes, err := audit.Diff(old, new)
+	if err != nil {
+		return err
+	}
+
+	ne := audit.NewEvent{
+		ActorID:    claims.Subject,
+		Action:     action,
+		TargetType: AuditTarget,
+		TargetID:   id,
+		Changes:    changes,
+	}
+	if _, err := audit.New(as.log, as.audits(tx)).Record(ctx, traceID, ne, now); err != nil {
+		return err
+	}
+
+	return nil
+}
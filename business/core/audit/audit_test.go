@@ -0,0 +1,133 @@
+package audit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditmem"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/logger"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestDiff(t *testing.T) {
+	type target struct {
+		Name    string   `json:"name"`
+		Roles   []string `json:"roles"`
+		Secret  string   `json:"-"`
+		Updated int      `json:"updated"`
+	}
+
+	t.Log("Given the need to describe the changes made to a target.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen comparing two versions of a target.", testID)
+		{
+			old := target{Name: "Gopher", Roles: []string{"ADMIN"}, Secret: "a", Updated: 1}
+			new := target{Name: "Gopher Jr", Roles: []string{"ADMIN"}, Secret: "b", Updated: 2}
+
+			changes, err := audit.Diff(old, new, "updated")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to diff the target : %s.", tests.Failed, testID, err)
+			}
+			changes = changes.Redact("secret")
+
+			want := audit.Changes{
+				{Field: "name", Old: "Gopher", New: "Gopher Jr"},
+				{Field: "secret", Old: audit.Redacted, New: audit.Redacted},
+			}
+			if diff := cmp.Diff(changes, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the changed fields only. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the changed fields only.", tests.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen comparing a created target.", testID)
+		{
+			changes, err := audit.Diff(nil, target{Name: "Gopher"})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to diff the target : %s.", tests.Failed, testID, err)
+			}
+
+			want := audit.Changes{
+				{Field: "name", Old: nil, New: "Gopher"},
+				{Field: "updated", Old: nil, New: float64(0)},
+			}
+			if diff := cmp.Diff(changes, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get every field as new. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get every field as new.", tests.Success, testID)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	as := audit.New(log, auditmem.NewStore())
+
+	t.Log("Given the need to record and review audit events.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			if _, err := as.Record(ctx, traceID, audit.NewEvent{Action: "user:create"}, now); errors.Cause(err) != audit.ErrInvalidEvent {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT record an event without a target : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT record an event without a target.", tests.Success, testID)
+
+			var recorded []audit.Event
+			for i, action := range []string{"user:create", "user:update", "user:update", "product:create"} {
+				ne := audit.NewEvent{
+					ActorID:    "5cf37266-3473-4006-984f-9325122678b7",
+					Action:     action,
+					TargetType: "user",
+					TargetID:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				}
+				ev, err := as.Record(ctx, traceID, ne, now.Add(time.Duration(i)*time.Minute))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record an event : %s.", tests.Failed, testID, err)
+				}
+				recorded = append(recorded, ev)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record events.", tests.Success, testID)
+
+			page, err := as.Query(ctx, traceID, audit.QueryFilter{}, nil, 3)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query events : %s.", tests.Failed, testID, err)
+			}
+			if len(page.Events) != 3 || !page.HasMore || page.Events[0].ID != recorded[3].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get the newest events first : %+v.", tests.Failed, testID, page)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the newest events first.", tests.Success, testID)
+
+			page, err = as.Query(ctx, traceID, audit.QueryFilter{}, page.Next, 3)
+			if err != nil || len(page.Events) != 1 || page.HasMore || page.Events[0].ID != recorded[0].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould resume after the cursor : %+v %v.", tests.Failed, testID, page, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould resume after the cursor.", tests.Success, testID)
+
+			after := now.Add(time.Minute)
+			filter := audit.QueryFilter{
+				Action:           tests.StringPointer("user:update"),
+				StartCreatedDate: &after,
+			}
+			page, err = as.Query(ctx, traceID, filter, nil, 0)
+			if err != nil || len(page.Events) != 2 || page.HasMore {
+				t.Fatalf("\t%s\tTest %d:\tShould filter the events : %+v %v.", tests.Failed, testID, page, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould filter the events.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Diff compares the JSON representations of a target before and after a
+// change and returns the fields that differ, sorted by name. Either side can
+// be nil for targets that are created or removed. Fields hidden from the
+// JSON representation are never compared, and the ignored ones are skipped.
+func Diff(old interface{}, new interface{}, ignore ...string) (Changes, error) {
+	before, err := fields(old)
+	if err != nil {
+		return nil, err
+	}
+	after, err := fields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		skip[field] = true
+	}
+
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	var changes Changes
+	for name := range names {
+		if skip[name] || reflect.DeepEqual(before[name], after[name]) {
+			continue
+		}
+		changes = append(changes, Change{
+			Field: name,
+			Old:   before[name],
+			New:   after[name],
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+// Redact adds a change of a secret field, hiding both of its values, and
+// keeps the changes sorted by field.
+func (c Changes) Redact(field string) Changes {
+	c = append(c, Change{
+		Field: field,
+		Old:   Redacted,
+		New:   Redacted,
+	})
+	sort.SliceStable(c, func(i, j int) bool { return c[i].Field < c[j].Field })
+	return c
+}
+
+// fields decodes the JSON representation of v into its top level fields.
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding target")
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrap(err, "decoding target fields")
+	}
+
+	return m, nil
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Redacted stands in for the values of secret fields, such as passwords, in
+// a change.
+const Redacted = "REDACTED"
+
+// Event records a change made to a target by an actor. ActorID is empty for
+// changes made by the system itself.
+type Event struct {
+	ID          string    `db:"event_id" json:"id"`
+	ActorID     string    `db:"actor_id" json:"actor_id"`
+	Action      string    `db:"action" json:"action"`
+	TargetType  string    `db:"target_type" json:"target_type"`
+	TargetID    string    `db:"target_id" json:"target_id"`
+	TraceID     string    `db:"trace_id" json:"trace_id"`
+	Changes     Changes   `db:"changes" json:"changes"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// NewEvent contains the information needed to record an event.
+type NewEvent struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Changes    Changes
+}
+
+// Change holds the old and new value of a single field of a target. Fields
+// that did not exist before or after the change have a nil value.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Changes is the list of fields changed by an event. It is stored as a JSON
+// document.
+type Changes []Change
+
+// Value implements the driver.Valuer interface.
+func (c Changes) Value() (driver.Value, error) {
+	if c == nil {
+		c = Changes{}
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface.
+func (c *Changes) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	}
+	return errors.Errorf("can not scan %T into changes", src)
+}
+
+// QueryFilter holds the available fields a listing of events can be
+// filtered on. Nil fields are not applied, and the creation date range is
+// inclusive on the start only.
+type QueryFilter struct {
+	ActorID          *string    `json:"actor_id"`
+	Action           *string    `json:"action"`
+	TargetType       *string    `json:"target_type"`
+	TargetID         *string    `json:"target_id"`
+	TraceID          *string    `json:"trace_id"`
+	StartCreatedDate *time.Time `json:"created_after"`
+	EndCreatedDate   *time.Time `json:"created_before"`
+}
+
+// Cursor marks the position of the last event returned in a page. Events
+// are listed from the newest, and listing resumes right after it.
+type Cursor struct {
+	DateCreated time.Time `json:"date_created"`
+	ID          string    `json:"id"`
+}
+
+// Page is a set of events returned by a listing along with the position to
+// resume from. Next is only set when HasMore is true.
+type Page struct {
+	Events  []Event
+	Next    *Cursor
+	HasMore bool
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+)
+
+// Storer declares the behavior the audit service needs from a persistence
+// layer. Events are only ever added. Query returns at most limit events
+// matching the filter, from the newest with ties broken by event_id,
+// starting right after the provided cursor, or from the newest when it is
+// nil.
+type Storer interface {
+	Create(ctx context.Context, traceID string, ev Event) error
+	Query(ctx context.Context, traceID string, filter QueryFilter, after *Cursor, limit int) ([]Event, error)
+}
+
+// TranStorer returns the storer recording the events of a change made within
+// the transaction tx. Services running their own transactions take one, so
+// where their events go can be swapped like for the services taking a
+// Storer with a WithinTran method.
+type TranStorer func(tx database.Executor) Storer
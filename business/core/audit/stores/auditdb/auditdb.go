@@ -0,0 +1,124 @@
+// Package auditdb contains the Postgres implementation of audit.Storer.
+package auditdb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for audit events access in Postgres.
+type Store struct {
+	db  database.Executor
+	log *zap.SugaredLogger
+}
+
+// NewStore constructs a Postgres backed audit store. Passing a *sqlx.Tx
+// makes the store join that transaction, which is how events are recorded
+// along with the change they describe.
+func NewStore(log *zap.SugaredLogger, db database.Executor) Store {
+	return Store{
+		db:  db,
+		log: log,
+	}
+}
+
+// TranStorer returns an audit.TranStorer recording the events within the
+// transaction of the change.
+func TranStorer(log *zap.SugaredLogger) audit.TranStorer {
+	return func(tx database.Executor) audit.Storer {
+		return NewStore(log, tx)
+	}
+}
+
+// Create inserts a new event into the database.
+func (s Store) Create(ctx context.Context, traceID string, ev audit.Event) error {
+	const q = `
+	INSERT INTO audit_events
+		(event_id, actor_id, action, target_type, target_id, trace_id, changes, date_created)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "audit.Create",
+		database.Log(q, ev.ID, ev.ActorID, ev.Action, ev.TargetType, ev.TargetID, ev.TraceID, ev.Changes, ev.DateCreated),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, ev.ID, ev.ActorID, ev.Action, ev.TargetType, ev.TargetID, ev.TraceID, ev.Changes, ev.DateCreated); err != nil {
+		return errors.Wrap(err, "inserting event")
+	}
+
+	return nil
+}
+
+// Query retrieves a page of events matching the filter, from the newest.
+func (s Store) Query(ctx context.Context, traceID string, filter audit.QueryFilter, after *audit.Cursor, limit int) ([]audit.Event, error) {
+	data := map[string]interface{}{
+		"rows": limit,
+	}
+
+	var where []string
+	if filter.ActorID != nil {
+		data["actor_id"] = *filter.ActorID
+		where = append(where, "actor_id = :actor_id")
+	}
+	if filter.Action != nil {
+		data["action"] = *filter.Action
+		where = append(where, "action = :action")
+	}
+	if filter.TargetType != nil {
+		data["target_type"] = *filter.TargetType
+		where = append(where, "target_type = :target_type")
+	}
+	if filter.TargetID != nil {
+		data["target_id"] = *filter.TargetID
+		where = append(where, "target_id = :target_id")
+	}
+	if filter.TraceID != nil {
+		data["trace_id"] = *filter.TraceID
+		where = append(where, "trace_id = :trace_id")
+	}
+	if filter.StartCreatedDate != nil {
+		data["start_date_created"] = filter.StartCreatedDate.UTC()
+		where = append(where, "date_created >= :start_date_created")
+	}
+	if filter.EndCreatedDate != nil {
+		data["end_date_created"] = filter.EndCreatedDate.UTC()
+		where = append(where, "date_created < :end_date_created")
+	}
+	if after != nil {
+		data["cursor_date"] = after.DateCreated.UTC()
+		data["cursor_id"] = after.ID
+		where = append(where, "(date_created, event_id) < (:cursor_date, CAST(:cursor_id AS UUID))")
+	}
+
+	const q = `
+	SELECT * 
+		FROM audit_events`
+
+	buf := bytes.NewBufferString(q)
+	if len(where) > 0 {
+		buf.WriteString(`
+			WHERE `)
+		buf.WriteString(strings.Join(where, " AND "))
+	}
+	buf.WriteString(`
+	ORDER BY date_created DESC, event_id DESC
+	FETCH FIRST :rows ROWS ONLY
+	`)
+
+	s.log.Infof("%s : %s : query : %s", traceID, "audit.Query",
+		database.Log(buf.String(), data),
+	)
+
+	var events []audit.Event
+	if err := database.NamedQuerySlice(ctx, s.db, buf.String(), data, &events); err != nil {
+		return nil, errors.Wrap(err, "selecting events")
+	}
+
+	return events, nil
+}
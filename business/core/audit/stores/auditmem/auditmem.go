@@ -0,0 +1,101 @@
+// Package auditmem contains an in-memory implementation of audit.Storer,
+// used for testing the business rules without a database.
+package auditmem
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+)
+
+// Store keeps the events in memory. It is safe for concurrent use.
+type Store struct {
+	mu     sync.RWMutex
+	events []audit.Event
+}
+
+// NewStore constructs an empty in-memory audit store.
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Create adds an event to the store.
+func (s *Store) Create(ctx context.Context, traceID string, ev audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, ev)
+	return nil
+}
+
+// Events returns every stored event in the order they were recorded.
+func (s *Store) Events() []audit.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]audit.Event(nil), s.events...)
+}
+
+// Query retrieves a page of events matching the filter, from the newest.
+func (s *Store) Query(ctx context.Context, traceID string, filter audit.QueryFilter, after *audit.Cursor, limit int) ([]audit.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []audit.Event
+	for _, ev := range s.events {
+		if !match(filter, ev) {
+			continue
+		}
+		if after != nil && !older(ev, after.DateCreated.UnixNano(), after.ID) {
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return older(events[j], events[i].DateCreated.UnixNano(), events[i].ID)
+	})
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// older reports whether ev comes after the position given by a date and an
+// ID when listing from the newest.
+func older(ev audit.Event, date int64, id string) bool {
+	if d := ev.DateCreated.UnixNano(); d != date {
+		return d < date
+	}
+	return ev.ID < id
+}
+
+// match reports whether ev satisfies every field set in the filter.
+func match(filter audit.QueryFilter, ev audit.Event) bool {
+	if filter.ActorID != nil && ev.ActorID != *filter.ActorID {
+		return false
+	}
+	if filter.Action != nil && ev.Action != *filter.Action {
+		return false
+	}
+	if filter.TargetType != nil && ev.TargetType != *filter.TargetType {
+		return false
+	}
+	if filter.TargetID != nil && ev.TargetID != *filter.TargetID {
+		return false
+	}
+	if filter.TraceID != nil && ev.TraceID != *filter.TraceID {
+		return false
+	}
+	if filter.StartCreatedDate != nil && ev.DateCreated.Before(*filter.StartCreatedDate) {
+		return false
+	}
+	if filter.EndCreatedDate != nil && !ev.DateCreated.Before(*filter.EndCreatedDate) {
+		return false
+	}
+	return true
+}
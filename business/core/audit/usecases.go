@@ -0,0 +1,103 @@
+// Package audit contains usecases for recording and querying the changes
+// made to the state of the system. Events are meant to be recorded in the
+// same transaction as the change they describe, so neither can be committed
+// without the other.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// These are the boundaries for the number of events returned per page.
+const (
+	DefaultRowsPerPage = 50
+	MaxRowsPerPage     = 500
+)
+
+// ErrInvalidEvent occurs when an event is recorded without an action or a
+// target.
+var ErrInvalidEvent = errors.New("event must have an action and a target")
+
+type AuditService struct {
+	storer Storer
+	log    *zap.SugaredLogger
+}
+
+// New is a factory method for constructing audit service. To record events
+// along with a change, the storer must join the transaction of the change.
+func New(log *zap.SugaredLogger, storer Storer) AuditService {
+	return AuditService{
+		storer: storer,
+		log:    log,
+	}
+}
+
+// Record stores an event for a change made on behalf of a request.
+func (as AuditService) Record(ctx context.Context, traceID string, ne NewEvent, now time.Time) (Event, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.audit.Record")
+	defer span.End()
+
+	if ne.Action == "" || ne.TargetType == "" {
+		return Event{}, ErrInvalidEvent
+	}
+
+	ev := Event{
+		ID:          uuid.New().String(),
+		ActorID:     ne.ActorID,
+		Action:      ne.Action,
+		TargetType:  ne.TargetType,
+		TargetID:    ne.TargetID,
+		TraceID:     traceID,
+		Changes:     ne.Changes,
+		DateCreated: now.UTC(),
+	}
+
+	if err := as.storer.Create(ctx, traceID, ev); err != nil {
+		return Event{}, errors.Wrap(err, "recording event")
+	}
+
+	return ev, nil
+}
+
+// Query retrieves a page of events matching the filter, from the newest.
+// Listing starts right after the provided cursor, or from the newest event
+// when it is nil. The number of rows is capped by MaxRowsPerPage.
+func (as AuditService) Query(ctx context.Context, traceID string, filter QueryFilter, after *Cursor, rowsPerPage int) (Page, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.audit.Query")
+	defer span.End()
+
+	switch {
+	case rowsPerPage < 1:
+		rowsPerPage = DefaultRowsPerPage
+	case rowsPerPage > MaxRowsPerPage:
+		rowsPerPage = MaxRowsPerPage
+	}
+
+	// Ask for one extra row to find out if there is a next page.
+	events, err := as.storer.Query(ctx, traceID, filter, after, rowsPerPage+1)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{
+		Events: events,
+	}
+	if len(events) > rowsPerPage {
+		page.Events = events[:rowsPerPage]
+		page.HasMore = true
+
+		last := page.Events[rowsPerPage-1]
+		page.Next = &Cursor{
+			DateCreated: last.DateCreated,
+			ID:          last.ID,
+		}
+	}
+
+	return page, nil
+}
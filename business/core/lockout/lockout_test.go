@@ -0,0 +1,133 @@
+package lockout_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout/stores/lockoutmem"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/logger"
+
+	"github.com/pkg/errors"
+)
+
+func TestLockout(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	cfg := lockout.Config{
+		EmailThreshold: 3,
+		AddrThreshold:  5,
+		BaseDelay:      time.Second,
+		MaxDelay:       4 * time.Second,
+		Window:         time.Hour,
+	}
+
+	t.Log("Given the need to throttle password guessing.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen guessing the password of a single email.", testID)
+		{
+			ls := lockout.New(log, lockoutmem.NewStore(), cfg)
+
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			for i := 0; i < 2; i++ {
+				if err := ls.Fail(ctx, traceID, now, "user@example.com", "10.0.0.1"); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failure : %s.", tests.Failed, testID, err)
+				}
+			}
+			if _, err := ls.Check(ctx, traceID, now, "user@example.com", "10.0.0.1"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT lock before the threshold : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT lock before the threshold.", tests.Success, testID)
+
+			// Every lock starts once the previous one is over, doubling up to
+			// the maximum delay.
+			for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+				if err := ls.Fail(ctx, traceID, now, "User@Example.com", "10.0.0.1"); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failure : %s.", tests.Failed, testID, err)
+				}
+
+				retryAfter, err := ls.Check(ctx, traceID, now, "user@example.com", "10.0.0.2")
+				if errors.Cause(err) != lockout.ErrLocked || retryAfter != want {
+					t.Fatalf("\t%s\tTest %d:\tShould lock the email for %v after %d failures : %v %s.", tests.Failed, testID, want, i+3, retryAfter, err)
+				}
+				now = now.Add(retryAfter)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the email with exponential backoff.", tests.Success, testID)
+
+			if _, err := ls.Check(ctx, traceID, now, "other@example.com", "10.0.0.2"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT lock other emails : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT lock other emails.", tests.Success, testID)
+
+			if err := ls.Fail(ctx, traceID, now, "user@example.com", "10.0.0.1"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record a failure : %s.", tests.Failed, testID, err)
+			}
+			if err := ls.Unlock(ctx, traceID, "user@example.com"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unlock the email : %s.", tests.Failed, testID, err)
+			}
+			if _, err := ls.Check(ctx, traceID, now, "user@example.com", "10.0.0.2"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept attempts after an unlock : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept attempts after an unlock.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen guessing the passwords of many emails.", testID)
+		{
+			ls := lockout.New(log, lockoutmem.NewStore(), cfg)
+
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+				if err := ls.Fail(ctx, traceID, now, email, "10.0.0.1"); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failure : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			if _, err := ls.Check(ctx, traceID, now, "f@example.com", "10.0.0.1"); errors.Cause(err) != lockout.ErrLocked {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the remote address : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the remote address.", tests.Success, testID)
+
+			if err := ls.Succeed(ctx, traceID, "a@example.com"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record a success : %s.", tests.Failed, testID, err)
+			}
+			if _, err := ls.Check(ctx, traceID, now, "a@example.com", "10.0.0.1"); errors.Cause(err) != lockout.ErrLocked {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the remote address locked after a success : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the remote address locked after a success.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen failures are spread over time.", testID)
+		{
+			ls := lockout.New(log, lockoutmem.NewStore(), cfg)
+
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			for i := 0; i < 3; i++ {
+				if err := ls.Fail(ctx, traceID, now, "user@example.com", ""); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to record a failure : %s.", tests.Failed, testID, err)
+				}
+				now = now.Add(cfg.Window + time.Second)
+			}
+
+			if _, err := ls.Check(ctx, traceID, now, "user@example.com", ""); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould forget failures older than the window : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould forget failures older than the window.", tests.Success, testID)
+		}
+	}
+}
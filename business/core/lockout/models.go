@@ -0,0 +1,23 @@
+package lockout
+
+import "time"
+
+// Attempts tracks the failed authentications made with a single key, an
+// email or a remote address.
+type Attempts struct {
+	Key         string     `db:"key" json:"key"`
+	Failures    int        `db:"failures" json:"failures"`
+	LastFailure time.Time  `db:"date_last_failure" json:"date_last_failure"`
+	LockedUntil *time.Time `db:"date_locked_until" json:"date_locked_until,omitempty"`
+}
+
+// Config sets how many failures are tolerated before locking and for how
+// long. The first lock lasts BaseDelay and each further failure doubles it,
+// up to MaxDelay. Failures are forgotten after Window without any failure.
+type Config struct {
+	EmailThreshold int
+	AddrThreshold  int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	Window         time.Duration
+}
@@ -0,0 +1,18 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Storer declares the behavior the lockout service needs from a persistence
+// layer. Fail must atomically count one more failure for the key, starting
+// over from one when the last failure happened before the since time, and
+// return the updated attempts. Query skips keys without attempts and Reset
+// forgets every attempt of a key.
+type Storer interface {
+	Query(ctx context.Context, traceID string, keys []string) ([]Attempts, error)
+	Fail(ctx context.Context, traceID string, key string, now time.Time, since time.Time) (Attempts, error)
+	Lock(ctx context.Context, traceID string, key string, until time.Time) error
+	Reset(ctx context.Context, traceID string, key string) error
+}
@@ -0,0 +1,115 @@
+// Package lockoutdb contains the Postgres implementation of lockout.Storer,
+// which shares the failed attempts between every replica of the service.
+package lockoutdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for failed attempts access in Postgres.
+type Store struct {
+	db  database.Executor
+	log *zap.SugaredLogger
+}
+
+// NewStore constructs a Postgres backed lockout store. Passing a *sqlx.Tx
+// makes the store join that transaction.
+func NewStore(log *zap.SugaredLogger, db database.Executor) Store {
+	return Store{
+		db:  db,
+		log: log,
+	}
+}
+
+// Query retrieves the attempts of the given keys.
+func (s Store) Query(ctx context.Context, traceID string, keys []string) ([]lockout.Attempts, error) {
+	const q = `
+	SELECT * 
+		FROM login_attempts 
+			WHERE key = ANY($1)
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "lockout.Query",
+		database.Log(q, pq.StringArray(keys)),
+	)
+
+	var attempts []lockout.Attempts
+	if err := s.db.SelectContext(ctx, &attempts, q, pq.StringArray(keys)); err != nil {
+		return nil, errors.Wrap(err, "selecting attempts")
+	}
+
+	return attempts, nil
+}
+
+// Fail counts one more failure for the key. The upsert makes concurrent
+// failures add up instead of overwriting each other.
+func (s Store) Fail(ctx context.Context, traceID string, key string, now time.Time, since time.Time) (lockout.Attempts, error) {
+	const q = `
+	INSERT INTO login_attempts AS a
+		(key, failures, date_last_failure)
+	VALUES ($1, 1, $2)
+	ON CONFLICT (key) DO UPDATE
+		SET
+			"failures" = CASE WHEN a.date_last_failure < $3 THEN 1 ELSE a.failures + 1 END,
+			"date_last_failure" = $2,
+			"date_locked_until" = CASE WHEN a.date_last_failure < $3 THEN NULL ELSE a.date_locked_until END
+	RETURNING *
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "lockout.Fail",
+		database.Log(q, key, now.UTC(), since.UTC()),
+	)
+
+	var a lockout.Attempts
+	if err := s.db.GetContext(ctx, &a, q, key, now.UTC(), since.UTC()); err != nil {
+		return lockout.Attempts{}, errors.Wrap(err, "recording failure")
+	}
+
+	return a, nil
+}
+
+// Lock locks the key until the given time.
+func (s Store) Lock(ctx context.Context, traceID string, key string, until time.Time) error {
+	const q = `
+	UPDATE login_attempts
+		SET
+			"date_locked_until" = $2
+		WHERE key = $1
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "lockout.Lock",
+		database.Log(q, key, until.UTC()),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, key, until.UTC()); err != nil {
+		return errors.Wrap(err, "locking key")
+	}
+
+	return nil
+}
+
+// Reset forgets the attempts of the key.
+func (s Store) Reset(ctx context.Context, traceID string, key string) error {
+	const q = `
+	DELETE 
+		FROM login_attempts 
+			WHERE key = $1
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "lockout.Reset",
+		database.Log(q, key),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, key); err != nil {
+		return errors.Wrap(err, "resetting attempts")
+	}
+
+	return nil
+}
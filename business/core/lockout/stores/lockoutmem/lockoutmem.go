@@ -0,0 +1,79 @@
+// Package lockoutmem contains an in-memory implementation of lockout.Storer.
+// It only sees the attempts made against a single instance.
+package lockoutmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/lockout"
+)
+
+// Store keeps the failed attempts in memory. It is safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	attempts map[string]lockout.Attempts
+}
+
+// NewStore constructs an empty in-memory lockout store.
+func NewStore() *Store {
+	return &Store{
+		attempts: make(map[string]lockout.Attempts),
+	}
+}
+
+// Query retrieves the attempts of the given keys.
+func (s *Store) Query(ctx context.Context, traceID string, keys []string) ([]lockout.Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []lockout.Attempts
+	for _, key := range keys {
+		if a, exists := s.attempts[key]; exists {
+			attempts = append(attempts, a)
+		}
+	}
+
+	return attempts, nil
+}
+
+// Fail counts one more failure for the key.
+func (s *Store) Fail(ctx context.Context, traceID string, key string, now time.Time, since time.Time) (lockout.Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, exists := s.attempts[key]
+	if !exists || a.LastFailure.Before(since) {
+		a = lockout.Attempts{
+			Key: key,
+		}
+	}
+	a.Failures++
+	a.LastFailure = now
+
+	s.attempts[key] = a
+	return a, nil
+}
+
+// Lock locks the key until the given time.
+func (s *Store) Lock(ctx context.Context, traceID string, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, exists := s.attempts[key]; exists {
+		a.LockedUntil = &until
+		s.attempts[key] = a
+	}
+
+	return nil
+}
+
+// Reset forgets the attempts of the key.
+func (s *Store) Reset(ctx context.Context, traceID string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package lockout contains usecases for throttling password guessing. Failed
+// authentications are counted per email and per remote address, and a key
+// failing too often is locked for an exponentially growing delay.
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// ErrLocked occurs when an authentication is attempted for a locked email or
+// from a locked remote address.
+var ErrLocked = errors.New("too many failed attempts, try again later")
+
+type LockoutService struct {
+	storer Storer
+	cfg    Config
+	log    *zap.SugaredLogger
+}
+
+// New is a factory method for constructing lockout service. The in-memory
+// store only sees the attempts made against a single instance, so
+// deployments with many replicas need a shared store.
+func New(log *zap.SugaredLogger, storer Storer, cfg Config) LockoutService {
+	return LockoutService{
+		storer: storer,
+		cfg:    cfg,
+		log:    log,
+	}
+}
+
+// Check reports whether an authentication for the email from the remote
+// address may go ahead. It returns ErrLocked along with the time left before
+// the next attempt when either of them is locked.
+func (ls LockoutService) Check(ctx context.Context, traceID string, now time.Time, email string, addr string) (time.Duration, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.lockout.Check")
+	defer span.End()
+
+	attempts, err := ls.storer.Query(ctx, traceID, keys(email, addr))
+	if err != nil {
+		return 0, errors.Wrap(err, "querying attempts")
+	}
+
+	var retryAfter time.Duration
+	for _, a := range attempts {
+		if a.LockedUntil == nil {
+			continue
+		}
+		if left := a.LockedUntil.Sub(now); left > retryAfter {
+			retryAfter = left
+		}
+	}
+	if retryAfter > 0 {
+		return retryAfter, ErrLocked
+	}
+
+	return 0, nil
+}
+
+// Fail records a failed authentication for the email from the remote
+// address, locking the ones that reached their threshold.
+func (ls LockoutService) Fail(ctx context.Context, traceID string, now time.Time, email string, addr string) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.lockout.Fail")
+	defer span.End()
+
+	thresholds := map[string]int{
+		emailKey(email): ls.cfg.EmailThreshold,
+	}
+	if addr != "" {
+		thresholds[addrKey(addr)] = ls.cfg.AddrThreshold
+	}
+
+	for key, threshold := range thresholds {
+		a, err := ls.storer.Fail(ctx, traceID, key, now, now.Add(-ls.cfg.Window))
+		if err != nil {
+			return errors.Wrapf(err, "recording failure for %s", key)
+		}
+		if a.Failures < threshold {
+			continue
+		}
+
+		delay := ls.delay(a.Failures - threshold)
+		if err := ls.storer.Lock(ctx, traceID, key, now.Add(delay)); err != nil {
+			return errors.Wrapf(err, "locking %s", key)
+		}
+		ls.log.Warnw("lockout", "traceid", traceID, "key", key, "failures", a.Failures, "delay", delay)
+	}
+
+	return nil
+}
+
+// Succeed forgets the failures of an email after a successful
+// authentication. The failures of the remote address are kept, otherwise a
+// client owning any account could keep guessing the passwords of others.
+func (ls LockoutService) Succeed(ctx context.Context, traceID string, email string) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.lockout.Succeed")
+	defer span.End()
+
+	return ls.storer.Reset(ctx, traceID, emailKey(email))
+}
+
+// Unlock lifts the lock of an email and forgets its failures.
+func (ls LockoutService) Unlock(ctx context.Context, traceID string, email string) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.lockout.Unlock")
+	defer span.End()
+
+	return ls.storer.Reset(ctx, traceID, emailKey(email))
+}
+
+// delay returns how long a key is locked for after exceeding its threshold
+// n times, doubling from BaseDelay up to MaxDelay.
+func (ls LockoutService) delay(n int) time.Duration {
+	if n >= 32 {
+		return ls.cfg.MaxDelay
+	}
+
+	d := ls.cfg.BaseDelay << uint(n)
+	if d <= 0 || d > ls.cfg.MaxDelay {
+		return ls.cfg.MaxDelay
+	}
+
+	return d
+}
+
+// keys returns the store keys of an email and a remote address.
+func keys(email string, addr string) []string {
+	keys := []string{emailKey(email)}
+	if addr != "" {
+		keys = append(keys, addrKey(addr))
+	}
+	return keys
+}
+
+// emailKey returns the store key of an email. Emails are compared without
+// case so guesses can not get around a lock by changing it.
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// addrKey returns the store key of a remote address.
+func addrKey(addr string) string {
+	return "addr:" + addr
+}
@@ -0,0 +1,149 @@
+package product_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditmem"
+	"github.com/danielmbirochi/go-sample-service/business/core/product"
+	"github.com/danielmbirochi/go-sample-service/business/data/schema"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestProduct(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	// Events are recorded in memory, to check every change is audited.
+	events := auditmem.NewStore()
+	p := product.New(log, db, auth.DefaultPolicy(), func(database.Executor) audit.Storer {
+		return events
+	})
+
+	t.Log("Given the need to work with Product records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Product.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			if err := schema.DeleteAll(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete all data : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete all data.", tests.Success, testID)
+
+			owner := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "go-sample-service project",
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					Audience:  []string{"testers"},
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(now),
+				},
+				Roles: []string{auth.RoleOperator},
+			}
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+			}
+
+			prd, err := p.Create(ctx, traceID, owner, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", tests.Success, testID)
+
+			saved, err := p.QueryByID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", tests.Success, testID)
+
+			if diff := cmp.Diff(prd, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", tests.Success, testID)
+
+			upd := product.UpdateProduct{
+				Name: tests.StringPointer("Comics"),
+				Cost: tests.IntPointer(50),
+			}
+
+			other := owner
+			other.Subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			if err := p.Update(ctx, traceID, other, prd.ID, upd, now); errors.Cause(err) != product.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update a product owned by another user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update a product owned by another user.", tests.Success, testID)
+
+			if err := p.Update(ctx, traceID, owner, prd.ID, upd, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update product.", tests.Success, testID)
+
+			products, err := p.List(ctx, traceID, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", tests.Success, testID)
+
+			// Check specified fields were updated. Make a copy of the original product
+			// and change just the fields we expect then diff it with what was saved.
+			want := prd
+			want.Name = *upd.Name
+			want.Cost = *upd.Cost
+
+			var idx int
+			for i, p := range products {
+				if p.ID == want.ID {
+					idx = i
+				}
+			}
+			if diff := cmp.Diff(want, products[idx]); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", tests.Success, testID)
+
+			if err := p.Delete(ctx, traceID, other, prd.ID, now); errors.Cause(err) != product.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete a product owned by another user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete a product owned by another user.", tests.Success, testID)
+
+			if err := p.Delete(ctx, traceID, owner, prd.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete product.", tests.Success, testID)
+
+			_, err = p.QueryByID(ctx, traceID, prd.ID)
+			if errors.Cause(err) != product.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product.", tests.Success, testID)
+
+			var actions []string
+			for _, ev := range events.Events() {
+				if ev.TargetID != prd.ID || ev.ActorID != owner.Subject {
+					t.Fatalf("\t%s\tTest %d:\tShould record the events of the product by its owner : %+v.", tests.Failed, testID, ev)
+				}
+				actions = append(actions, ev.Action)
+			}
+			exp := []string{auth.ActionProductCreate, auth.ActionProductUpdate, auth.ActionProductDelete}
+			if diff := cmp.Diff(exp, actions); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould record every change in the audit log. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record every change in the audit log.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package product contains usecases for CRUD operations.
+package product
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is used when a specific Product is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form (UUID).
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// AuditTarget is the type of target of the audit events about products.
+const AuditTarget = "product"
+
+type ProductService struct {
+	db     database.Executor
+	policy *auth.Policy
+	audits audit.TranStorer
+	log    *zap.SugaredLogger
+}
+
+// New is a factory method for constructing product service. Passing a *sqlx.Tx
+// makes the service join that transaction. The policy decides who can modify
+// a product, and audits where the changes are recorded.
+func New(log *zap.SugaredLogger, db database.Executor, policy *auth.Policy, audits audit.TranStorer) ProductService {
+	return ProductService{
+		db:     db,
+		policy: policy,
+		audits: audits,
+		log:    log,
+	}
+}
+
+// Create inserts a new product into the database. The product is owned by
+// the user identified by the claims subject. Every change to a product is
+// recorded in the audit log within the same transaction.
+func (ps ProductService) Create(ctx context.Context, traceID string, claims auth.Claims, np NewProduct, now time.Time) (Product, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.product.Create")
+	defer span.End()
+
+	prd := Product{
+		ID:          uuid.New().String(),
+		Name:        np.Name,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		UserID:      claims.Subject,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO products
+		(product_id, user_id, name, cost, quantity, date_created, date_updated)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	ps.log.Infof("%s : %s : query : %s", traceID, "product.Create",
+		database.Log(q, prd.ID, prd.UserID, prd.Name, prd.Cost, prd.Quantity, prd.DateCreated, prd.DateUpdated),
+	)
+
+	err := database.WithinTran(ctx, ps.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, q, prd.ID, prd.UserID, prd.Name, prd.Cost, prd.Quantity, prd.DateCreated, prd.DateUpdated); err != nil {
+			return errors.Wrap(err, "inserting product")
+		}
+		return ps.record(ctx, tx, traceID, claims, auth.ActionProductCreate, prd.ID, nil, prd, now)
+	})
+	if err != nil {
+		return Product{}, err
+	}
+
+	return prd, nil
+}
+
+// Update modifies data about a product. It will error if the specified ID is
+// invalid or does not reference an existing product.
+func (ps ProductService) Update(ctx context.Context, traceID string, claims auth.Claims, id string, up UpdateProduct, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.product.Update")
+	defer span.End()
+
+	return database.WithinTran(ctx, ps.db, func(tx *sqlx.Tx) error {
+		old, err := New(ps.log, tx, ps.policy, ps.audits).QueryByID(ctx, traceID, id)
+		if err != nil {
+			return err
+		}
+
+		if !ps.policy.Can(claims, auth.ActionProductUpdate, auth.Resource{OwnerID: old.UserID}) {
+			return ErrForbidden
+		}
+
+		prd := old
+		if up.Name != nil {
+			prd.Name = *up.Name
+		}
+		if up.Cost != nil {
+			prd.Cost = *up.Cost
+		}
+		if up.Quantity != nil {
+			prd.Quantity = *up.Quantity
+		}
+		prd.DateUpdated = now
+
+		const q = `
+		UPDATE products 
+			SET
+				"name" = $2,
+				"cost" = $3,
+				"quantity" = $4,
+				"date_updated" = $5
+			WHERE product_id = $1
+		`
+
+		ps.log.Infof("%s : %s : query : %s", traceID, "product.Update",
+			database.Log(q, prd.ID, prd.Name, prd.Cost, prd.Quantity, prd.DateUpdated),
+		)
+
+		if _, err = tx.ExecContext(ctx, q, prd.ID, prd.Name, prd.Cost, prd.Quantity, prd.DateUpdated); err != nil {
+			return errors.Wrap(err, "updating product")
+		}
+		return ps.record(ctx, tx, traceID, claims, auth.ActionProductUpdate, prd.ID, old, prd, now)
+	})
+}
+
+// Delete removes the product identified by a given ID.
+func (ps ProductService) Delete(ctx context.Context, traceID string, claims auth.Claims, id string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.product.Delete")
+	defer span.End()
+
+	return database.WithinTran(ctx, ps.db, func(tx *sqlx.Tx) error {
+		prd, err := New(ps.log, tx, ps.policy, ps.audits).QueryByID(ctx, traceID, id)
+		if err != nil {
+			return err
+		}
+
+		if !ps.policy.Can(claims, auth.ActionProductDelete, auth.Resource{OwnerID: prd.UserID}) {
+			return ErrForbidden
+		}
+
+		const q = `
+		DELETE 
+			FROM products 
+				WHERE product_id = $1
+		`
+
+		ps.log.Infof("%s : %s : query : %s", traceID, "product.Delete",
+			database.Log(q, id),
+		)
+
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return errors.Wrapf(err, "deleting product %s", id)
+		}
+		return ps.record(ctx, tx, traceID, claims, auth.ActionProductDelete, id, prd, nil, now)
+	})
+}
+
+// List retrieves a list of existing products from the database.
+func (ps ProductService) List(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]Product, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.product.List")
+	defer span.End()
+
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT * 
+		FROM products
+	ORDER BY product_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY
+	`
+
+	ps.log.Infof("%s : %s : query : %s", traceID, "product.List",
+		database.Log(q, data),
+	)
+
+	var products []Product
+	if err := database.NamedQuerySlice(ctx, ps.db, q, data, &products); err != nil {
+		return nil, errors.Wrap(err, "selecting products")
+	}
+
+	return products, nil
+}
+
+// QueryByID gets the specified product from the database.
+func (ps ProductService) QueryByID(ctx context.Context, traceID string, productID string) (Product, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.product.QueryByID")
+	defer span.End()
+
+	if _, err := uuid.Parse(productID); err != nil {
+		return Product{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT * 
+		FROM products 
+			WHERE product_id = $1
+	`
+
+	ps.log.Infof("%s : %s : query : %s", traceID, "product.QueryByID",
+		database.Log(q, productID),
+	)
+
+	var prd Product
+	if err := ps.db.GetContext(ctx, &prd, q, productID); err != nil {
+		if err == sql.ErrNoRows {
+			return Product{}, ErrNotFound
+		}
+		return Product{}, errors.Wrapf(err, "selecting product %q", productID)
+	}
+
+	return prd, nil
+}
+
+// record adds an event about a change to a product to the audit log, within
+// the transaction of the change. A nil old or new product stands for a
+// product being created or removed.
+func (ps ProductService) record(ctx context.Context, tx *sqlx.Tx, traceID string, claims auth.Claims, action string, id string, old interface{}, new interface{}, now time.Time) error {
+	changes, err := audit.Diff(old, new, "date_updated")
+	if err != nil {
+		return err
+	}
+
+	ne := audit.NewEvent{
+		ActorID:    claims.Subject,
+		Action:     action,
+		TargetType: AuditTarget,
+		TargetID:   id,
+		Changes:    changes,
+	}
+	if _, err := audit.New(ps.log, ps.audits(tx)).Record(ctx, traceID, ne, now); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package sale
+
+import (
+	"time"
+)
+
+// Sale represents a transaction where we sold some quantity of a
+// Product.
+type Sale struct {
+	ID          string    `db:"sale_id" json:"id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	Quantity    int       `db:"quantity" json:"quantity"`
+	Paid        int       `db:"paid" json:"paid"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// NewSale is what we require from clients for recording new transactions.
+type NewSale struct {
+	Quantity int `json:"quantity" validate:"gte=1"`
+}
@@ -0,0 +1,131 @@
+package sale_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/product"
+	"github.com/danielmbirochi/go-sample-service/business/core/sale"
+	"github.com/danielmbirochi/go-sample-service/business/data/schema"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+func TestSale(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	audits := auditdb.TranStorer(log)
+	p := product.New(log, db, auth.DefaultPolicy(), audits)
+	s := sale.New(log, db, audits)
+
+	t.Log("Given the need to work with Sale records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling concurrent sales of a single Product.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			if err := schema.DeleteAll(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete all data : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete all data.", tests.Success, testID)
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "go-sample-service project",
+					Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+					Audience:  []string{"testers"},
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(now),
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 10,
+			}
+
+			prd, err := p.Create(ctx, traceID, claims, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", tests.Success, testID)
+
+			const buyers = 20
+			var (
+				wg     sync.WaitGroup
+				mu     sync.Mutex
+				sold   int
+				denied int
+				failed error
+			)
+			wg.Add(buyers)
+			for i := 0; i < buyers; i++ {
+				go func() {
+					defer wg.Done()
+
+					_, err := s.Create(ctx, traceID, claims, prd.ID, sale.NewSale{Quantity: 1}, now)
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch errors.Cause(err) {
+					case nil:
+						sold++
+					case sale.ErrInsufficientStock:
+						denied++
+					default:
+						failed = err
+					}
+				}()
+			}
+			wg.Wait()
+
+			if failed != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record concurrent sales : %s.", tests.Failed, testID, failed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record concurrent sales.", tests.Success, testID)
+
+			if sold != np.Quantity || denied != buyers-np.Quantity {
+				t.Fatalf("\t%s\tTest %d:\tShould sell exactly the available stock : sold %d denied %d.", tests.Failed, testID, sold, denied)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sell exactly the available stock.", tests.Success, testID)
+
+			saved, err := p.QueryByID(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", tests.Success, testID)
+
+			if saved.Quantity != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould have no stock left : got %d.", tests.Failed, testID, saved.Quantity)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have no stock left.", tests.Success, testID)
+
+			sales, err := s.QueryByProduct(ctx, traceID, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve sales by product : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve sales by product.", tests.Success, testID)
+
+			if len(sales) != np.Quantity {
+				t.Fatalf("\t%s\tTest %d:\tShould have one sale per unit sold : got %d.", tests.Failed, testID, len(sales))
+			}
+			t.Logf("\t%s\tTest %d:\tShould have one sale per unit sold.", tests.Success, testID)
+
+			if sales[0].Paid != np.Cost {
+				t.Fatalf("\t%s\tTest %d:\tShould charge the product cost : got %d want %d.", tests.Failed, testID, sales[0].Paid, np.Cost)
+			}
+			t.Logf("\t%s\tTest %d:\tShould charge the product cost.", tests.Success, testID)
+		}
+	}
+}
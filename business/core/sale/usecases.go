@@ -0,0 +1,194 @@
+// Package sale contains usecases for recording and querying product sales.
+package sale
+
+import (
+	"context"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is used when the Product being sold does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form (UUID).
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInsufficientStock occurs when a sale asks for more units than the
+	// Product has available.
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// AuditTarget is the type of target of the audit events about sales.
+const AuditTarget = "sale"
+
+type SaleService struct {
+	db     database.Executor
+	audits audit.TranStorer
+	log    *zap.SugaredLogger
+}
+
+// New is a factory method for constructing sale service. Passing a *sqlx.Tx
+// makes the service join that transaction. Sales are recorded by audits.
+func New(log *zap.SugaredLogger, db database.Executor, audits audit.TranStorer) SaleService {
+	return SaleService{
+		db:     db,
+		audits: audits,
+		log:    log,
+	}
+}
+
+// Create records a sale made to the claims subject for the specified product
+// and decrements its stock. Both statements, along with the audit event of
+// the sale, run in a single transaction. The stock decrement is a
+// conditional update, so concurrent purchases of the same product are
+// serialized by the row lock and can never take the quantity below zero.
+func (ss SaleService) Create(ctx context.Context, traceID string, claims auth.Claims, productID string, ns NewSale, now time.Time) (Sale, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.sale.Create")
+	defer span.End()
+
+	if _, err := uuid.Parse(productID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	var sl Sale
+	err := database.WithinTran(ctx, ss.db, func(tx *sqlx.Tx) error {
+		var err error
+		sl, err = ss.create(ctx, tx, traceID, productID, ns, now)
+		if err != nil {
+			return err
+		}
+
+		changes, err := audit.Diff(nil, sl)
+		if err != nil {
+			return err
+		}
+		ne := audit.NewEvent{
+			ActorID:    claims.Subject,
+			Action:     auth.ActionSaleCreate,
+			TargetType: AuditTarget,
+			TargetID:   sl.ID,
+			Changes:    changes,
+		}
+		_, err = audit.New(ss.log, ss.audits(tx)).Record(ctx, traceID, ne, now)
+		return err
+	})
+	if err != nil {
+		return Sale{}, err
+	}
+
+	return sl, nil
+}
+
+// create runs the statements that make up a sale against the provided
+// transaction.
+func (ss SaleService) create(ctx context.Context, tx *sqlx.Tx, traceID string, productID string, ns NewSale, now time.Time) (Sale, error) {
+	const qStock = `
+	UPDATE products
+		SET
+			"quantity" = quantity - $2,
+			"date_updated" = $3
+		WHERE product_id = $1 AND quantity >= $2
+	RETURNING cost
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "sale.Create",
+		database.Log(qStock, productID, ns.Quantity, now.UTC()),
+	)
+
+	var cost []int
+	if err := tx.SelectContext(ctx, &cost, qStock, productID, ns.Quantity, now.UTC()); err != nil {
+		return Sale{}, errors.Wrap(err, "decrementing product stock")
+	}
+
+	// No row was updated, so either the product does not exist or it does not
+	// have enough units left.
+	if len(cost) == 0 {
+		const qExists = `
+		SELECT EXISTS (
+			SELECT 1 
+				FROM products 
+					WHERE product_id = $1
+		)
+		`
+
+		ss.log.Infof("%s : %s : query : %s", traceID, "sale.Create",
+			database.Log(qExists, productID),
+		)
+
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, qExists, productID); err != nil {
+			return Sale{}, errors.Wrapf(err, "selecting product %q", productID)
+		}
+		if !exists {
+			return Sale{}, ErrNotFound
+		}
+		return Sale{}, ErrInsufficientStock
+	}
+
+	sl := Sale{
+		ID:          uuid.New().String(),
+		ProductID:   productID,
+		Quantity:    ns.Quantity,
+		Paid:        ns.Quantity * cost[0],
+		DateCreated: now.UTC(),
+	}
+
+	const qInsert = `
+	INSERT INTO sales
+		(sale_id, product_id, quantity, paid, date_created)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "sale.Create",
+		database.Log(qInsert, sl.ID, sl.ProductID, sl.Quantity, sl.Paid, sl.DateCreated),
+	)
+
+	if _, err := tx.ExecContext(ctx, qInsert, sl.ID, sl.ProductID, sl.Quantity, sl.Paid, sl.DateCreated); err != nil {
+		return Sale{}, errors.Wrap(err, "inserting sale")
+	}
+
+	return sl, nil
+}
+
+// QueryByProduct gets all the sales recorded for the specified product.
+func (ss SaleService) QueryByProduct(ctx context.Context, traceID string, productID string) ([]Sale, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.sale.QueryByProduct")
+	defer span.End()
+
+	if _, err := uuid.Parse(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	SELECT * 
+		FROM sales 
+			WHERE product_id = :product_id
+	ORDER BY date_created
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "sale.QueryByProduct",
+		database.Log(q, data),
+	)
+
+	sales := []Sale{}
+	if err := database.NamedQuerySlice(ctx, ss.db, q, data, &sales); err != nil {
+		return nil, errors.Wrap(err, "selecting sales")
+	}
+
+	return sales, nil
+}
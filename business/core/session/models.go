@@ -0,0 +1,19 @@
+package session
+
+import (
+	"time"
+)
+
+// RefreshToken is a single-use token that can be exchanged for a new access
+// token. Every exchange rotates it into a new token of the same family, so a
+// family tracks one login session from start to logout.
+type RefreshToken struct {
+	ID          string     `db:"token_id"`
+	FamilyID    string     `db:"family_id"`
+	UserID      string     `db:"user_id"`
+	TokenHash   string     `db:"token_hash"`
+	DateCreated time.Time  `db:"date_created"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+	DateRevoked *time.Time `db:"date_revoked"`
+}
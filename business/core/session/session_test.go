@@ -0,0 +1,118 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/session"
+	"github.com/danielmbirochi/go-sample-service/business/data/schema"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+
+	"github.com/pkg/errors"
+)
+
+func TestSession(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	s := session.New(log, db)
+
+	t.Log("Given the need to work with refresh tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen rotating the tokens of a single session.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			// Seeded regular user.
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			if err := schema.DeleteAll(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete all data : %s.", tests.Failed, testID, err)
+			}
+			if err := schema.Seed(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to seed the database : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to seed the database.", tests.Success, testID)
+
+			first, err := s.Create(ctx, traceID, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a session : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a session.", tests.Success, testID)
+
+			errGrant := errors.New("grant failed")
+			refuse := func(string) error { return errGrant }
+			if _, _, err := s.Refresh(ctx, traceID, first, now.Add(time.Minute), refuse); err != errGrant {
+				t.Fatalf("\t%s\tTest %d:\tShould get the error of a failed grant : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the error of a failed grant.", tests.Success, testID)
+
+			var granted string
+			grant := func(userID string) error {
+				granted = userID
+				return nil
+			}
+
+			gotUserID, second, err := s.Refresh(ctx, traceID, first, now.Add(time.Minute), grant)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to refresh the session after a failed grant : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to refresh the session after a failed grant.", tests.Success, testID)
+
+			if gotUserID != userID || granted != userID || second == first {
+				t.Fatalf("\t%s\tTest %d:\tShould get a new token for the same user : got %q, granted %q.", tests.Failed, testID, gotUserID, granted)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a new token for the same user.", tests.Success, testID)
+
+			if _, _, err := s.Refresh(ctx, traceID, first, now.Add(2*time.Minute), grant); errors.Cause(err) != session.ErrTokenReused {
+				t.Fatalf("\t%s\tTest %d:\tShould detect the reuse of a token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould detect the reuse of a token.", tests.Success, testID)
+
+			if _, _, err := s.Refresh(ctx, traceID, second, now.Add(3*time.Minute), grant); errors.Cause(err) != session.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the whole family after a reuse : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the whole family after a reuse.", tests.Success, testID)
+
+			third, err := s.Create(ctx, traceID, userID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create another session : %s.", tests.Failed, testID, err)
+			}
+
+			if _, _, err := s.Refresh(ctx, traceID, third, now.Add(session.RefreshTTL), grant); errors.Cause(err) != session.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT refresh an expired token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT refresh an expired token.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen revoking an access token.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			const jti = "6a1a4ec5-6c1b-4a5b-8a43-2b8f5e0f7d31"
+
+			revoked, err := s.IsRevoked(ctx, traceID, jti)
+			if err != nil || revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT report an unknown token as revoked : %v %s.", tests.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT report an unknown token as revoked.", tests.Success, testID)
+
+			if err := s.RevokeAccess(ctx, traceID, jti, now.Add(time.Hour), now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the token.", tests.Success, testID)
+
+			revoked, err = s.IsRevoked(ctx, traceID, jti)
+			if err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould report the token as revoked : %v %s.", tests.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the token as revoked.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package session contains usecases for refresh tokens and the revocation
+// of access tokens.
+package session
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/danielmbirochi/go-sample-service/foundation/token"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// RefreshTTL is how long a refresh token can be exchanged after being issued.
+const RefreshTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidToken occurs when a refresh token does not exist, expired or
+	// was revoked.
+	ErrInvalidToken = errors.New("invalid refresh token")
+
+	// ErrTokenReused occurs when a refresh token that was already exchanged is
+	// presented again. This means the token leaked, so its whole family is
+	// revoked.
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+type SessionService struct {
+	db  database.Executor
+	log *zap.SugaredLogger
+}
+
+// New is a factory method for constructing session service. Passing a
+// *sqlx.Tx makes the service join that transaction.
+func New(log *zap.SugaredLogger, db database.Executor) SessionService {
+	return SessionService{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create starts a new session for the user and returns its first refresh
+// token. Only a hash of the token is stored.
+func (ss SessionService) Create(ctx context.Context, traceID string, userID string, now time.Time) (string, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.session.Create")
+	defer span.End()
+
+	return ss.issue(ctx, ss.db, traceID, uuid.New().String(), userID, now)
+}
+
+// Refresh exchanges a refresh token for a new one of the same family and
+// returns the ID of the user that owns the session. Presenting a token that
+// was already exchanged revokes the family and returns ErrTokenReused.
+//
+// grant is called with the ID of the user within the exchange, to issue
+// whatever goes along with the new token. An error from grant rolls the
+// exchange back and is returned as is, so the token is not consumed and can
+// be presented again.
+func (ss SessionService) Refresh(ctx context.Context, traceID string, refreshToken string, now time.Time, grant func(userID string) error) (string, string, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.session.Refresh")
+	defer span.End()
+
+	var (
+		userID  string
+		newTkn  string
+		reused  bool
+		invalid bool
+	)
+
+	// A reused token must revoke its family and still report an error, so
+	// the transaction is committed and the outcome is returned afterwards.
+	err := database.WithinTran(ctx, ss.db, func(tx *sqlx.Tx) error {
+		const q = `
+		SELECT * 
+			FROM refresh_tokens 
+				WHERE token_hash = $1
+		FOR UPDATE
+		`
+
+		hash := token.Hash(refreshToken)
+		ss.log.Infof("%s : %s : query : %s", traceID, "session.Refresh",
+			database.Log(q, hash),
+		)
+
+		var rt RefreshToken
+		if err := tx.GetContext(ctx, &rt, q, hash); err != nil {
+			if err == sql.ErrNoRows {
+				invalid = true
+				return nil
+			}
+			return errors.Wrap(err, "selecting refresh token")
+		}
+
+		switch {
+		case rt.DateRevoked != nil, !now.Before(rt.DateExpires):
+			invalid = true
+			return nil
+
+		case rt.DateUsed != nil:
+			reused = true
+			return ss.revokeFamily(ctx, tx, traceID, rt.FamilyID, now)
+		}
+
+		if err := grant(rt.UserID); err != nil {
+			return err
+		}
+
+		const qUse = `
+		UPDATE refresh_tokens
+			SET
+				"date_used" = $2
+			WHERE token_id = $1
+		`
+
+		ss.log.Infof("%s : %s : query : %s", traceID, "session.Refresh",
+			database.Log(qUse, rt.ID, now.UTC()),
+		)
+
+		if _, err := tx.ExecContext(ctx, qUse, rt.ID, now.UTC()); err != nil {
+			return errors.Wrap(err, "marking refresh token as used")
+		}
+
+		var err error
+		newTkn, err = ss.issue(ctx, tx, traceID, rt.FamilyID, rt.UserID, now)
+		if err != nil {
+			return err
+		}
+		userID = rt.UserID
+
+		return nil
+	})
+
+	switch {
+	case err != nil:
+		return "", "", err
+	case reused:
+		return "", "", ErrTokenReused
+	case invalid:
+		return "", "", ErrInvalidToken
+	}
+
+	return userID, newTkn, nil
+}
+
+// Revoke ends the session the refresh token belongs to. The token must be
+// owned by the specified user.
+func (ss SessionService) Revoke(ctx context.Context, traceID string, userID string, refreshToken string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.session.Revoke")
+	defer span.End()
+
+	const q = `
+	SELECT family_id 
+		FROM refresh_tokens 
+			WHERE token_hash = $1 AND user_id = $2
+	`
+
+	hash := token.Hash(refreshToken)
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.Revoke",
+		database.Log(q, hash, userID),
+	)
+
+	var familyID string
+	if err := ss.db.GetContext(ctx, &familyID, q, hash, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidToken
+		}
+		return errors.Wrap(err, "selecting refresh token")
+	}
+
+	return ss.revokeFamily(ctx, ss.db, traceID, familyID, now)
+}
+
+// RevokeUser ends every session of the user, for instance after their
+// password was reset.
+func (ss SessionService) RevokeUser(ctx context.Context, traceID string, userID string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.session.RevokeUser")
+	defer span.End()
+
+	const q = `
+	UPDATE refresh_tokens
+		SET
+			"date_revoked" = $2
+		WHERE user_id = $1 AND date_revoked IS NULL
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.RevokeUser",
+		database.Log(q, userID, now.UTC()),
+	)
+
+	if _, err := ss.db.ExecContext(ctx, q, userID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking sessions of user %s", userID)
+	}
+
+	return nil
+}
+
+// RevokeAccess adds an access token to the revocation list. The entry is kept
+// until the token expires, after which it would be rejected anyway.
+func (ss SessionService) RevokeAccess(ctx context.Context, traceID string, jti string, expires time.Time, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.session.RevokeAccess")
+	defer span.End()
+
+	const qPurge = `
+	DELETE 
+		FROM revoked_tokens 
+			WHERE date_expires < $1
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.RevokeAccess",
+		database.Log(qPurge, now.UTC()),
+	)
+
+	if _, err := ss.db.ExecContext(ctx, qPurge, now.UTC()); err != nil {
+		return errors.Wrap(err, "purging expired revoked tokens")
+	}
+
+	const q = `
+	INSERT INTO revoked_tokens
+		(jti, date_expires)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.RevokeAccess",
+		database.Log(q, jti, expires.UTC()),
+	)
+
+	if _, err := ss.db.ExecContext(ctx, q, jti, expires.UTC()); err != nil {
+		return errors.Wrap(err, "inserting revoked token")
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the access token identified by jti is on the
+// revocation list. It implements auth.RevocationList.
+func (ss SessionService) IsRevoked(ctx context.Context, traceID string, jti string) (bool, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.session.IsRevoked")
+	defer span.End()
+
+	const q = `
+	SELECT EXISTS (
+		SELECT 1 
+			FROM revoked_tokens 
+				WHERE jti = $1
+	)
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.IsRevoked",
+		database.Log(q, jti),
+	)
+
+	var revoked bool
+	if err := ss.db.GetContext(ctx, &revoked, q, jti); err != nil {
+		return false, errors.Wrap(err, "selecting revoked token")
+	}
+
+	return revoked, nil
+}
+
+// issue stores a new refresh token for the family and returns its raw value.
+func (ss SessionService) issue(ctx context.Context, db database.Executor, traceID string, familyID string, userID string, now time.Time) (string, error) {
+	tkn, err := token.New()
+	if err != nil {
+		return "", errors.Wrap(err, "generating refresh token")
+	}
+
+	rt := RefreshToken{
+		ID:          uuid.New().String(),
+		FamilyID:    familyID,
+		UserID:      userID,
+		TokenHash:   token.Hash(tkn),
+		DateCreated: now.UTC(),
+		DateExpires: now.Add(RefreshTTL).UTC(),
+	}
+
+	const q = `
+	INSERT INTO refresh_tokens
+		(token_id, family_id, user_id, token_hash, date_created, date_expires)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.issue",
+		database.Log(q, rt.ID, rt.FamilyID, rt.UserID, rt.TokenHash, rt.DateCreated, rt.DateExpires),
+	)
+
+	if _, err := db.ExecContext(ctx, q, rt.ID, rt.FamilyID, rt.UserID, rt.TokenHash, rt.DateCreated, rt.DateExpires); err != nil {
+		return "", errors.Wrap(err, "inserting refresh token")
+	}
+
+	return tkn, nil
+}
+
+// revokeFamily revokes every token of a family that is not revoked yet.
+func (ss SessionService) revokeFamily(ctx context.Context, db database.Executor, traceID string, familyID string, now time.Time) error {
+	const q = `
+	UPDATE refresh_tokens
+		SET
+			"date_revoked" = $2
+		WHERE family_id = $1 AND date_revoked IS NULL
+	`
+
+	ss.log.Infof("%s : %s : query : %s", traceID, "session.revokeFamily",
+		database.Log(q, familyID, now.UTC()),
+	)
+
+	if _, err := db.ExecContext(ctx, q, familyID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "revoking refresh token family %s", familyID)
+	}
+
+	return nil
+}
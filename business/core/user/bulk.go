@@ -0,0 +1,242 @@
+package user
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BatchSize is the number of users inserted at once by an import.
+const BatchSize = 100
+
+// errRejected rolls back an import once one of its rows is rejected.
+var errRejected = errors.New("import has rejected rows")
+
+// RowDecoder is the source of the users of an import. Decode decodes the
+// next row into a *NewUser and returns io.EOF after the last row. A row that
+// can not be decoded or fails validation is reported with an error
+// implementing rowError, and decoding goes on with the next row. Any other
+// error aborts the import.
+type RowDecoder interface {
+	Decode(val interface{}) error
+}
+
+// rowError is implemented by the errors of a RowDecoder rejecting a single
+// row. Reasons describes what is wrong with the row.
+type rowError interface {
+	error
+	Reasons() []string
+}
+
+// RowError reports a row rejected by an import. Rows are numbered from 1,
+// not counting the header of a CSV file.
+type RowError struct {
+	Row    int      `json:"row"`
+	Email  string   `json:"email,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportReport is the outcome of an import. Users are only created when no
+// row was rejected and the import is not a dry run.
+type ImportReport struct {
+	Rows    int        `json:"rows"`
+	Created int        `json:"created"`
+	DryRun  bool       `json:"dry_run"`
+	Errors  []RowError `json:"errors"`
+}
+
+// Import creates the users read from rows on behalf of the claims subject.
+// Every row is held to the same rules as Create, so rows giving roles are
+// rejected unless the claims allow ActionUserGrant, and its email must be
+// neither taken nor repeated in the import. Users are inserted in batches
+// of BatchSize inside a single transaction, which is rolled back when any
+// row is rejected, so an import is all or nothing. Reading goes on after a
+// rejected row, so the report lists every rejected row at once.
+//
+// A dry run checks every row the same way but creates nothing. The emails
+// of deleted users can be taken by the imported ones.
+//
+// Imported users are not published as events: an import would push every
+// other event out of the buffer of the broker and drop its subscribers.
+func (us UserService) Import(ctx context.Context, traceID string, claims auth.Claims, rows RowDecoder, dryRun bool, now time.Time) (ImportReport, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Import")
+	defer span.End()
+
+	report := ImportReport{
+		DryRun: dryRun,
+		Errors: []RowError{},
+	}
+
+	// The grant is checked once, on the first row giving roles, so a denial
+	// is reported once per import.
+	var canGrant *bool
+	grants := func() bool {
+		if canGrant == nil {
+			ok := us.policy.Can(claims, auth.ActionUserGrant, auth.Resource{})
+			canGrant = &ok
+		}
+		return *canGrant
+	}
+
+	err := us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		seen := make(map[string]int)
+		batch := make([]User, 0, BatchSize)
+
+		flush := func() error {
+			if len(batch) == 0 || len(report.Errors) > 0 || dryRun {
+				batch = batch[:0]
+				return nil
+			}
+			if err := s.CreateBatch(ctx, traceID, batch); err != nil {
+				return errors.Wrap(err, "inserting batch")
+			}
+			for _, usr := range batch {
+				if err := us.record(ctx, traceID, audits, claims.Subject, auth.ActionUserCreate, User{}, usr, now); err != nil {
+					return err
+				}
+			}
+			report.Created += len(batch)
+			batch = batch[:0]
+			return nil
+		}
+
+		for {
+			var nu NewUser
+			err := rows.Decode(&nu)
+			if err == io.EOF {
+				break
+			}
+			report.Rows++
+
+			reject := func(reasons ...string) {
+				report.Errors = append(report.Errors, RowError{
+					Row:    report.Rows,
+					Email:  nu.Email,
+					Errors: reasons,
+				})
+			}
+
+			var invalid rowError
+			switch {
+			case errors.As(err, &invalid):
+				reject(invalid.Reasons()...)
+				continue
+			case err != nil:
+				return errors.Wrapf(err, "reading row %d", report.Rows)
+			}
+
+			if len(nu.Roles) > 0 && !grants() {
+				reject(ErrForbidden.Error())
+				continue
+			}
+
+			if row, ok := seen[nu.Email]; ok {
+				reject(errors.Errorf("email is repeated from row %d", row).Error())
+				continue
+			}
+			seen[nu.Email] = report.Rows
+
+			switch _, err := s.QueryByEmail(ctx, traceID, nu.Email); errors.Cause(err) {
+			case ErrNotFound:
+			case nil:
+				reject(ErrUniqueEmail.Error())
+				continue
+			default:
+				return errors.Wrapf(err, "checking email of row %d", report.Rows)
+			}
+
+			// Once a row is rejected, nothing will be inserted, so there is
+			// no point in paying for the password hash of the remaining ones.
+			if dryRun || len(report.Errors) > 0 {
+				continue
+			}
+
+			hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return errors.Wrap(err, "generating password hash")
+			}
+			batch = append(batch, User{
+				ID:           uuid.New().String(),
+				Name:         nu.Name,
+				Email:        nu.Email,
+				PasswordHash: hash,
+				Roles:        nu.Roles,
+				DateCreated:  now.UTC(),
+				DateUpdated:  now.UTC(),
+				Version:      1,
+			})
+
+			if len(batch) == BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+		if len(report.Errors) > 0 {
+			return errRejected
+		}
+		return nil
+	})
+
+	switch {
+	case err == errRejected:
+		report.Created = 0
+	case err != nil:
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
+
+// Stream yields every user matching the filter, from the oldest, one at a
+// time, so no more than a user is held in memory. The iterator must be
+// closed.
+func (us UserService) Stream(ctx context.Context, traceID string, filter QueryFilter) (Iterator, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Stream")
+	defer span.End()
+
+	it, err := us.storer.QueryIter(ctx, traceID, filter, DefaultOrderBy)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying users")
+	}
+
+	return it, nil
+}
+
+// Export calls fn for every user matching the filter, from the oldest, as
+// they are streamed. An error returned by fn stops the export.
+func (us UserService) Export(ctx context.Context, traceID string, filter QueryFilter, fn func(User) error) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Export")
+	defer span.End()
+
+	it, err := us.Stream(ctx, traceID, filter)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for {
+		usr, err := it.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading users")
+		}
+
+		if err := fn(usr); err != nil {
+			return err
+		}
+	}
+}
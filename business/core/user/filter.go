@@ -0,0 +1,117 @@
+package user
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidOrderBy occurs when a listing is requested to be sorted by a
+// field that is not allowed or in an unknown direction.
+var ErrInvalidOrderBy = errors.New("invalid order by")
+
+// QueryFilter holds the available fields a listing of users can be filtered
+// on. Nil fields are not applied. Email and Name match case-insensitive
+// substrings, and the creation date range is inclusive on the start only.
+// Deleted users are only listed when IncludeDeleted is set.
+type QueryFilter struct {
+	Role             *string    `json:"role"`
+	Email            *string    `json:"email"`
+	Name             *string    `json:"name"`
+	StartCreatedDate *time.Time `json:"created_after"`
+	EndCreatedDate   *time.Time `json:"created_before"`
+	IncludeDeleted   bool       `json:"include_deleted"`
+}
+
+// The set of fields a listing of users can be ordered by.
+const (
+	OrderByID          = "user_id"
+	OrderByName        = "name"
+	OrderByEmail       = "email"
+	OrderByDateCreated = "date_created"
+)
+
+// The set of directions a listing of users can be ordered in.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+// orderByFields maps the field names accepted from clients to the fields
+// a listing can be ordered by.
+var orderByFields = map[string]string{
+	"id":           OrderByID,
+	"name":         OrderByName,
+	"email":        OrderByEmail,
+	"date_created": OrderByDateCreated,
+}
+
+// OrderBy represents the field and direction a listing is sorted by. Ties
+// are always broken by user ID in the same direction.
+type OrderBy struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"`
+}
+
+// valid reports whether ob only references allowed fields and directions.
+func (ob OrderBy) valid() bool {
+	switch ob.Field {
+	case OrderByID, OrderByName, OrderByEmail, OrderByDateCreated:
+	default:
+		return false
+	}
+	return ob.Direction == ASC || ob.Direction == DESC
+}
+
+// DefaultOrderBy lists the oldest users first.
+var DefaultOrderBy = OrderBy{Field: OrderByDateCreated, Direction: ASC}
+
+// ParseOrderBy parses a "field,direction" value where direction is optional
+// and defaults to ascending. An empty value returns DefaultOrderBy.
+func ParseOrderBy(s string) (OrderBy, error) {
+	if s == "" {
+		return DefaultOrderBy, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) > 2 {
+		return OrderBy{}, errors.Wrapf(ErrInvalidOrderBy, "unknown format %q", s)
+	}
+
+	field, ok := orderByFields[strings.TrimSpace(parts[0])]
+	if !ok {
+		return OrderBy{}, errors.Wrapf(ErrInvalidOrderBy, "unknown field %q", parts[0])
+	}
+
+	ob := OrderBy{Field: field, Direction: ASC}
+	if len(parts) == 2 {
+		switch dir := strings.ToUpper(strings.TrimSpace(parts[1])); dir {
+		case ASC, DESC:
+			ob.Direction = dir
+		default:
+			return OrderBy{}, errors.Wrapf(ErrInvalidOrderBy, "unknown direction %q", parts[1])
+		}
+	}
+
+	return ob, nil
+}
+
+// dateLayout renders dates with a fixed width, so their string form sorts in
+// the same order as the dates themselves.
+const dateLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// OrderValue returns the value of the field usr is ordered by in the form
+// carried inside a Cursor.
+func OrderValue(usr User, field string) string {
+	switch field {
+	case OrderByName:
+		return usr.Name
+	case OrderByEmail:
+		return usr.Email
+	case OrderByDateCreated:
+		return usr.DateCreated.UTC().Format(dateLayout)
+	default:
+		return usr.ID
+	}
+}
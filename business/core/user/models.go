@@ -15,6 +15,9 @@ type User struct {
 	PasswordHash []byte         `db:"password_hash" json:"-"`
 	DateCreated  time.Time      `db:"date_created" json:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+	DateVerified *time.Time     `db:"date_verified" json:"date_verified,omitempty"`
+	DateDeleted  *time.Time     `db:"date_deleted" json:"date_deleted,omitempty"`
+	Version      int            `db:"version" json:"version"`
 }
 
 // NewUser contains information needed to create a new User.
@@ -29,11 +32,42 @@ type NewUser struct {
 // UpdateUser defines what information may be provided to modify an existing
 // User. All fields are optional so clients can send just the fields they want
 // to change. It uses pointer semantics for having nil values facilitating comparison
-// against it.
+// against it. CurrentPassword is only checked when users change their own
+// password. Version, when set, is the version of the user the client last
+// read, and the update is refused if the user changed since.
 type UpdateUser struct {
 	Name            *string  `json:"name"`
 	Email           *string  `json:"email" validate:"omitempty,email"`
 	Roles           []string `json:"roles"`
 	Password        *string  `json:"password"`
 	PasswordConfirm *string  `json:"password_confirm" validate:"omitempty,eqfield=Password"`
+	CurrentPassword *string  `json:"current_password"`
+	Version         *int     `json:"-"`
+}
+
+// PatchUser is the representation of a user that PATCH requests are applied
+// to. The patched document is validated like a new user, so a patch can
+// remove a single role but can not leave a user without a name or an email.
+// Passwords can not be patched.
+type PatchUser struct {
+	Name  string   `json:"name" validate:"required"`
+	Email string   `json:"email" validate:"required,email"`
+	Roles []string `json:"roles" validate:"required"`
+}
+
+// Cursor marks the position of the last user returned in a page. Listing
+// resumes right after it, following the same ordering. Value holds the
+// ordering field of that user as returned by OrderValue.
+type Cursor struct {
+	OrderBy OrderBy `json:"order_by"`
+	Value   string  `json:"value"`
+	ID      string  `json:"id"`
+}
+
+// Page is a set of users returned by a listing along with the position to
+// resume from. Next is only set when HasMore is true.
+type Page struct {
+	Users   []User
+	Next    *Cursor
+	HasMore bool
 }
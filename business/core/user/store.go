@@ -0,0 +1,59 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+)
+
+// Storer declares the behavior the user service needs from a persistence
+// layer. Implementations must return ErrNotFound when a requested user does
+// not exist and ErrUniqueEmail when an email is already taken. Query returns
+// at most limit users matching the filter, sorted by orderBy with ties broken
+// by user_id, starting right after the provided cursor, or from the beginning
+// when it is nil.
+//
+// QueryIter yields every user matching the filter, sorted like Query, one
+// at a time, so exporting many users does not hold them all in memory.
+//
+// CreateBatch inserts several users at once, and returns ErrUniqueEmail
+// when any of their emails is taken, in which case none is inserted.
+//
+// Every write increments the version of a user. Update only applies when
+// the stored version still matches the version of usr, and returns
+// ErrVersionConflict otherwise, so concurrent writers can not overwrite each
+// other.
+//
+// Delete only marks a user as deleted. Deleted users are treated as missing
+// by every query, unless a listing filter asks for them, until Restore
+// brings them back or Purge removes the ones deleted before a given time
+// for good. Emails are only unique among the users that are not deleted.
+// Restore returns ErrNotFound when the user is not deleted, ErrUniqueEmail
+// when another user took its email since, and the date the user was deleted
+// otherwise. Purge returns the IDs of the users it removed.
+//
+// WithinTran runs fn with a Storer and an audit.Storer bound to a single
+// transaction, so the changes made through the first and the events
+// recorded through the second are committed or rolled back together.
+type Storer interface {
+	WithinTran(ctx context.Context, fn func(s Storer, audits audit.Storer) error) error
+	Create(ctx context.Context, traceID string, usr User) error
+	CreateBatch(ctx context.Context, traceID string, usrs []User) error
+	Update(ctx context.Context, traceID string, usr User) error
+	Delete(ctx context.Context, traceID string, userID string, now time.Time) error
+	Restore(ctx context.Context, traceID string, userID string) (time.Time, error)
+	Purge(ctx context.Context, traceID string, before time.Time) ([]string, error)
+	Query(ctx context.Context, traceID string, filter QueryFilter, orderBy OrderBy, after *Cursor, limit int) ([]User, error)
+	QueryIter(ctx context.Context, traceID string, filter QueryFilter, orderBy OrderBy) (Iterator, error)
+	QueryByID(ctx context.Context, traceID string, userID string) (User, error)
+	QueryByEmail(ctx context.Context, traceID string, email string) (User, error)
+}
+
+// Iterator yields users one at a time. Next returns io.EOF after the last
+// user. Close releases the resources held by the iterator and must be
+// called once done with it.
+type Iterator interface {
+	Next() (User, error)
+	Close() error
+}
@@ -0,0 +1,412 @@
+// Package userdb contains the Postgres implementation of user.Storer.
+package userdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// uniqueViolation is the Postgres error code raised when a unique
+// constraint is violated.
+const uniqueViolation = "23505"
+
+// Store manages the set of APIs for user access in Postgres.
+type Store struct {
+	db  database.Executor
+	log *zap.SugaredLogger
+}
+
+// NewStore constructs a Postgres backed user store. Passing a *sqlx.Tx
+// makes the store join that transaction.
+func NewStore(log *zap.SugaredLogger, db database.Executor) Store {
+	return Store{
+		db:  db,
+		log: log,
+	}
+}
+
+// WithinTran runs fn with a user and an audit store joining the same
+// transaction. If the store already runs in a transaction, fn joins it.
+func (s Store) WithinTran(ctx context.Context, fn func(s user.Storer, audits audit.Storer) error) error {
+	return database.WithinTran(ctx, s.db, func(tx *sqlx.Tx) error {
+		return fn(NewStore(s.log, tx), auditdb.NewStore(s.log, tx))
+	})
+}
+
+// Create inserts a new user into the database.
+func (s Store) Create(ctx context.Context, traceID string, usr user.User) error {
+	const q = `
+	INSERT INTO users
+		(user_id, name, email, password_hash, roles, date_created, date_updated, date_verified, version)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.Create",
+		database.Log(q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated, usr.DateVerified, usr.Version),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated, usr.DateVerified, usr.Version); err != nil {
+		if isUniqueViolation(err) {
+			return user.ErrUniqueEmail
+		}
+		return errors.Wrap(err, "inserting user")
+	}
+
+	return nil
+}
+
+// CreateBatch adds several users to the database with a single INSERT
+// statement.
+func (s Store) CreateBatch(ctx context.Context, traceID string, usrs []user.User) error {
+	if len(usrs) == 0 {
+		return nil
+	}
+
+	const columns = 9
+
+	var b strings.Builder
+	b.WriteString(`
+	INSERT INTO users
+		(user_id, name, email, password_hash, roles, date_created, date_updated, date_verified, version)
+	VALUES `)
+
+	args := make([]interface{}, 0, len(usrs)*columns)
+	for i, usr := range usrs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		n := i * columns
+		fmt.Fprintf(&b, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+		args = append(args, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated, usr.DateVerified, usr.Version)
+	}
+	q := b.String()
+
+	// Logging every row would flood the log, so only the size of the batch
+	// is reported.
+	s.log.Infof("%s : %s : query : %d users", traceID, "user.CreateBatch", len(usrs))
+
+	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+		if isUniqueViolation(err) {
+			return user.ErrUniqueEmail
+		}
+		return errors.Wrap(err, "inserting users")
+	}
+
+	return nil
+}
+
+// Update replaces a user document in the database when it is still at the
+// version of usr. A user that changed and a user that is gone both leave no
+// row to update, and are reported as a conflict.
+func (s Store) Update(ctx context.Context, traceID string, usr user.User) error {
+	const q = `
+	UPDATE users 
+		SET
+			"name" = $2,
+			"email" = $3,
+			"roles" = $4,
+			"password_hash" = $5,
+			"date_updated" = $6,
+			"date_verified" = $7,
+			"version" = version + 1
+		WHERE user_id = $1 AND version = $8 AND date_deleted IS NULL
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.Update",
+		database.Log(q, usr.ID, usr.Name, usr.Email, usr.Roles, usr.PasswordHash, usr.DateUpdated, usr.DateVerified, usr.Version),
+	)
+
+	res, err := s.db.ExecContext(ctx, q, usr.ID, usr.Name, usr.Email, usr.Roles, usr.PasswordHash, usr.DateUpdated, usr.DateVerified, usr.Version)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return user.ErrUniqueEmail
+		}
+		return errors.Wrap(err, "updating user")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return user.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// Delete marks a user as deleted. Deleting a user twice keeps the first
+// deletion date.
+func (s Store) Delete(ctx context.Context, traceID string, userID string, now time.Time) error {
+	const q = `
+	UPDATE users
+		SET
+			"date_deleted" = $2,
+			"version" = version + 1
+		WHERE user_id = $1 AND date_deleted IS NULL
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.Delete",
+		database.Log(q, userID, now),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, userID, now); err != nil {
+		return errors.Wrapf(err, "deleting user %s", userID)
+	}
+
+	return nil
+}
+
+// Restore brings back a deleted user and returns the date it was deleted.
+func (s Store) Restore(ctx context.Context, traceID string, userID string) (time.Time, error) {
+	const q = `
+	WITH deleted AS (
+		SELECT user_id, date_deleted
+			FROM users
+				WHERE user_id = $1 AND date_deleted IS NOT NULL
+		FOR UPDATE
+	)
+	UPDATE users
+		SET
+			"date_deleted" = NULL,
+			"version" = version + 1
+		FROM deleted
+		WHERE users.user_id = deleted.user_id
+	RETURNING deleted.date_deleted
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.Restore",
+		database.Log(q, userID),
+	)
+
+	var deleted []time.Time
+	if err := s.db.SelectContext(ctx, &deleted, q, userID); err != nil {
+		if isUniqueViolation(err) {
+			return time.Time{}, user.ErrUniqueEmail
+		}
+		return time.Time{}, errors.Wrapf(err, "restoring user %s", userID)
+	}
+	if len(deleted) == 0 {
+		return time.Time{}, user.ErrNotFound
+	}
+
+	return deleted[0], nil
+}
+
+// Purge removes the users deleted before the given time from the database
+// and returns their IDs.
+func (s Store) Purge(ctx context.Context, traceID string, before time.Time) ([]string, error) {
+	const q = `
+	DELETE 
+		FROM users 
+			WHERE date_deleted < $1
+	RETURNING user_id
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.Purge",
+		database.Log(q, before),
+	)
+
+	var ids []string
+	if err := s.db.SelectContext(ctx, &ids, q, before); err != nil {
+		return nil, errors.Wrap(err, "purging users")
+	}
+
+	return ids, nil
+}
+
+// orderByColumns maps the fields a listing can be ordered by to their
+// column and SQL type. Only columns present here are ever written into a
+// query, which keeps the ORDER BY clause safe from injection.
+var orderByColumns = map[string]struct {
+	column string
+	typ    string
+}{
+	user.OrderByID:          {"user_id", "UUID"},
+	user.OrderByName:        {"name", "TEXT"},
+	user.OrderByEmail:       {"email", "TEXT"},
+	user.OrderByDateCreated: {"date_created", "TIMESTAMP"},
+}
+
+// Query retrieves a page of users matching the filter using keyset
+// pagination on the ordering column and user_id.
+func (s Store) Query(ctx context.Context, traceID string, filter user.QueryFilter, orderBy user.OrderBy, after *user.Cursor, limit int) ([]user.User, error) {
+	q, data, err := queryUsers(filter, orderBy, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.List",
+		database.Log(q, data),
+	)
+
+	var users []user.User
+	if err := database.NamedQuerySlice(ctx, s.db, q, data, &users); err != nil {
+		return nil, errors.Wrap(err, "selecting users")
+	}
+
+	return users, nil
+}
+
+// QueryIter retrieves every user matching the filter, one row at a time.
+func (s Store) QueryIter(ctx context.Context, traceID string, filter user.QueryFilter, orderBy user.OrderBy) (user.Iterator, error) {
+	q, data, err := queryUsers(filter, orderBy, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.QueryIter",
+		database.Log(q, data),
+	)
+
+	it, err := database.NamedQueryIter(ctx, s.db, q, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting users")
+	}
+
+	return iter{it}, nil
+}
+
+// iter yields the users of a query.
+type iter struct {
+	*database.Iter
+}
+
+// Next unmarshals the next user.
+func (it iter) Next() (user.User, error) {
+	var usr user.User
+	err := it.Iter.Next(&usr)
+	return usr, err
+}
+
+// queryUsers builds the query selecting the users matching the filter,
+// sorted by orderBy and user_id, starting right after the cursor when there
+// is one. A limit of zero selects every user.
+func queryUsers(filter user.QueryFilter, orderBy user.OrderBy, after *user.Cursor, limit int) (string, map[string]interface{}, error) {
+	col, ok := orderByColumns[orderBy.Field]
+	if !ok {
+		return "", nil, user.ErrInvalidOrderBy
+	}
+	dir, cmp := "ASC", ">"
+	if orderBy.Direction == user.DESC {
+		dir, cmp = "DESC", "<"
+	}
+
+	data := make(map[string]interface{})
+
+	var where []string
+	if !filter.IncludeDeleted {
+		where = append(where, "date_deleted IS NULL")
+	}
+	if filter.Role != nil {
+		data["role"] = *filter.Role
+		where = append(where, ":role = ANY(roles)")
+	}
+	if filter.Email != nil {
+		data["email"] = "%" + escapeLike(*filter.Email) + "%"
+		where = append(where, "email ILIKE :email")
+	}
+	if filter.Name != nil {
+		data["name"] = "%" + escapeLike(*filter.Name) + "%"
+		where = append(where, "name ILIKE :name")
+	}
+	if filter.StartCreatedDate != nil {
+		data["start_date_created"] = filter.StartCreatedDate.UTC()
+		where = append(where, "date_created >= :start_date_created")
+	}
+	if filter.EndCreatedDate != nil {
+		data["end_date_created"] = filter.EndCreatedDate.UTC()
+		where = append(where, "date_created < :end_date_created")
+	}
+	if after != nil {
+		data["cursor_value"] = after.Value
+		data["cursor_id"] = after.ID
+		where = append(where, fmt.Sprintf("(%s, user_id) %s (CAST(:cursor_value AS %s), CAST(:cursor_id AS UUID))", col.column, cmp, col.typ))
+	}
+
+	const q = `
+	SELECT * 
+		FROM users`
+
+	buf := bytes.NewBufferString(q)
+	if len(where) > 0 {
+		buf.WriteString(`
+			WHERE `)
+		buf.WriteString(strings.Join(where, " AND "))
+	}
+	fmt.Fprintf(buf, `
+	ORDER BY %s %s, user_id %s`, col.column, dir, dir)
+	if limit > 0 {
+		data["rows"] = limit
+		buf.WriteString(`
+	FETCH FIRST :rows ROWS ONLY`)
+	}
+
+	return buf.String(), data, nil
+}
+
+// QueryByID gets the specified user from the database.
+func (s Store) QueryByID(ctx context.Context, traceID string, userID string) (user.User, error) {
+	const q = `
+	SELECT * 
+		FROM users 
+			WHERE user_id = $1 AND date_deleted IS NULL
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.GetById",
+		database.Log(q, userID),
+	)
+
+	var usr user.User
+	if err := s.db.GetContext(ctx, &usr, q, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return user.User{}, user.ErrNotFound
+		}
+		return user.User{}, errors.Wrapf(err, "selecting user %q", userID)
+	}
+
+	return usr, nil
+}
+
+// QueryByEmail gets the specified user from the database by email.
+func (s Store) QueryByEmail(ctx context.Context, traceID string, email string) (user.User, error) {
+	const q = `
+	SELECT * 
+		FROM users 
+			WHERE email = $1 AND date_deleted IS NULL
+	`
+
+	s.log.Infof("%s : %s : query : %s", traceID, "user.GetByEmail",
+		database.Log(q, email),
+	)
+
+	var usr user.User
+	if err := s.db.GetContext(ctx, &usr, q, email); err != nil {
+		if err == sql.ErrNoRows {
+			return user.User{}, user.ErrNotFound
+		}
+		return user.User{}, errors.Wrapf(err, "selecting user %q", email)
+	}
+
+	return usr, nil
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern, so user input
+// is always matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// isUniqueViolation reports whether err was raised by a unique constraint.
+func isUniqueViolation(err error) bool {
+	pqErr, ok := errors.Cause(err).(*pq.Error)
+	return ok && pqErr.Code == uniqueViolation
+}
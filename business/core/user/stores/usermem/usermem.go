@@ -0,0 +1,339 @@
+// Package usermem contains an in-memory implementation of user.Storer. It is
+// meant for tests and local development where a database is not available.
+package usermem
+
+import (
+	"context"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditmem"
+	"github.com/danielmbirochi/go-sample-service/business/core/user"
+)
+
+// Store keeps users, and the audit events recorded along with their
+// changes, in memory. It is safe for concurrent use.
+type Store struct {
+	mu     sync.RWMutex
+	users  map[string]user.User
+	audits *auditmem.Store
+}
+
+// NewStore constructs an empty in-memory user store.
+func NewStore() *Store {
+	return &Store{
+		users:  make(map[string]user.User),
+		audits: auditmem.NewStore(),
+	}
+}
+
+// Audits returns the store holding the audit events committed by
+// WithinTran.
+func (s *Store) Audits() *auditmem.Store {
+	return s.audits
+}
+
+// WithinTran runs fn with the store and a pending set of audit events. When
+// fn fails, the users are restored as they were and the events are dropped.
+// Unlike a database transaction, fn is not isolated from concurrent writers.
+func (s *Store) WithinTran(ctx context.Context, fn func(s user.Storer, audits audit.Storer) error) error {
+	s.mu.RLock()
+	snapshot := make(map[string]user.User, len(s.users))
+	for id, usr := range s.users {
+		snapshot[id] = clone(usr)
+	}
+	s.mu.RUnlock()
+
+	pending := auditmem.NewStore()
+	if err := fn(s, pending); err != nil {
+		s.mu.Lock()
+		s.users = snapshot
+		s.mu.Unlock()
+		return err
+	}
+
+	for _, ev := range pending.Events() {
+		if err := s.audits.Create(ctx, ev.TraceID, ev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Create adds a new user to the store.
+func (s *Store) Create(ctx context.Context, traceID string, usr user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(usr.Email, usr.ID) {
+		return user.ErrUniqueEmail
+	}
+
+	s.users[usr.ID] = clone(usr)
+	return nil
+}
+
+// CreateBatch adds several users to the store, or none of them when any of
+// their emails is taken.
+func (s *Store) CreateBatch(ctx context.Context, traceID string, usrs []user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	emails := make(map[string]bool, len(usrs))
+	for _, usr := range usrs {
+		if emails[usr.Email] || s.emailTaken(usr.Email, usr.ID) {
+			return user.ErrUniqueEmail
+		}
+		emails[usr.Email] = true
+	}
+
+	for _, usr := range usrs {
+		s.users[usr.ID] = clone(usr)
+	}
+	return nil
+}
+
+// Update replaces a stored user when it is still at the version of usr.
+// Like the UPDATE statement, it can not tell a user that changed from one
+// that is gone, and reports both as a conflict.
+func (s *Store) Update(ctx context.Context, traceID string, usr user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, exists := s.users[usr.ID]
+	if !exists || cur.DateDeleted != nil || cur.Version != usr.Version {
+		return user.ErrVersionConflict
+	}
+
+	if s.emailTaken(usr.Email, usr.ID) {
+		return user.ErrUniqueEmail
+	}
+
+	usr = clone(usr)
+	usr.Version++
+	s.users[usr.ID] = usr
+	return nil
+}
+
+// Delete marks a user as deleted.
+func (s *Store) Delete(ctx context.Context, traceID string, userID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists || usr.DateDeleted != nil {
+		return nil
+	}
+
+	usr.DateDeleted = &now
+	usr.Version++
+	s.users[userID] = usr
+	return nil
+}
+
+// Restore brings back a deleted user and returns the date it was deleted.
+func (s *Store) Restore(ctx context.Context, traceID string, userID string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usr, exists := s.users[userID]
+	if !exists || usr.DateDeleted == nil {
+		return time.Time{}, user.ErrNotFound
+	}
+	if s.emailTaken(usr.Email, usr.ID) {
+		return time.Time{}, user.ErrUniqueEmail
+	}
+
+	deleted := *usr.DateDeleted
+	usr.DateDeleted = nil
+	usr.Version++
+	s.users[userID] = usr
+	return deleted, nil
+}
+
+// Purge removes the users deleted before the given time from the store and
+// returns their IDs.
+func (s *Store) Purge(ctx context.Context, traceID string, before time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, usr := range s.users {
+		if usr.DateDeleted != nil && usr.DateDeleted.Before(before) {
+			delete(s.users, id)
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// Query retrieves a page of users matching the filter, sorted by orderBy.
+func (s *Store) Query(ctx context.Context, traceID string, filter user.QueryFilter, orderBy user.OrderBy, after *user.Cursor, limit int) ([]user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	desc := orderBy.Direction == user.DESC
+
+	users := make([]user.User, 0, len(s.users))
+	for _, usr := range s.users {
+		if !match(filter, usr) {
+			continue
+		}
+		if after != nil && !before(after.Value, after.ID, usr, orderBy.Field, desc) {
+			continue
+		}
+		users = append(users, clone(usr))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return before(user.OrderValue(users[i], orderBy.Field), users[i].ID, users[j], orderBy.Field, desc)
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+// QueryIter yields a snapshot of the users matching the filter.
+func (s *Store) QueryIter(ctx context.Context, traceID string, filter user.QueryFilter, orderBy user.OrderBy) (user.Iterator, error) {
+	users, err := s.Query(ctx, traceID, filter, orderBy, nil, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &iter{users: users}, nil
+}
+
+// iter yields the users of a slice.
+type iter struct {
+	users []user.User
+}
+
+// Next returns the next user of the slice.
+func (it *iter) Next() (user.User, error) {
+	if len(it.users) == 0 {
+		return user.User{}, io.EOF
+	}
+
+	usr := it.users[0]
+	it.users = it.users[1:]
+	return usr, nil
+}
+
+// Close drops the users left.
+func (it *iter) Close() error {
+	it.users = nil
+	return nil
+}
+
+// QueryByID gets the specified user from the store.
+func (s *Store) QueryByID(ctx context.Context, traceID string, userID string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usr, exists := s.users[userID]
+	if !exists || usr.DateDeleted != nil {
+		return user.User{}, user.ErrNotFound
+	}
+
+	return clone(usr), nil
+}
+
+// QueryByEmail gets the specified user from the store by email.
+func (s *Store) QueryByEmail(ctx context.Context, traceID string, email string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, usr := range s.users {
+		if usr.Email == email && usr.DateDeleted == nil {
+			return clone(usr), nil
+		}
+	}
+
+	return user.User{}, user.ErrNotFound
+}
+
+// emailTaken reports whether a user other than userID owns the email. The
+// caller must hold the lock.
+func (s *Store) emailTaken(email string, userID string) bool {
+	for _, usr := range s.users {
+		if usr.Email == email && usr.ID != userID && usr.DateDeleted == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// before reports whether the position (value, id) sorts before usr when
+// ordering by field in the given direction.
+func before(value string, id string, usr user.User, field string, desc bool) bool {
+	other := user.OrderValue(usr, field)
+	if value == other {
+		value, other = id, usr.ID
+	}
+	if desc {
+		return value > other
+	}
+	return value < other
+}
+
+// match reports whether usr satisfies every field set in the filter.
+func match(filter user.QueryFilter, usr user.User) bool {
+	if !filter.IncludeDeleted && usr.DateDeleted != nil {
+		return false
+	}
+	if filter.Role != nil {
+		var found bool
+		for _, role := range usr.Roles {
+			if role == *filter.Role {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.Email != nil && !strings.Contains(strings.ToLower(usr.Email), strings.ToLower(*filter.Email)) {
+		return false
+	}
+	if filter.Name != nil && !strings.Contains(strings.ToLower(usr.Name), strings.ToLower(*filter.Name)) {
+		return false
+	}
+	if filter.StartCreatedDate != nil && usr.DateCreated.Before(*filter.StartCreatedDate) {
+		return false
+	}
+	if filter.EndCreatedDate != nil && !usr.DateCreated.Before(*filter.EndCreatedDate) {
+		return false
+	}
+	return true
+}
+
+// clone returns a copy of usr that does not share memory with it, so callers
+// can not mutate the stored value through slices.
+func clone(usr user.User) user.User {
+	if usr.Roles != nil {
+		usr.Roles = append(usr.Roles[:0:0], usr.Roles...)
+	}
+	if usr.PasswordHash != nil {
+		usr.PasswordHash = append(usr.PasswordHash[:0:0], usr.PasswordHash...)
+	}
+	if usr.DateVerified != nil {
+		verified := *usr.DateVerified
+		usr.DateVerified = &verified
+	}
+	if usr.DateDeleted != nil {
+		deleted := *usr.DateDeleted
+		usr.DateDeleted = &deleted
+	}
+	return usr
+}
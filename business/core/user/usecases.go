@@ -2,15 +2,15 @@
 package user
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
 	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
-	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
@@ -28,28 +28,96 @@ var (
 	// anything goes wrong.
 	ErrAuthenticationFailure = errors.New("authentication failed")
 
+	// ErrUniqueEmail occurs when a user is created or updated with an email
+	// that already belongs to another user.
+	ErrUniqueEmail = errors.New("email is not unique")
+
+	// ErrInvalidCursor occurs when a cursor is used with an ordering other than
+	// the one it was issued for.
+	ErrInvalidCursor = errors.New("cursor does not match the requested ordering")
+
 	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
 	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrUnverifiedEmail occurs when a user with the right password attempts
+	// to authenticate before verifying their email, and verification is
+	// required.
+	ErrUnverifiedEmail = errors.New("email is not verified")
+
+	// ErrWrongPassword occurs when users change their own password without
+	// providing their current password.
+	ErrWrongPassword = errors.New("current password is missing or wrong")
+
+	// ErrVersionConflict occurs when a user is updated based on a version
+	// that is no longer the current one.
+	ErrVersionConflict = errors.New("user has been modified")
+)
+
+// AuditTarget is the type of target of the audit events about users.
+const AuditTarget = "user"
+
+// These are the types of the events published about users. Their data is
+// the user after the change.
+const (
+	EventCreated = "user.created"
+	EventUpdated = "user.updated"
+	EventDeleted = "user.deleted"
+)
+
+// These are the boundaries for the number of users returned per page.
+const (
+	DefaultRowsPerPage = 20
+	MaxRowsPerPage     = 100
 )
 
+// AccessTokenTTL is the lifetime of the access tokens issued to users. A
+// rotated signing key must remain valid for verification at least this long.
+const AccessTokenTTL = time.Hour
+
 type UserService struct {
-	db  *sqlx.DB
-	log *zap.SugaredLogger
+	storer          Storer
+	policy          *auth.Policy
+	requireVerified bool
+	events          *events.Broker
+	log             *zap.SugaredLogger
 }
 
-// New is a factory method for constructing user service.
-func New(log *zap.SugaredLogger, sqlxDB *sqlx.DB) UserService {
+// New is a factory method for constructing user service. The storer is in
+// charge of persisting users, which keeps the business rules in this package
+// independent of the database. The policy decides who can access a user.
+func New(log *zap.SugaredLogger, storer Storer, policy *auth.Policy) UserService {
 	return UserService{
-		db:  sqlxDB,
-		log: log,
+		storer: storer,
+		policy: policy,
+		log:    log,
 	}
 }
 
-// Create inserts a new user into the database.
-func (us UserService) Create(ctx context.Context, traceID string, nu NewUser, now time.Time) (User, error) {
+// RequireVerifiedEmail returns a copy of the service that refuses to
+// authenticate users who have not verified their email yet.
+func (us UserService) RequireVerifiedEmail() UserService {
+	us.requireVerified = true
+	return us
+}
+
+// PublishEvents returns a copy of the service that publishes the users it
+// creates, updates and deletes to the broker, once the change is committed.
+func (us UserService) PublishEvents(b *events.Broker) UserService {
+	us.events = b
+	return us
+}
+
+// Create inserts a new user into the database on behalf of the claims
+// subject. Its email is not verified. Like on updates, giving the user roles
+// takes ActionUserGrant.
+func (us UserService) Create(ctx context.Context, traceID string, claims auth.Claims, nu NewUser, now time.Time) (User, error) {
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Create")
 	defer span.End()
 
+	if len(nu.Roles) > 0 && !us.policy.Can(claims, auth.ActionUserGrant, auth.Resource{}) {
+		return User{}, ErrForbidden
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, errors.Wrap(err, "generating password hash")
@@ -63,135 +131,266 @@ func (us UserService) Create(ctx context.Context, traceID string, nu NewUser, no
 		Roles:        nu.Roles,
 		DateCreated:  now.UTC(),
 		DateUpdated:  now.UTC(),
+		Version:      1,
 	}
 
-	const q = `
-	INSERT INTO users
-		(user_id, name, email, password_hash, roles, date_created, date_updated)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-
-	us.log.Infof("%s : %s : query : %s", traceID, "user.Create",
-		database.Log(q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated),
-	)
-
-	if _, err = us.db.ExecContext(ctx, q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated); err != nil {
-		return User{}, errors.Wrap(err, "inserting user")
+	err = us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		if err := s.Create(ctx, traceID, usr); err != nil {
+			return err
+		}
+		return us.record(ctx, traceID, audits, claims.Subject, auth.ActionUserCreate, User{}, usr, now)
+	})
+	if err != nil {
+		return User{}, err
 	}
+	us.publish(EventCreated, usr)
 
 	return usr, nil
 }
 
-// Update replaces a user document in the database.
+// Update replaces a user document in the database. Users allowed to update
+// a record can change its name, email and password, but changing its roles is
+// a separate grant, so users can not elevate themselves. Users changing their
+// own password must provide the current one. It returns ErrVersionConflict
+// when the user is no longer at the expected version.
 func (us UserService) Update(ctx context.Context, traceID string, claims auth.Claims, id string, uu UpdateUser, now time.Time) error {
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Update")
 	defer span.End()
 
-	usr, err := us.GetById(ctx, traceID, claims, id)
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	if !us.policy.Can(claims, auth.ActionUserUpdate, auth.Resource{OwnerID: id}) {
+		return ErrForbidden
+	}
+
+	var usr User
+	err := us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		old, err := s.QueryByID(ctx, traceID, id)
+		if err != nil {
+			return err
+		}
+
+		if uu.Version != nil && *uu.Version != old.Version {
+			return ErrVersionConflict
+		}
+
+		// Roles are granted to a user, never owned by them, so the check does
+		// not take a resource owner into account.
+		if uu.Roles != nil && !sameRoles(uu.Roles, old.Roles) {
+			if !us.policy.Can(claims, auth.ActionUserGrant, auth.Resource{}) {
+				return ErrForbidden
+			}
+		}
+
+		if uu.Password != nil && claims.Subject == id {
+			if uu.CurrentPassword == nil {
+				return ErrWrongPassword
+			}
+			if err := bcrypt.CompareHashAndPassword(old.PasswordHash, []byte(*uu.CurrentPassword)); err != nil {
+				return ErrWrongPassword
+			}
+		}
+
+		usr = old
+		if uu.Name != nil {
+			usr.Name = *uu.Name
+		}
+		if uu.Email != nil && *uu.Email != usr.Email {
+			usr.Email = *uu.Email
+			usr.DateVerified = nil
+		}
+		if uu.Roles != nil {
+			usr.Roles = uu.Roles
+		}
+		if uu.Password != nil {
+			pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return errors.Wrap(err, "generating password hash")
+			}
+			usr.PasswordHash = pw
+		}
+		usr.DateUpdated = now
+
+		if err := s.Update(ctx, traceID, usr); err != nil {
+			return err
+		}
+		return us.record(ctx, traceID, audits, claims.Subject, auth.ActionUserUpdate, old, usr, now)
+	})
 	if err != nil {
 		return err
 	}
+	usr.Version++
+	us.publish(EventUpdated, usr)
 
-	if uu.Name != nil {
-		usr.Name = *uu.Name
-	}
-	if uu.Email != nil {
-		usr.Email = *uu.Email
+	return nil
+}
+
+// Delete marks a user as deleted. Deleted users can not authenticate and are
+// left out of the queries, but their record is kept, along with the history
+// referencing it, until it is purged. It returns ErrNotFound when the user
+// does not exist or is already deleted.
+func (us UserService) Delete(ctx context.Context, traceID string, claims auth.Claims, id string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Delete")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
 	}
-	if uu.Roles != nil {
-		usr.Roles = uu.Roles
+
+	if !us.policy.Can(claims, auth.ActionUserDelete, auth.Resource{OwnerID: id}) {
+		return ErrForbidden
 	}
-	if uu.Password != nil {
-		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
+
+	var usr User
+	err := us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		old, err := s.QueryByID(ctx, traceID, id)
 		if err != nil {
-			return errors.Wrap(err, "generating password hash")
+			return err
 		}
-		usr.PasswordHash = pw
-	}
-	usr.DateUpdated = now
-
-	const q = `
-	UPDATE users 
-		SET
-			"name" = $2,
-			"email" = $3,
-			"roles" = $4,
-			"password_hash" = $5,
-			"date_updated" = $6
-		WHERE user_id = $1
-	`
 
-	us.log.Infof("%s : %s : query : %s", traceID, "user.Update",
-		database.Log(q, usr.ID, usr.Name, usr.Email, usr.Roles, usr.PasswordHash, usr.DateCreated, usr.DateUpdated),
-	)
+		if err := s.Delete(ctx, traceID, id, now.UTC()); err != nil {
+			return err
+		}
 
-	if _, err = us.db.ExecContext(ctx, q, id, usr.Name, usr.Email, usr.Roles, usr.PasswordHash, usr.DateUpdated); err != nil {
-		return errors.Wrap(err, "updating user")
+		usr = old
+		deleted := now.UTC()
+		usr.DateDeleted = &deleted
+		return us.record(ctx, traceID, audits, claims.Subject, auth.ActionUserDelete, old, usr, now)
+	})
+	if err != nil {
+		return err
 	}
+	usr.Version++
+	us.publish(EventDeleted, usr)
 
 	return nil
 }
 
-// Delete removes a user from the database.
-func (us UserService) Delete(ctx context.Context, traceID string, id string) error {
-	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Delete")
+// Restore brings back a deleted user. It returns ErrNotFound when the user
+// does not exist or is not deleted, and ErrUniqueEmail when its email was
+// taken by another user since it was deleted.
+func (us UserService) Restore(ctx context.Context, traceID string, claims auth.Claims, id string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Restore")
 	defer span.End()
 
 	if _, err := uuid.Parse(id); err != nil {
 		return ErrInvalidID
 	}
 
-	const q = `
-	DELETE 
-		FROM users 
-			WHERE user_id = $1
-	`
+	if !us.policy.Can(claims, auth.ActionUserRestore, auth.Resource{OwnerID: id}) {
+		return ErrForbidden
+	}
+
+	var usr User
+	err := us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		deleted, err := s.Restore(ctx, traceID, id)
+		if err != nil {
+			return err
+		}
 
-	us.log.Infof("%s : %s : query : %s", traceID, "user.Delete",
-		database.Log(q, id),
-	)
+		ne := audit.NewEvent{
+			ActorID:    claims.Subject,
+			Action:     auth.ActionUserRestore,
+			TargetType: AuditTarget,
+			TargetID:   id,
+			Changes:    audit.Changes{{Field: "date_deleted", Old: deleted}},
+		}
+		if _, err := audit.New(us.log, audits).Record(ctx, traceID, ne, now); err != nil {
+			return err
+		}
 
-	if _, err := us.db.ExecContext(ctx, q, id); err != nil {
-		return errors.Wrapf(err, "deleting user %s", id)
+		usr, err = s.QueryByID(ctx, traceID, id)
+		return err
+	})
+	if err != nil {
+		return err
 	}
+	us.publish(EventUpdated, usr)
 
 	return nil
 }
 
-// List retrieves a list of existing users from the database.
-// PS: List func needs to be updated for supporting data pagination.
-func (us UserService) List(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]User, error) {
+// Purge removes for good the users deleted before the given time and returns
+// how many were removed. Purging is done by the system, so the events it
+// records have no actor.
+func (us UserService) Purge(ctx context.Context, traceID string, before time.Time, now time.Time) (int, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Purge")
+	defer span.End()
+
+	var purged int
+	err := us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		ids, err := s.Purge(ctx, traceID, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		as := audit.New(us.log, audits)
+		for _, id := range ids {
+			ne := audit.NewEvent{
+				Action:     auth.ActionUserPurge,
+				TargetType: AuditTarget,
+				TargetID:   id,
+			}
+			if _, err := as.Record(ctx, traceID, ne, now); err != nil {
+				return err
+			}
+		}
+
+		purged = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// List retrieves a page of existing users matching the filter and sorted by
+// orderBy. Listing starts right after the provided cursor, or from the first
+// user when it is nil. The number of rows is capped by MaxRowsPerPage.
+func (us UserService) List(ctx context.Context, traceID string, filter QueryFilter, orderBy OrderBy, after *Cursor, rowsPerPage int) (Page, error) {
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.List")
 	defer span.End()
 
-	data := struct {
-		Offset      int `db:"offset"`
-		RowsPerPage int `db:"rows_per_page"`
-	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
-	}
-
-	const q = `
-	SELECT * 
-		FROM users
-	ORDER BY user_id
-	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY
-	`
-
-	us.log.Infof("%s : %s : query : %s", traceID, "user.List",
-		database.Log(q, data),
-	)
-
-	var users []User
-	if err := database.NamedQuerySlice(ctx, us.db, q, data, &users); err != nil {
-		if err == database.ErrNotFound {
-			return nil, database.ErrNotFound
+	if !orderBy.valid() {
+		return Page{}, ErrInvalidOrderBy
+	}
+	if after != nil && after.OrderBy != orderBy {
+		return Page{}, ErrInvalidCursor
+	}
+
+	switch {
+	case rowsPerPage < 1:
+		rowsPerPage = DefaultRowsPerPage
+	case rowsPerPage > MaxRowsPerPage:
+		rowsPerPage = MaxRowsPerPage
+	}
+
+	// Ask for one extra row to find out if there is a next page.
+	users, err := us.storer.Query(ctx, traceID, filter, orderBy, after, rowsPerPage+1)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{
+		Users: users,
+	}
+	if len(users) > rowsPerPage {
+		page.Users = users[:rowsPerPage]
+		page.HasMore = true
+
+		last := page.Users[rowsPerPage-1]
+		page.Next = &Cursor{
+			OrderBy: orderBy,
+			Value:   OrderValue(last, orderBy.Field),
+			ID:      last.ID,
 		}
-		return nil, errors.Wrap(err, "selecting users")
 	}
 
-	return users, nil
+	return page, nil
 }
 
 // GetById gets the specified user from the database.
@@ -203,63 +402,114 @@ func (us UserService) GetById(ctx context.Context, traceID string, claims auth.C
 		return User{}, ErrInvalidID
 	}
 
-	// Only admins and the own user can access such record.
-	if !claims.HasRole(auth.RoleAdmin) && claims.Subject != userID {
+	if !us.policy.Can(claims, auth.ActionUserRead, auth.Resource{OwnerID: userID}) {
 		return User{}, ErrForbidden
 	}
 
-	const q = `
-	SELECT * 
-		FROM users 
-			WHERE user_id = $1
-	`
+	return us.storer.QueryByID(ctx, traceID, userID)
+}
 
-	us.log.Infof("%s : %s : query : %s", traceID, "user.GetById",
-		database.Log(q, userID),
-	)
+// GetByEmail gets the specified user from the database.
+func (us UserService) GetByEmail(ctx context.Context, traceID string, claims auth.Claims, email string) (User, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.GetByEmail")
+	defer span.End()
 
-	var u User
-	if err := us.db.GetContext(ctx, &u, q, userID); err != nil {
-		if err == sql.ErrNoRows {
-			return User{}, ErrNotFound
-		}
-		return User{}, errors.Wrapf(err, "selecting user %q", userID)
+	usr, err := us.storer.QueryByEmail(ctx, traceID, email)
+	if err != nil {
+		return User{}, err
 	}
 
-	return u, nil
+	if !us.policy.Can(claims, auth.ActionUserRead, auth.Resource{OwnerID: usr.ID}) {
+		return User{}, ErrForbidden
+	}
+
+	return usr, nil
 }
 
-// GetByEmail gets the specified user from the database.
-func (us UserService) GetByEmail(ctx context.Context, traceID string, claims auth.Claims, email string) (User, error) {
-	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.GetByEmail")
+// FindByEmail gets the specified user from the database without any access
+// control. It is meant for the flows where a user proves who they are by
+// receiving an email, and its result must never be sent back to the client.
+func (us UserService) FindByEmail(ctx context.Context, traceID string, email string) (User, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.FindByEmail")
 	defer span.End()
 
-	const q = `
-	SELECT * 
-		FROM users 
-			WHERE email = $1
-	`
+	return us.storer.QueryByEmail(ctx, traceID, email)
+}
 
-	us.log.Infof("%s : %s : query : %s", traceID, "user.GetByEmail",
-		database.Log(q, email),
-	)
+// ResetPassword replaces the password of a user who proved they own their
+// email, which also verifies it. The user is recorded as the actor.
+func (us UserService) ResetPassword(ctx context.Context, traceID string, userID string, password string, now time.Time) (User, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.ResetPassword")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, errors.Wrap(err, "generating password hash")
+	}
 
 	var usr User
-	if err := us.db.GetContext(ctx, &usr, q, email); err != nil {
-		if err == sql.ErrNoRows {
-			return User{}, ErrNotFound
+	err = us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		old, err := s.QueryByID(ctx, traceID, userID)
+		if err != nil {
+			return err
 		}
-		return User{}, errors.Wrapf(err, "selecting user %q", email)
-	}
 
-	// Only admins and the own user can access such record.
-	if !claims.HasRole(auth.RoleAdmin) && claims.Subject != usr.ID {
-		return User{}, ErrForbidden
+		usr = old
+		usr.PasswordHash = hash
+		usr.DateUpdated = now
+		if usr.DateVerified == nil {
+			verified := now
+			usr.DateVerified = &verified
+		}
+
+		if err := s.Update(ctx, traceID, usr); err != nil {
+			return err
+		}
+		return us.record(ctx, traceID, audits, userID, auth.ActionUserUpdate, old, usr, now)
+	})
+	if err != nil {
+		return User{}, err
 	}
+	usr.Version++
+	us.publish(EventUpdated, usr)
 
 	return usr, nil
 }
 
+// VerifyEmail marks the email of a user as verified. The user is recorded as
+// the actor.
+func (us UserService) VerifyEmail(ctx context.Context, traceID string, userID string, now time.Time) error {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.VerifyEmail")
+	defer span.End()
+
+	var usr User
+	err := us.storer.WithinTran(ctx, func(s Storer, audits audit.Storer) error {
+		old, err := s.QueryByID(ctx, traceID, userID)
+		if err != nil {
+			return err
+		}
+		if old.DateVerified != nil {
+			return nil
+		}
+
+		usr = old
+		usr.DateVerified = &now
+		usr.DateUpdated = now
+
+		if err := s.Update(ctx, traceID, usr); err != nil {
+			return err
+		}
+		return us.record(ctx, traceID, audits, userID, auth.ActionUserUpdate, old, usr, now)
+	})
+	if err != nil || usr.ID == "" {
+		return err
+	}
+	usr.Version++
+	us.publish(EventUpdated, usr)
+
+	return nil
+}
+
 // Authenticate finds a user by their email and verifies their password. On
 // success it returns a Claims value representing this user. The claims can be
 // used to generate a token for future authentication.
@@ -267,22 +517,12 @@ func (us UserService) Authenticate(ctx context.Context, traceID string, now time
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Authenticate")
 	defer span.End()
 
-	const q = `
-	SELECT * 
-		FROM users 
-			WHERE email = $1
-	`
-
-	us.log.Infof("%s: %s: %s", traceID, "user.Authenticate",
-		database.Log(q, email),
-	)
-
-	var u User
-	if err := us.db.GetContext(ctx, &u, q, email); err != nil {
+	u, err := us.storer.QueryByEmail(ctx, traceID, email)
+	if err != nil {
 
 		// Normally we would return ErrNotFound in this scenario but we do not want
 		// to leak to an unauthenticated user which emails are in the system.
-		if err == sql.ErrNoRows {
+		if err == ErrNotFound {
 			return auth.Claims{}, ErrAuthenticationFailure
 		}
 
@@ -295,16 +535,106 @@ func (us UserService) Authenticate(ctx context.Context, traceID string, now time
 		return auth.Claims{}, ErrAuthenticationFailure
 	}
 
-	claims := auth.Claims{
+	// Only tell about the verification once the password is known to be
+	// right, so it can not be used to find out which emails are registered.
+	if us.requireVerified && u.DateVerified == nil {
+		return auth.Claims{}, ErrUnverifiedEmail
+	}
+
+	return newClaims(u), nil
+}
+
+// Reauthenticate returns the claims for an existing user without checking
+// their password. It is used to issue a new access token when a session is
+// refreshed, so a user that no longer exists, or whose email is required
+// but not verified, fails to authenticate.
+func (us UserService) Reauthenticate(ctx context.Context, traceID string, now time.Time, userID string) (auth.Claims, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.user.Reauthenticate")
+	defer span.End()
+
+	u, err := us.storer.QueryByID(ctx, traceID, userID)
+	if err != nil {
+		if err == ErrNotFound {
+			return auth.Claims{}, ErrAuthenticationFailure
+		}
+
+		return auth.Claims{}, errors.Wrap(err, "selecting single user")
+	}
+
+	if us.requireVerified && u.DateVerified == nil {
+		return auth.Claims{}, ErrAuthenticationFailure
+	}
+
+	return newClaims(u), nil
+}
+
+// sameRoles reports whether both sets hold the same roles, in any order.
+func sameRoles(a []string, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, role := range a {
+		set[role] = true
+	}
+	for _, role := range b {
+		if !set[role] {
+			return false
+		}
+		delete(set, role)
+	}
+	return len(set) == 0
+}
+
+// newClaims constructs the claims of an access token for the user. Every
+// token gets a unique ID (jti) so it can be revoked individually.
+func newClaims(u User) auth.Claims {
+	return auth.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			Issuer:    "go-sample-service",
 			Subject:   u.ID,
 			Audience:  []string{"students"},
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		Roles: u.Roles,
 	}
+}
+
+// record adds an event about a change to a user to the audit log. The
+// changes are the fields that differ between old and usr, with the password
+// redacted, and the audit store must join the transaction of the change. A
+// zero old user stands for a user being created.
+func (us UserService) record(ctx context.Context, traceID string, audits audit.Storer, actorID string, action string, old User, usr User, now time.Time) error {
+	var before interface{}
+	if old.ID != "" {
+		before = old
+	}
+
+	changes, err := audit.Diff(before, usr, "date_updated", "version")
+	if err != nil {
+		return err
+	}
+	if old.ID != "" && !bytes.Equal(old.PasswordHash, usr.PasswordHash) {
+		changes = changes.Redact("password")
+	}
 
-	return claims, nil
+	ne := audit.NewEvent{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: AuditTarget,
+		TargetID:   usr.ID,
+		Changes:    changes,
+	}
+	if _, err := audit.New(us.log, audits).Record(ctx, traceID, ne, now); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// publish sends an event about a committed change to a user, when the
+// service publishes events.
+func (us UserService) publish(typ string, usr User) {
+	if us.events != nil {
+		us.events.Publish(typ, usr.ID, usr)
+	}
 }
@@ -1,24 +1,43 @@
 package user_test
 
 import (
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit"
 	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/user/stores/userdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/user/stores/usermem"
 	"github.com/danielmbirochi/go-sample-service/business/data/schema"
 	"github.com/danielmbirochi/go-sample-service/business/tests"
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+	"github.com/danielmbirochi/go-sample-service/foundation/logger"
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/go-cmp/cmp"
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
+// adminClaims are the claims of the seeded admin, recorded as the actor of
+// the changes made by the tests.
+var adminClaims = auth.Claims{
+	RegisteredClaims: jwt.RegisteredClaims{
+		Subject: "5cf37266-3473-4006-984f-9325122678b7",
+	},
+	Roles: []string{auth.RoleAdmin},
+}
+
 func TestUser(t *testing.T) {
 	log, db, teardown := tests.NewUnit(t)
 	t.Cleanup(teardown)
 
-	u := user.New(log, db)
+	u := user.New(log, userdb.NewStore(log, db), auth.DefaultPolicy())
 
 	t.Log("Given the need to work with User records.")
 	{
@@ -42,7 +61,7 @@ func TestUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete all data.", tests.Success, testID)
 
-			usr, err := u.Create(ctx, traceID, nu, now)
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
 			}
@@ -112,7 +131,7 @@ func TestUser(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updates to Email.", tests.Success, testID)
 			}
 
-			if err := u.Delete(ctx, traceID, usr.ID); err != nil {
+			if err := u.Delete(ctx, traceID, adminClaims, usr.ID, now); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", tests.Success, testID)
@@ -122,6 +141,1033 @@ func TestUser(t *testing.T) {
 				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve user : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user.", tests.Success, testID)
+
+			if err := u.Restore(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.GetById(ctx, traceID, claims, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve a restored user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve a restored user.", tests.Success, testID)
+
+			if err := u.Delete(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+			}
+			if n, err := u.Purge(ctx, traceID, now.Add(time.Second), now); err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould purge the deleted user : %d %v.", tests.Failed, testID, n, err)
+			}
+			if err := u.Restore(ctx, traceID, adminClaims, usr.ID, now); errors.Cause(err) != user.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to restore a purged user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould purge the deleted user.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen joining a caller's transaction.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nu := user.NewUser{
+				Name:            "Daniel M",
+				Email:           "dmbirochi@gmail.com.com",
+				Roles:           []string{auth.RoleAdmin},
+				Password:        "teste123",
+				PasswordConfirm: "teste123",
+			}
+
+			errRollback := errors.New("rollback")
+
+			var usr user.User
+			err := database.WithinTran(ctx, db, func(tx *sqlx.Tx) error {
+				var err error
+				usr, err = user.New(log, userdb.NewStore(log, tx), auth.DefaultPolicy()).Create(ctx, traceID, adminClaims, nu, now)
+				if err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if err != errRollback {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user inside the transaction : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create user inside the transaction.", tests.Success, testID)
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "go-sample-service project",
+					Audience:  []string{"testers"},
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(now),
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			_, err = u.GetById(ctx, traceID, claims, usr.ID)
+			if errors.Cause(err) != user.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve user after rollback : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user after rollback.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserAccess(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy())
+
+	t.Log("Given the need to enforce access control over User records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nu := user.NewUser{
+				Name:            "Daniel M",
+				Email:           "dmbirochi@gmail.com.com",
+				Roles:           []string{auth.RoleOperator},
+				Password:        "teste123",
+				PasswordConfirm: "teste123",
+			}
+
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create user.", tests.Success, testID)
+
+			if _, err := u.Create(ctx, traceID, adminClaims, nu, now); errors.Cause(err) != user.ErrUniqueEmail {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create user with a taken email : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create user with a taken email.", tests.Success, testID)
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "go-sample-service project",
+					Subject:   usr.ID,
+					Audience:  []string{"testers"},
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(now),
+				},
+				Roles: []string{auth.RoleOperator},
+			}
+
+			if _, err := u.GetById(ctx, traceID, claims, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve own user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve own user.", tests.Success, testID)
+
+			other := claims
+			other.Subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			if _, err := u.GetById(ctx, traceID, other, usr.ID); errors.Cause(err) != user.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve another user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve another user.", tests.Success, testID)
+
+			admin := other
+			admin.Roles = []string{auth.RoleAdmin}
+
+			if _, err := u.GetById(ctx, traceID, admin, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve another user as admin : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve another user as admin.", tests.Success, testID)
+
+			if _, err := u.GetById(ctx, traceID, admin, "not-a-uuid"); errors.Cause(err) != user.ErrInvalidID {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a malformed ID : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a malformed ID.", tests.Success, testID)
+
+			if _, err := u.Authenticate(ctx, traceID, now, nu.Email, "wrong"); errors.Cause(err) != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT authenticate with a wrong password : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT authenticate with a wrong password.", tests.Success, testID)
+
+			got, err := u.Authenticate(ctx, traceID, now, nu.Email, nu.Password)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate.", tests.Success, testID)
+
+			if got.Subject != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get claims for the authenticated user : got %q want %q.", tests.Failed, testID, got.Subject, usr.ID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get claims for the authenticated user.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserScopedAccess(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	policy, err := auth.NewPolicy(map[string][]string{
+		auth.RoleAdmin: {"*"},
+		auth.AnyRole:   {auth.ActionUserDelete + ":" + auth.ScopeSelf, auth.ActionUserRestore + ":" + auth.ScopeSelf},
+	})
+	if err != nil {
+		t.Fatalf("policy error: %s", err)
+	}
+	u := user.New(log, usermem.NewStore(), policy)
+
+	t.Log("Given the need to limit self scoped grants to one's own record.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen users may only delete and restore themselves.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			var created []user.User
+			for _, email := range []string{"a@example.com", "b@example.com"} {
+				nu := user.NewUser{
+					Name:            "Gopher",
+					Email:           email,
+					Roles:           []string{auth.RoleOperator},
+					Password:        "gophers",
+					PasswordConfirm: "gophers",
+				}
+				usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+				}
+				created = append(created, usr)
+			}
+
+			self := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: created[0].ID},
+				Roles:            []string{auth.RoleOperator},
+			}
+
+			if err := u.Delete(ctx, traceID, self, created[1].ID, now); errors.Cause(err) != user.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete another user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete another user.", tests.Success, testID)
+
+			if err := u.Delete(ctx, traceID, adminClaims, created[1].ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user as admin : %s.", tests.Failed, testID, err)
+			}
+			if err := u.Restore(ctx, traceID, self, created[1].ID, now); errors.Cause(err) != user.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to restore another user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to restore another user.", tests.Success, testID)
+
+			if err := u.Delete(ctx, traceID, self, created[0].ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete oneself : %s.", tests.Failed, testID, err)
+			}
+			if err := u.Restore(ctx, traceID, self, created[0].ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore oneself : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete and restore oneself.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserGrantOnCreate(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	policy, err := auth.NewPolicy(map[string][]string{
+		auth.RoleAdmin: {"*"},
+		auth.AnyRole:   {auth.ActionUserCreate},
+	})
+	if err != nil {
+		t.Fatalf("policy error: %s", err)
+	}
+	var denials int
+	policy.SetAuditor(func(auth.Claims, string, *auth.Resource) { denials++ })
+	u := user.New(log, usermem.NewStore(), policy)
+
+	t.Log("Given the need to keep users who can create users from granting roles.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen users may create users but not grant roles.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			creator := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"},
+				Roles:            []string{auth.RoleOperator},
+			}
+			nu := user.NewUser{
+				Name:            "Gopher",
+				Email:           "gopher@example.com",
+				Roles:           []string{auth.RoleAdmin},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			if _, err := u.Create(ctx, traceID, creator, nu, now); errors.Cause(err) != user.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create a user with roles : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a user with roles.", tests.Success, testID)
+
+			nu.Roles = []string{}
+			if _, err := u.Create(ctx, traceID, creator, nu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user without roles : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a user without roles.", tests.Success, testID)
+
+			denials = 0
+			rows := "name,email,roles,password,password_confirm\n" +
+				"Bob,bob@example.com,ADMIN,gophers,gophers\n" +
+				"Cid,cid@example.com,OPERATOR,gophers,gophers\n"
+
+			report, err := u.Import(ctx, traceID, creator, csvRows(t, rows), false, now)
+			if err != nil || report.Created != 0 || len(report.Errors) != 2 || report.Errors[0].Row != 1 || report.Errors[1].Row != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the imported rows giving roles : %+v %v.", tests.Failed, testID, report, err)
+			}
+			if denials != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould report the denial once per import : %d.", tests.Failed, testID, denials)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the imported rows giving roles.", tests.Success, testID)
+
+			report, err = u.Import(ctx, traceID, adminClaims, csvRows(t, rows), true, now)
+			if err != nil || len(report.Errors) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould import rows giving roles as admin : %+v %v.", tests.Failed, testID, report, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould import rows giving roles as admin.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserVerification(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy()).RequireVerifiedEmail()
+
+	t.Log("Given the need to require verified emails.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nu := user.NewUser{
+				Name:            "Gopher",
+				Email:           "gopher@example.com",
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := u.Authenticate(ctx, traceID, now, nu.Email, "wrong"); errors.Cause(err) != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould check the password before the verification : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould check the password before the verification.", tests.Success, testID)
+
+			if _, err := u.Authenticate(ctx, traceID, now, nu.Email, nu.Password); errors.Cause(err) != user.ErrUnverifiedEmail {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT authenticate an unverified user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT authenticate an unverified user.", tests.Success, testID)
+
+			if _, err := u.Reauthenticate(ctx, traceID, now, usr.ID); errors.Cause(err) != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT refresh the session of an unverified user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT refresh the session of an unverified user.", tests.Success, testID)
+
+			if err := u.VerifyEmail(ctx, traceID, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to verify the email : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, now, nu.Email, nu.Password); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate a verified user : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Reauthenticate(ctx, traceID, now, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould refresh the session of a verified user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate a verified user.", tests.Success, testID)
+
+			admin := auth.Claims{
+				Roles: []string{auth.RoleAdmin},
+			}
+			uu := user.UpdateUser{
+				Email: tests.StringPointer("gopher2@example.com"),
+			}
+			if err := u.Update(ctx, traceID, admin, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update the email : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, now, *uu.Email, nu.Password); errors.Cause(err) != user.ErrUnverifiedEmail {
+				t.Fatalf("\t%s\tTest %d:\tShould require verifying a changed email : %v.", tests.Failed, testID, err)
+			}
+			if _, err := u.Reauthenticate(ctx, traceID, now, usr.ID); errors.Cause(err) != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT refresh the session until a changed email is verified : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require verifying a changed email.", tests.Success, testID)
+
+			if _, err := u.ResetPassword(ctx, traceID, usr.ID, "gophers2", now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reset the password : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, now, *uu.Email, "gophers2"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate with the reset password : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate with the reset password.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserUpdate(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy())
+
+	t.Log("Given the need to tell apart what users and admins may update.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nu := user.NewUser{
+				Name:            "Gopher",
+				Email:           "gopher@example.com",
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+
+			self := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: usr.ID,
+				},
+				Roles: []string{auth.RoleOperator},
+			}
+			admin := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: "5cf37266-3473-4006-984f-9325122678b7",
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			uu := user.UpdateUser{
+				Name:  tests.StringPointer("Gopher Jr"),
+				Roles: []string{auth.RoleOperator},
+			}
+			if err := u.Update(ctx, traceID, self, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update own name : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update own name.", tests.Success, testID)
+
+			uu = user.UpdateUser{
+				Roles: []string{auth.RoleOperator, auth.RoleAdmin},
+			}
+			if err := u.Update(ctx, traceID, self, usr.ID, uu, now); errors.Cause(err) != user.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to grant roles to oneself : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to grant roles to oneself.", tests.Success, testID)
+
+			if err := u.Update(ctx, traceID, admin, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to grant roles as admin : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to grant roles as admin.", tests.Success, testID)
+
+			for _, current := range []*string{nil, tests.StringPointer("wrong")} {
+				uu = user.UpdateUser{
+					Password:        tests.StringPointer("gophers2"),
+					CurrentPassword: current,
+				}
+				if err := u.Update(ctx, traceID, self, usr.ID, uu, now); errors.Cause(err) != user.ErrWrongPassword {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT change own password without the current one : %v.", tests.Failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT change own password without the current one.", tests.Success, testID)
+
+			uu.CurrentPassword = tests.StringPointer("gophers")
+			if err := u.Update(ctx, traceID, self, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould change own password with the current one : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change own password with the current one.", tests.Success, testID)
+
+			uu = user.UpdateUser{
+				Password: tests.StringPointer("gophers3"),
+			}
+			if err := u.Update(ctx, traceID, admin, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould change the password of others as admin : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, now, nu.Email, "gophers3"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate with the new password : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change the password of others as admin.", tests.Success, testID)
 		}
 	}
 }
+
+func TestUserVersion(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy())
+
+	t.Log("Given the need to keep concurrent updates from overwriting each other.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nu := user.NewUser{
+				Name:            "Gopher",
+				Email:           "gopher@example.com",
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+			if usr.Version != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould start at version 1 : got %d.", tests.Failed, testID, usr.Version)
+			}
+			t.Logf("\t%s\tTest %d:\tShould start at version 1.", tests.Success, testID)
+
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: usr.ID,
+				},
+				Roles: []string{auth.RoleAdmin},
+			}
+
+			read := usr.Version
+			uu := user.UpdateUser{
+				Name:    tests.StringPointer("Gopher Jr"),
+				Version: &read,
+			}
+			if err := u.Update(ctx, traceID, claims, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould update the version that was read : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould update the version that was read.", tests.Success, testID)
+
+			uu.Name = tests.StringPointer("Gopher Sr")
+			if err := u.Update(ctx, traceID, claims, usr.ID, uu, now); errors.Cause(err) != user.ErrVersionConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT update a stale version : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT update a stale version.", tests.Success, testID)
+
+			if err := u.Delete(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+			}
+			if err := u.Restore(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user : %s.", tests.Failed, testID, err)
+			}
+
+			got, err := u.GetById(ctx, traceID, claims, usr.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user : %s.", tests.Failed, testID, err)
+			}
+			if got.Name != "Gopher Jr" || got.Version != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould count every write in the version : got %q at %d.", tests.Failed, testID, got.Name, got.Version)
+			}
+			t.Logf("\t%s\tTest %d:\tShould count every write in the version.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserSoftDelete(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy())
+
+	t.Log("Given the need to delete users without losing their history.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			var created []user.User
+			for _, email := range []string{"a@example.com", "b@example.com"} {
+				nu := user.NewUser{
+					Name:            "Gopher",
+					Email:           email,
+					Roles:           []string{auth.RoleOperator},
+					Password:        "gophers",
+					PasswordConfirm: "gophers",
+				}
+				usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+				}
+				created = append(created, usr)
+			}
+
+			deleted := created[0]
+			if err := u.Delete(ctx, traceID, adminClaims, deleted.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", tests.Success, testID)
+
+			page, err := u.List(ctx, traceID, user.QueryFilter{}, user.DefaultOrderBy, nil, 10)
+			if err != nil || len(page.Users) != 1 || page.Users[0].ID != created[1].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould leave deleted users out of listings : %+v %v.", tests.Failed, testID, page.Users, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould leave deleted users out of listings.", tests.Success, testID)
+
+			page, err = u.List(ctx, traceID, user.QueryFilter{IncludeDeleted: true}, user.DefaultOrderBy, nil, 10)
+			if err != nil || len(page.Users) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould list deleted users on demand : %+v %v.", tests.Failed, testID, page.Users, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list deleted users on demand.", tests.Success, testID)
+
+			if _, err := u.Authenticate(ctx, traceID, now, deleted.Email, "gophers"); errors.Cause(err) != user.ErrAuthenticationFailure {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT authenticate a deleted user : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT authenticate a deleted user.", tests.Success, testID)
+
+			twin, err := u.Create(ctx, traceID, adminClaims, user.NewUser{
+				Name:            "Twin Gopher",
+				Email:           deleted.Email,
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to take the email of a deleted user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to take the email of a deleted user.", tests.Success, testID)
+
+			if err := u.Restore(ctx, traceID, adminClaims, deleted.ID, now); errors.Cause(err) != user.ErrUniqueEmail {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT restore a user whose email was taken : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT restore a user whose email was taken.", tests.Success, testID)
+
+			// The twin is deleted past the retention window used below.
+			if err := u.Delete(ctx, traceID, adminClaims, twin.ID, now.Add(48*time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+			}
+
+			if err := u.Restore(ctx, traceID, adminClaims, created[1].ID, now); errors.Cause(err) != user.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT restore a user that is not deleted : %v.", tests.Failed, testID, err)
+			}
+			if err := u.Restore(ctx, traceID, adminClaims, deleted.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user : %s.", tests.Failed, testID, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, now, deleted.Email, "gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate a restored user : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate a restored user.", tests.Success, testID)
+
+			for i, usr := range created {
+				if err := u.Delete(ctx, traceID, adminClaims, usr.ID, now.Add(time.Duration(i)*24*time.Hour)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+				}
+			}
+			if n, err := u.Purge(ctx, traceID, now.Add(time.Hour), now); err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould only purge users deleted before the retention window : %d %v.", tests.Failed, testID, n, err)
+			}
+			if err := u.Restore(ctx, traceID, adminClaims, created[1].ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep users deleted within the retention window : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only purge users deleted before the retention window.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserEvents(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	broker := events.NewBroker(events.DefaultSize, events.DefaultBuffer)
+	defer broker.Close()
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy()).PublishEvents(broker)
+
+	t.Log("Given the need to publish the changes made to users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			sub, err := broker.Subscribe("", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe : %s.", tests.Failed, testID, err)
+			}
+			defer sub.Close()
+
+			nu := user.NewUser{
+				Name:            "Gopher",
+				Email:           "gopher@example.com",
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+
+			name := "Gopher Jr."
+			if err := u.Update(ctx, traceID, adminClaims, usr.ID, user.UpdateUser{Name: &name}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", tests.Failed, testID, err)
+			}
+			if err := u.Update(ctx, traceID, auth.Claims{}, usr.ID, user.UpdateUser{Name: &name}, now); errors.Cause(err) != user.ErrForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT update user without access : %v.", tests.Failed, testID, err)
+			}
+			if err := u.Delete(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+			}
+			if err := u.Restore(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user : %s.", tests.Failed, testID, err)
+			}
+
+			want := []struct {
+				typ     string
+				version int
+				name    string
+				deleted bool
+			}{
+				{user.EventCreated, 1, "Gopher", false},
+				{user.EventUpdated, 2, "Gopher Jr.", false},
+				{user.EventDeleted, 3, "Gopher Jr.", true},
+				{user.EventUpdated, 4, "Gopher Jr.", false},
+			}
+			for i, w := range want {
+				var ev events.Event
+				select {
+				case ev = <-sub.Events():
+				default:
+					t.Fatalf("\t%s\tTest %d:\tShould publish every committed change : got %d events.", tests.Failed, testID, i)
+				}
+
+				got, ok := ev.Data.(user.User)
+				if !ok || ev.Type != w.typ || ev.TargetID != usr.ID || got.Version != w.version || got.Name != w.name || (got.DateDeleted != nil) != w.deleted {
+					t.Fatalf("\t%s\tTest %d:\tShould publish the user after change %d : %s %+v.", tests.Failed, testID, i, ev.Type, ev.Data)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the user after every committed change.", tests.Success, testID)
+
+			select {
+			case ev := <-sub.Events():
+				t.Fatalf("\t%s\tTest %d:\tShould NOT publish failed changes : %s.", tests.Failed, testID, ev.Type)
+			default:
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT publish failed changes.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserList(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	u := user.New(log, usermem.NewStore(), auth.DefaultPolicy())
+
+	t.Log("Given the need to page through User records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			var created []user.User
+			for i, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				nu := user.NewUser{
+					Name:            "Gopher",
+					Email:           email,
+					Roles:           []string{auth.RoleOperator},
+					Password:        "gophers",
+					PasswordConfirm: "gophers",
+				}
+
+				usr, err := u.Create(ctx, traceID, adminClaims, nu, now.Add(time.Duration(i)*time.Second))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+				}
+				created = append(created, usr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create users.", tests.Success, testID)
+
+			page, err := u.List(ctx, traceID, user.QueryFilter{}, user.DefaultOrderBy, nil, 2)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list the first page : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to list the first page.", tests.Success, testID)
+
+			if len(page.Users) != 2 || !page.HasMore || page.Next == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould get two users and a cursor : got %d users, has more %v.", tests.Failed, testID, len(page.Users), page.HasMore)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get two users and a cursor.", tests.Success, testID)
+
+			if page.Users[0].ID != created[0].ID || page.Users[1].ID != created[1].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get users in creation order.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get users in creation order.", tests.Success, testID)
+
+			next := page.Next
+
+			page, err = u.List(ctx, traceID, user.QueryFilter{}, user.DefaultOrderBy, next, 2)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list the second page : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to list the second page.", tests.Success, testID)
+
+			if len(page.Users) != 1 || page.HasMore || page.Next != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould get the last user and no cursor : got %d users, has more %v.", tests.Failed, testID, len(page.Users), page.HasMore)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the last user and no cursor.", tests.Success, testID)
+
+			if page.Users[0].ID != created[2].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould resume right after the cursor.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould resume right after the cursor.", tests.Success, testID)
+
+			orderBy, err := user.ParseOrderBy("email,desc")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the ordering : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to parse the ordering.", tests.Success, testID)
+
+			if _, err := u.List(ctx, traceID, user.QueryFilter{}, orderBy, next, 2); errors.Cause(err) != user.ErrInvalidCursor {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT accept a cursor issued for another ordering : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT accept a cursor issued for another ordering.", tests.Success, testID)
+
+			page, err = u.List(ctx, traceID, user.QueryFilter{}, orderBy, nil, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list users by email descending : %s.", tests.Failed, testID, err)
+			}
+			page, err = u.List(ctx, traceID, user.QueryFilter{}, orderBy, page.Next, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list users by email descending : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to list users by email descending.", tests.Success, testID)
+
+			if page.Users[0].Email != "b@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the second user by email descending : got %q.", tests.Failed, testID, page.Users[0].Email)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the second user by email descending.", tests.Success, testID)
+
+			filter := user.QueryFilter{
+				Email:            tests.StringPointer("C@EXAMPLE"),
+				StartCreatedDate: &now,
+			}
+			page, err = u.List(ctx, traceID, filter, user.DefaultOrderBy, nil, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter users : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to filter users.", tests.Success, testID)
+
+			if len(page.Users) != 1 || page.Users[0].ID != created[2].ID {
+				t.Fatalf("\t%s\tTest %d:\tShould only get users matching the filter : got %d users.", tests.Failed, testID, len(page.Users))
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get users matching the filter.", tests.Success, testID)
+
+			if _, err := user.ParseOrderBy("password_hash,asc"); errors.Cause(err) != user.ErrInvalidOrderBy {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT order by a field outside the allow-list : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT order by a field outside the allow-list.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserAudit(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	store := usermem.NewStore()
+	u := user.New(log, store, auth.DefaultPolicy())
+
+	t.Log("Given the need to keep track of who changed users.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			nu := user.NewUser{
+				Name:            "Gopher",
+				Email:           "gopher@example.com",
+				Roles:           []string{auth.RoleOperator},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+			usr, err := u.Create(ctx, traceID, adminClaims, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", tests.Failed, testID, err)
+			}
+
+			uu := user.UpdateUser{
+				Name:     tests.StringPointer("Gopher Jr"),
+				Password: tests.StringPointer("gophers2"),
+			}
+			if err := u.Update(ctx, traceID, adminClaims, usr.ID, uu, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", tests.Failed, testID, err)
+			}
+
+			stale := user.UpdateUser{
+				Name:    tests.StringPointer("Gopher Sr"),
+				Version: &usr.Version,
+			}
+			if err := u.Update(ctx, traceID, adminClaims, usr.ID, stale, now); errors.Cause(err) != user.ErrVersionConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT update a stale version : %v.", tests.Failed, testID, err)
+			}
+
+			if err := u.Delete(ctx, traceID, adminClaims, usr.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
+			}
+
+			events := store.Audits().Events()
+			if len(events) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould record one event per committed change : %+v.", tests.Failed, testID, events)
+			}
+			for i, action := range []string{auth.ActionUserCreate, auth.ActionUserUpdate, auth.ActionUserDelete} {
+				ev := events[i]
+				if ev.Action != action || ev.ActorID != adminClaims.Subject || ev.TargetType != user.AuditTarget || ev.TargetID != usr.ID || ev.TraceID != traceID {
+					t.Fatalf("\t%s\tTest %d:\tShould record the %s event : %+v.", tests.Failed, testID, action, ev)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould record one event per committed change.", tests.Success, testID)
+
+			want := audit.Changes{
+				{Field: "name", Old: "Gopher", New: "Gopher Jr"},
+				{Field: "password", Old: audit.Redacted, New: audit.Redacted},
+			}
+			if diff := cmp.Diff(events[1].Changes, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould record the changed fields with the password redacted. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the changed fields with the password redacted.", tests.Success, testID)
+		}
+	}
+}
+
+func TestUserImport(t *testing.T) {
+	log, err := logger.New("TEST")
+	if err != nil {
+		t.Fatalf("logger error: %s", err)
+	}
+
+	store := usermem.NewStore()
+	u := user.New(log, store, auth.DefaultPolicy())
+
+	t.Log("Given the need to create and list users in bulk.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using an in-memory store.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			good := "name,email,roles,password,password_confirm\n" +
+				"Ann,ann@example.com,OPERATOR,gophers,gophers\n" +
+				"Bob,bob@example.com,ADMIN;OPERATOR,gophers,gophers\n"
+			bad := good +
+				"Cid,not-an-email,OPERATOR,gophers,gophers\n" +
+				"Ann,ann@example.com,OPERATOR,gophers,gophers\n"
+
+			report, err := u.Import(ctx, traceID, adminClaims, csvRows(t, bad), false, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to import users : %s.", tests.Failed, testID, err)
+			}
+			if report.Rows != 4 || report.Created != 0 || len(report.Errors) != 2 || report.Errors[0].Row != 3 || report.Errors[1].Row != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould report every rejected row : %+v.", tests.Failed, testID, report)
+			}
+			if page, _ := u.List(ctx, traceID, user.QueryFilter{}, user.DefaultOrderBy, nil, 10); len(page.Users) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT create users along rejected rows : %+v.", tests.Failed, testID, page.Users)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report every rejected row and create no user.", tests.Success, testID)
+
+			report, err = u.Import(ctx, traceID, adminClaims, csvRows(t, good), true, now)
+			if err != nil || report.Rows != 2 || report.Created != 0 || len(report.Errors) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould check the rows of a dry run : %+v %v.", tests.Failed, testID, report, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould check the rows of a dry run.", tests.Success, testID)
+
+			report, err = u.Import(ctx, traceID, adminClaims, csvRows(t, good), false, now)
+			if err != nil || report.Created != 2 || len(report.Errors) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould create the users : %+v %v.", tests.Failed, testID, report, err)
+			}
+			if _, err := u.Authenticate(ctx, traceID, now, "bob@example.com", "gophers"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate an imported user : %s.", tests.Failed, testID, err)
+			}
+			if events := store.Audits().Events(); len(events) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould record the creation of every user : %+v.", tests.Failed, testID, events)
+			}
+			t.Logf("\t%s\tTest %d:\tShould create the users.", tests.Success, testID)
+
+			report, err = u.Import(ctx, traceID, adminClaims, csvRows(t, good), true, now)
+			if err != nil || len(report.Errors) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould reject emails already taken : %+v %v.", tests.Failed, testID, report, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject emails already taken.", tests.Success, testID)
+
+			var emails []string
+			err = u.Export(ctx, traceID, user.QueryFilter{}, func(usr user.User) error {
+				emails = append(emails, usr.Email)
+				return nil
+			})
+
+			// Both users share the same creation date, so they are exported in
+			// the order of their random IDs.
+			sort.Strings(emails)
+			if diff := cmp.Diff(emails, []string{"ann@example.com", "bob@example.com"}); err != nil || diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould export every user : %v. Diff:\n%s", tests.Failed, testID, err, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export every user.", tests.Success, testID)
+		}
+	}
+}
+
+// csvRows decodes the users of an import from a CSV document.
+func csvRows(t *testing.T, doc string) user.RowDecoder {
+	rows, err := web.NewRowDecoder(strings.NewReader(doc), web.CSVType)
+	if err != nil {
+		t.Fatalf("decoder error: %s", err)
+	}
+	return rows
+}
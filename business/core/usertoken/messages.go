@@ -0,0 +1,38 @@
+package usertoken
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/mail"
+)
+
+// NewMessage composes the email carrying a token to a user. The token is
+// sent as is, leaving it to clients to build links around it.
+func NewMessage(purpose string, name string, email string, tkn string) mail.Message {
+	switch purpose {
+	case PurposePasswordReset:
+		return mail.Message{
+			To:      email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hi %s,\n\nUse the token below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not ask for a password reset, you can ignore this email.\n",
+				name, hours(PasswordResetTTL), tkn),
+		}
+
+	default:
+		return mail.Message{
+			To:      email,
+			Subject: "Verify your email",
+			Body: fmt.Sprintf("Hi %s,\n\nUse the token below to verify your email. It expires in %s.\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+				name, hours(EmailVerificationTTL), tkn),
+		}
+	}
+}
+
+// hours spells out a duration of whole hours.
+func hours(d time.Duration) string {
+	if h := int(d.Hours()); h != 1 {
+		return fmt.Sprintf("%d hours", h)
+	}
+	return "1 hour"
+}
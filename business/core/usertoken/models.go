@@ -0,0 +1,24 @@
+package usertoken
+
+import (
+	"time"
+)
+
+// These are the purposes a token can be issued for. A token only works for
+// the purpose it was issued for.
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+// Token is a single-use, time-limited token sent to a user by email to prove
+// they own the address.
+type Token struct {
+	ID          string     `db:"token_id"`
+	UserID      string     `db:"user_id"`
+	Purpose     string     `db:"purpose"`
+	TokenHash   string     `db:"token_hash"`
+	DateCreated time.Time  `db:"date_created"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+}
@@ -0,0 +1,132 @@
+// Package usertoken contains usecases for the single-use tokens mailed to
+// users to reset their password or verify their email.
+package usertoken
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
+	"github.com/danielmbirochi/go-sample-service/foundation/token"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// These are the lifetimes of the tokens of each purpose.
+const (
+	PasswordResetTTL     = time.Hour
+	EmailVerificationTTL = 48 * time.Hour
+)
+
+// ErrInvalidToken occurs when a token does not exist, was issued for another
+// purpose, expired or was already used.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type UserTokenService struct {
+	db  database.Executor
+	log *zap.SugaredLogger
+}
+
+// New is a factory method for constructing user token service. Passing a
+// *sqlx.Tx makes the service join that transaction.
+func New(log *zap.SugaredLogger, db database.Executor) UserTokenService {
+	return UserTokenService{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create issues a token for the purpose and returns its raw value. Only a
+// hash of the token is stored. Tokens issued before for the same user and
+// purpose stop working, so only the latest email sent can be used.
+func (ts UserTokenService) Create(ctx context.Context, traceID string, userID string, purpose string, ttl time.Duration, now time.Time) (string, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.usertoken.Create")
+	defer span.End()
+
+	tkn, err := token.New()
+	if err != nil {
+		return "", errors.Wrap(err, "generating token")
+	}
+
+	t := Token{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Purpose:     purpose,
+		TokenHash:   token.Hash(tkn),
+		DateCreated: now.UTC(),
+		DateExpires: now.Add(ttl).UTC(),
+	}
+
+	err = database.WithinTran(ctx, ts.db, func(tx *sqlx.Tx) error {
+		const qUse = `
+		UPDATE user_tokens
+			SET
+				"date_used" = $3
+			WHERE user_id = $1 AND purpose = $2 AND date_used IS NULL
+		`
+
+		ts.log.Infof("%s : %s : query : %s", traceID, "usertoken.Create",
+			database.Log(qUse, t.UserID, t.Purpose, t.DateCreated),
+		)
+
+		if _, err := tx.ExecContext(ctx, qUse, t.UserID, t.Purpose, t.DateCreated); err != nil {
+			return errors.Wrap(err, "invalidating previous tokens")
+		}
+
+		const q = `
+		INSERT INTO user_tokens
+			(token_id, user_id, purpose, token_hash, date_created, date_expires)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		`
+
+		ts.log.Infof("%s : %s : query : %s", traceID, "usertoken.Create",
+			database.Log(q, t.ID, t.UserID, t.Purpose, t.TokenHash, t.DateCreated, t.DateExpires),
+		)
+
+		if _, err := tx.ExecContext(ctx, q, t.ID, t.UserID, t.Purpose, t.TokenHash, t.DateCreated, t.DateExpires); err != nil {
+			return errors.Wrap(err, "inserting token")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tkn, nil
+}
+
+// Consume uses up a token issued for the purpose and returns the ID of the
+// user it was issued to. Marking the token as used and checking it is a
+// single statement, so a token can not be used twice concurrently.
+func (ts UserTokenService) Consume(ctx context.Context, traceID string, tkn string, purpose string, now time.Time) (string, error) {
+	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.core.usertoken.Consume")
+	defer span.End()
+
+	const q = `
+	UPDATE user_tokens
+		SET
+			"date_used" = $3
+		WHERE token_hash = $1 AND purpose = $2 AND date_used IS NULL AND date_expires > $3
+	RETURNING user_id
+	`
+
+	hash := token.Hash(tkn)
+	ts.log.Infof("%s : %s : query : %s", traceID, "usertoken.Consume",
+		database.Log(q, hash, purpose, now.UTC()),
+	)
+
+	var userID string
+	if err := ts.db.GetContext(ctx, &userID, q, hash, purpose, now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidToken
+		}
+		return "", errors.Wrap(err, "consuming token")
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,81 @@
+package usertoken_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/business/core/usertoken"
+	"github.com/danielmbirochi/go-sample-service/business/data/schema"
+	"github.com/danielmbirochi/go-sample-service/business/tests"
+
+	"github.com/pkg/errors"
+)
+
+func TestUserToken(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ts := usertoken.New(log, db)
+
+	t.Log("Given the need to work with tokens sent by email.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen issuing password reset tokens.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000001"
+
+			// Seeded regular user.
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+			if err := schema.DeleteAll(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete all data : %s.", tests.Failed, testID, err)
+			}
+			if err := schema.Seed(db); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to seed the database : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to seed the database.", tests.Success, testID)
+
+			first, err := ts.Create(ctx, traceID, userID, usertoken.PurposePasswordReset, usertoken.PasswordResetTTL, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a token : %s.", tests.Failed, testID, err)
+			}
+			second, err := ts.Create(ctx, traceID, userID, usertoken.PurposePasswordReset, usertoken.PasswordResetTTL, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a token : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create tokens.", tests.Success, testID)
+
+			if _, err := ts.Consume(ctx, traceID, first, usertoken.PurposePasswordReset, now); errors.Cause(err) != usertoken.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT consume a superseded token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT consume a superseded token.", tests.Success, testID)
+
+			if _, err := ts.Consume(ctx, traceID, second, usertoken.PurposeEmailVerification, now); errors.Cause(err) != usertoken.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT consume a token for another purpose : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT consume a token for another purpose.", tests.Success, testID)
+
+			got, err := ts.Consume(ctx, traceID, second, usertoken.PurposePasswordReset, now.Add(time.Minute))
+			if err != nil || got != userID {
+				t.Fatalf("\t%s\tTest %d:\tShould consume the token for its user : %q %v.", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould consume the token for its user.", tests.Success, testID)
+
+			if _, err := ts.Consume(ctx, traceID, second, usertoken.PurposePasswordReset, now.Add(time.Minute)); errors.Cause(err) != usertoken.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT consume a token twice : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT consume a token twice.", tests.Success, testID)
+
+			third, err := ts.Create(ctx, traceID, userID, usertoken.PurposePasswordReset, usertoken.PasswordResetTTL, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a token : %s.", tests.Failed, testID, err)
+			}
+			if _, err := ts.Consume(ctx, traceID, third, usertoken.PurposePasswordReset, now.Add(usertoken.PasswordResetTTL)); errors.Cause(err) != usertoken.ErrInvalidToken {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT consume an expired token : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT consume an expired token.", tests.Success, testID)
+		}
+	}
+}
@@ -66,6 +66,149 @@ CREATE TABLE sales (
 		Script: `
 ALTER TABLE products
 	ADD COLUMN user_id UUID DEFAULT '00000000-0000-0000-0000-000000000000'
+`,
+	},
+	{
+		Version:     2.2,
+		Description: "Add users keyset pagination index",
+		Script: `
+CREATE INDEX users_date_created_user_id_idx ON users (date_created, user_id);
+`,
+	},
+	{
+		Version:     2.3,
+		Description: "Add refresh tokens and revoked access tokens",
+		Script: `
+CREATE TABLE refresh_tokens (
+	token_id     UUID,
+	family_id    UUID,
+	user_id      UUID,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_used    TIMESTAMP,
+	date_revoked TIMESTAMP,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+CREATE TABLE revoked_tokens (
+	jti          TEXT,
+	date_expires TIMESTAMP,
+
+	PRIMARY KEY (jti)
+);
+`,
+	},
+	{
+		Version:     2.4,
+		Description: "Add api keys",
+		Script: `
+CREATE TABLE api_keys (
+	key_id         UUID,
+	user_id        UUID,
+	name           TEXT,
+	prefix         TEXT,
+	key_hash       TEXT UNIQUE,
+	scopes         TEXT[],
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP,
+	date_last_used TIMESTAMP,
+	date_revoked   TIMESTAMP,
+
+	PRIMARY KEY (key_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+	},
+	{
+		Version:     2.5,
+		Description: "Add login attempts",
+		Script: `
+CREATE TABLE login_attempts (
+	key               TEXT,
+	failures          INT,
+	date_last_failure TIMESTAMP,
+	date_locked_until TIMESTAMP,
+
+	PRIMARY KEY (key)
+);
+`,
+	},
+	{
+		Version:     2.6,
+		Description: "Add email verification and user tokens",
+		Script: `
+ALTER TABLE users
+	ADD COLUMN date_verified TIMESTAMP;
+
+-- Users created before verification existed are trusted.
+UPDATE users SET date_verified = date_created;
+
+CREATE TABLE user_tokens (
+	token_id     UUID,
+	user_id      UUID,
+	purpose      TEXT,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_used    TIMESTAMP,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX user_tokens_user_id_purpose_idx ON user_tokens (user_id, purpose);
+`,
+	},
+	{
+		Version:     2.7,
+		Description: "Add users soft delete",
+		Script: `
+ALTER TABLE users
+	ADD COLUMN date_deleted TIMESTAMP;
+
+CREATE INDEX users_date_deleted_idx ON users (date_deleted) WHERE date_deleted IS NOT NULL;
+
+-- Emails are only unique among the users that are not deleted, so the email
+-- of a deleted user can be taken again before it is purged.
+ALTER TABLE users
+	DROP CONSTRAINT users_email_key;
+
+CREATE UNIQUE INDEX users_email_idx ON users (email) WHERE date_deleted IS NULL;
+`,
+	},
+	{
+		Version:     2.8,
+		Description: "Add users version",
+		Script: `
+ALTER TABLE users
+	ADD COLUMN version INT NOT NULL DEFAULT 1;
+`,
+	},
+	{
+		Version:     2.9,
+		Description: "Add audit events",
+		Script: `
+CREATE TABLE audit_events (
+	event_id     UUID,
+	actor_id     TEXT,
+	action       TEXT,
+	target_type  TEXT,
+	target_id    TEXT,
+	trace_id     TEXT,
+	changes      JSONB,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (event_id)
+);
+
+CREATE INDEX audit_events_date_created_idx ON audit_events (date_created, event_id);
+CREATE INDEX audit_events_target_idx ON audit_events (target_type, target_id);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id);
 `,
 	},
 }
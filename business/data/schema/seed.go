@@ -1,34 +1,28 @@
 package schema
 
 import (
+	"context"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/database"
 	"github.com/jmoiron/sqlx"
 )
 
 // Seed func inserts seed-data into the db. It uses a transaction so
 // if any `seed query` fails, it runs a rollback operation.
 func Seed(db *sqlx.DB) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	if _, err := tx.Exec(seeds); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return err
-		}
+	return database.WithinTran(context.Background(), db, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(seeds)
 		return err
-	}
-
-	return tx.Commit()
+	})
 }
 
 // seeds is a string constant containing all of the queries needed to get the
 // db seeded to a useful state for development.
 const seeds = `
 -- Create admin and regular User with password "gophers"
-INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated) VALUES
-	('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
-	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated, date_verified) VALUES
+	('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
 	ON CONFLICT DO NOTHING;
 
 INSERT INTO products (product_id, name, cost, quantity, date_created, date_updated) VALUES
@@ -46,22 +40,20 @@ INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES
 // DeleteAll runs the set of Drop-table queries against db. The queries are ran in a
 // transaction and rolled back if any fail.
 func DeleteAll(db *sqlx.DB) error {
-	tx, err := db.Begin()
-	if err != nil {
+	return database.WithinTran(context.Background(), db, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(deleteAll)
 		return err
-	}
-
-	if _, err := tx.Exec(deleteAll); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return err
-		}
-		return err
-	}
-
-	return tx.Commit()
+	})
 }
 
 // deleteAll is used to clean the database between tests.
 const deleteAll = `
+DELETE FROM audit_events;
+DELETE FROM user_tokens;
+DELETE FROM login_attempts;
+DELETE FROM api_keys;
+DELETE FROM revoked_tokens;
+DELETE FROM refresh_tokens;
+DELETE FROM sales;
 DELETE FROM products;
 DELETE FROM users;`
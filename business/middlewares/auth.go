@@ -19,6 +19,7 @@ var ErrForbidden = web.NewRequestError(
 )
 
 // Authenticate middleware validates a JWT from the `Authorization` http header.
+// Tokens whose jti is on the authenticator revocation list are rejected.
 func Authenticate(a *auth.Auth) web.Middleware {
 
 	// Middleware func
@@ -29,6 +30,11 @@ func Authenticate(a *auth.Auth) web.Middleware {
 			ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.middlewares.Authenticate")
 			defer span.End()
 
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
 			// Expecting header: Authorization: bearer <token>
 			authHeader := r.Header.Get("authorization")
 
@@ -46,6 +52,60 @@ func Authenticate(a *auth.Auth) web.Middleware {
 				return web.NewRequestError(err, http.StatusUnauthorized)
 			}
 
+			revoked, err := a.IsRevoked(ctx, v.TraceID, claims.ID)
+			if err != nil {
+				return err
+			}
+			if revoked {
+				err := errors.New("token has been revoked")
+				return web.NewRequestError(err, http.StatusUnauthorized)
+			}
+
+			// Add claims to the context..
+			ctx = context.WithValue(ctx, auth.Key, claims)
+
+			return innerHandler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// AuthenticateAPIKey middleware is a variant of Authenticate for routes open
+// to machine clients. Besides bearer tokens it accepts the header
+// `Authorization: ApiKey <key>`, which produces the claims of the key owner.
+func AuthenticateAPIKey(a *auth.Auth) web.Middleware {
+	bearer := Authenticate(a)
+
+	// Middleware func
+	m := func(innerHandler web.Handler) web.Handler {
+		withBearer := bearer(innerHandler)
+
+		// Handler func
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			parts := strings.Split(r.Header.Get("authorization"), " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "apikey" {
+				return withBearer(ctx, w, r)
+			}
+
+			ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.middlewares.AuthenticateAPIKey")
+			defer span.End()
+
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			claims, err := a.ValidateAPIKey(ctx, v.TraceID, parts[1], v.Now)
+			if err != nil {
+				if err == auth.ErrInvalidAPIKey {
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+				return err
+			}
+
 			// Add claims to the context..
 			ctx = context.WithValue(ctx, auth.Key, claims)
 
@@ -88,3 +148,36 @@ func Authorize(roles ...string) web.Middleware {
 
 	return m
 }
+
+// Require middleware validates that an authenticated user is granted the
+// action by the policy. Grants scoped to owned resources are enough to pass,
+// since the target is not known yet: the business layer checks ownership.
+// Policies only scope the actions it checks ownership for.
+func Require(p *auth.Policy, action string) web.Middleware {
+
+	// Middleware func
+	m := func(innerHandler web.Handler) web.Handler {
+
+		// Handler func
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "business.middlewares.Require")
+			defer span.End()
+
+			// If the context is missing this value (integrity error) return failure.
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("auth claims missing from context")
+			}
+
+			if !p.CanAny(claims, action) {
+				return ErrForbidden
+			}
+
+			return innerHandler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
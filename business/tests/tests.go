@@ -3,6 +3,7 @@ package tests
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -10,7 +11,11 @@ import (
 	"time"
 
 	"github.com/danielmbirochi/go-sample-service/business/auth"
+	"github.com/danielmbirochi/go-sample-service/business/core/apikey"
+	"github.com/danielmbirochi/go-sample-service/business/core/audit/stores/auditdb"
+	"github.com/danielmbirochi/go-sample-service/business/core/session"
 	"github.com/danielmbirochi/go-sample-service/business/core/user"
+	"github.com/danielmbirochi/go-sample-service/business/core/user/stores/userdb"
 	"github.com/danielmbirochi/go-sample-service/business/data/schema"
 	"github.com/danielmbirochi/go-sample-service/foundation/database"
 	"github.com/danielmbirochi/go-sample-service/foundation/docker"
@@ -120,7 +125,9 @@ type Test struct {
 	DB       *sqlx.DB
 	Log      *zap.SugaredLogger
 	Auth     *auth.Auth
+	Policy   *auth.Policy
 	KID      string
+	Mailbox  string
 	Teardown func()
 
 	t *testing.T
@@ -140,7 +147,7 @@ func NewIntegration(t *testing.T) *Test {
 	}
 
 	keyID := "4754d86b-7a6d-4df5-9c65-224741361492"
-	lookup := func(publicKID string) (*rsa.PublicKey, error) {
+	lookup := func(publicKID string) (crypto.PublicKey, error) {
 		switch publicKID {
 		case keyID:
 			return &privateKey.PublicKey, nil
@@ -148,17 +155,23 @@ func NewIntegration(t *testing.T) *Test {
 		return nil, fmt.Errorf("no public key found for the specified kid: %s", publicKID)
 	}
 
+	policy := auth.DefaultPolicy()
+
 	auth, err := auth.New("RS256", lookup, auth.Keys{keyID: privateKey})
 	if err != nil {
 		t.Fatal(err)
 	}
+	auth.SetRevocationList(session.New(log, db))
+	auth.SetAPIKeyValidator(apikey.New(log, db, policy, auditdb.TranStorer(log)))
 
 	test := Test{
 		TraceID:  "00000000-0000-0000-0000-000000000001",
 		DB:       db,
 		Log:      log,
 		Auth:     auth,
+		Policy:   policy,
 		KID:      keyID,
+		Mailbox:  t.TempDir(),
 		t:        t,
 		Teardown: teardown,
 	}
@@ -170,7 +183,7 @@ func NewIntegration(t *testing.T) *Test {
 func (test *Test) Token(email, pass string) string {
 	test.t.Log("Generating token for test ...")
 
-	u := user.New(test.Log, test.DB)
+	u := user.New(test.Log, userdb.NewStore(test.Log, test.DB), test.Policy)
 	claims, err := u.Authenticate(context.Background(), test.TraceID, time.Now(), email, pass)
 	if err != nil {
 		test.t.Fatal(err)
@@ -0,0 +1,72 @@
+// Package cursor provides opaque and tamper-proof tokens for keyset pagination.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned when a token is malformed or its signature does
+// not match the payload.
+var ErrInvalid = errors.New("invalid cursor")
+
+// Signer encodes pagination positions into signed tokens and decodes them
+// back. Clients can hand tokens back to the API but can not forge them.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner constructs a Signer that uses the provided key for HMAC-SHA256.
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Encode marshals v and returns it as a signed token.
+func (s *Signer) Encode(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.sign(payload)), nil
+}
+
+// Decode verifies the token signature and unmarshals its payload into v.
+func (s *Signer) Decode(token string, v interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return ErrInvalid
+	}
+
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalid
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalid
+	}
+
+	if !hmac.Equal(sig, s.sign(payload)) {
+		return ErrInvalid
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalid
+	}
+
+	return nil
+}
+
+// sign computes the HMAC of the payload.
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,66 @@
+package cursor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/cursor"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type position struct {
+	DateCreated time.Time `json:"date_created"`
+	ID          string    `json:"id"`
+}
+
+func TestSigner(t *testing.T) {
+	t.Log("Given the need to hand out tamper-proof pagination cursors.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single position.", testID)
+		{
+			s := cursor.NewSigner([]byte("secret"))
+
+			exp := position{
+				DateCreated: time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC),
+				ID:          "5cf37266-3473-4006-984f-9325122678b7",
+			}
+
+			token, err := s.Encode(exp)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to encode a cursor: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to encode a cursor.", success, testID)
+
+			var got position
+			if err := s.Decode(token, &got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the cursor: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the cursor.", success, testID)
+
+			if diff := cmp.Diff(exp, got); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same position. Diff:\n%s", failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same position.", success, testID)
+
+			forged, err := cursor.NewSigner([]byte("other")).Encode(exp)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to encode a cursor with another key: %v", failed, testID, err)
+			}
+			if err := s.Decode(forged, &got); err != cursor.ErrInvalid {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a cursor signed with another key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a cursor signed with another key.", success, testID)
+
+			if err := s.Decode(token[1:], &got); err != cursor.ErrInvalid {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a tampered cursor: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a tampered cursor.", success, testID)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"reflect"
 	"strings"
@@ -20,6 +22,14 @@ var (
 	ErrForbidden             = errors.New("action forbidden")
 )
 
+// Executor is the set of query methods shared by *sqlx.DB and *sqlx.Tx. Code
+// written against it can run standalone or as part of a caller's transaction.
+type Executor interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
 // Config is the required props for connecting to database.
 type Config struct {
 	User       string
@@ -62,19 +72,64 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
 
+// WithinTran runs fn inside a database transaction. The transaction is
+// committed when fn returns nil and rolled back when it returns an error or
+// panics. If db is already a *sqlx.Tx, fn joins it and the caller stays in
+// charge of committing or rolling it back.
+func WithinTran(ctx context.Context, db Executor, fn func(tx *sqlx.Tx) error) (err error) {
+	if tx, ok := db.(*sqlx.Tx); ok {
+		return fn(tx)
+	}
+
+	beginner, ok := db.(interface {
+		BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+	})
+	if !ok {
+		return errors.New("executor does not support transactions")
+	}
+
+	tx, err := beginner.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	// Roll back if fn panics and convert the panic into an error, so a
+	// broken transaction is never left open on the connection.
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			err = fmt.Errorf("panic in transaction: %v", r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rolling back transaction: %v: %w", rbErr, err)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
 // NamedQuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshaled into a slice.
-func NamedQuerySlice(ctx context.Context, db *sqlx.DB, query string, data interface{}, dest interface{}) error {
+func NamedQuerySlice(ctx context.Context, db Executor, query string, data interface{}, dest interface{}) error {
 
 	val := reflect.ValueOf(dest)
 	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
 		return errors.New("must provide a pointer to a slice")
 	}
 
-	rows, err := db.NamedQueryContext(ctx, query, data)
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	slice := val.Elem()
 	for rows.Next() {
@@ -85,17 +140,56 @@ func NamedQuerySlice(ctx context.Context, db *sqlx.DB, query string, data interf
 		slice.Set(reflect.Append(slice, v.Elem()))
 	}
 
-	return nil
+	return rows.Err()
+}
+
+// Iter iterates over the rows of a query one at a time, so a large result
+// is never held in memory. It holds on to a connection until closed.
+type Iter struct {
+	rows *sqlx.Rows
+}
+
+// NamedQueryIter is the companion of NamedQuerySlice for collections too
+// large to be unmarshaled into a slice at once. The rows are unmarshaled by
+// the Next method of the returned Iter, which must be closed.
+func NamedQueryIter(ctx context.Context, db Executor, query string, data interface{}) (*Iter, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Iter{rows: rows}, nil
+}
+
+// Next unmarshals the next row into the struct pointed to by dest. It
+// returns io.EOF after the last row.
+func (it *Iter) Next(dest interface{}) error {
+	if !it.rows.Next() {
+		if err := it.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	return it.rows.StructScan(dest)
+}
+
+// Close releases the connection held by the iterator. It is safe to call it
+// more than once.
+func (it *Iter) Close() error {
+	return it.rows.Close()
 }
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type.
-func NamedQueryStruct(ctx context.Context, db *sqlx.DB, query string, data interface{}, dest interface{}) error {
+func NamedQueryStruct(ctx context.Context, db Executor, query string, data interface{}, dest interface{}) error {
 
-	rows, err := db.NamedQueryContext(ctx, query, data)
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
 	if !rows.Next() {
 		return ErrNotFound
 	}
@@ -71,7 +71,7 @@ func DumpContainerLogs(t *testing.T, id string) {
 	if err != nil {
 		t.Fatalf("could not log container: %v", err)
 	}
-	t.Log("Logs for %s\n%s: ", id, out)
+	t.Logf("Logs for %s\n%s: ", id, out)
 }
 
 func extractIPPort(t *testing.T, doc []map[string]interface{}, port string) (string, string) {
@@ -0,0 +1,241 @@
+// Package events broadcasts the changes made to the records of the system to
+// the subscribers of a Broker. The latest events are kept in a bounded ring
+// buffer, so subscribers reconnecting can resume where they left off.
+package events
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// These are the defaults for the size of the ring buffer of a Broker and the
+// number of events buffered for each of its subscribers.
+const (
+	DefaultSize   = 1024
+	DefaultBuffer = 64
+)
+
+var (
+	// ErrLagged is the reason a subscription ends when its subscriber does not
+	// keep up with the events and its buffer is full.
+	ErrLagged = errors.New("subscriber fell behind")
+
+	// ErrClosed is the reason a subscription ends when the broker is closed,
+	// and is returned when subscribing to a closed broker.
+	ErrClosed = errors.New("broker closed")
+)
+
+// Event is a change made to a record. Type names the record and the change,
+// such as "user.created", and TargetID is the record changed. ID is set by
+// the broker and orders the events it publishes.
+type Event struct {
+	ID       string
+	Type     string
+	TargetID string
+	Data     interface{}
+}
+
+// Broker publishes events to its subscribers. Publishing never waits for a
+// subscriber: one whose buffer is full is dropped with ErrLagged, and is
+// expected to subscribe again from the last event it got. It is safe for
+// concurrent use.
+type Broker struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	ring   []Event
+	start  int
+	n      int
+	buffer int
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBroker constructs a Broker keeping the last size events and buffering
+// up to buffer events for each subscriber.
+//
+// Event IDs are made of the time the broker was constructed and a sequence,
+// so the IDs of a previous process are told apart from the current ones.
+func NewBroker(size int, buffer int) *Broker {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	return &Broker{
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:   make([]Event, size),
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next ID to an event about the target, keeps it and
+// sends it to the subscribers allowed to see it. It returns the event.
+func (b *Broker) Publish(typ string, targetID string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev := Event{
+		ID:       b.epoch + "-" + strconv.FormatUint(b.seq, 10),
+		Type:     typ,
+		TargetID: targetID,
+		Data:     data,
+	}
+	if b.closed {
+		return ev
+	}
+
+	// Once full, the ring overwrites its oldest event.
+	if b.n < len(b.ring) {
+		b.ring[(b.start+b.n)%len(b.ring)] = ev
+		b.n++
+	} else {
+		b.ring[b.start] = ev
+		b.start = (b.start + 1) % len(b.ring)
+	}
+
+	for s := range b.subs {
+		if s.allow != nil && !s.allow(ev) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			b.drop(s, ErrLagged)
+		}
+	}
+
+	return ev
+}
+
+// Subscribe starts receiving the events allow returns true for, every event
+// when allow is nil. allow is called while publishing and must be fast.
+//
+// With the ID of the last event a subscriber got, the events kept since
+// then are received first. When they are no longer kept, because the ID is
+// too old or comes from another process, the subscription has Missed set
+// and every event kept is received.
+func (b *Broker) Subscribe(lastEventID string, allow func(Event) bool) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	replay, missed := b.since(lastEventID)
+
+	s := Subscription{
+		b:      b,
+		allow:  allow,
+		Missed: missed,
+	}
+
+	var events []Event
+	for _, ev := range replay {
+		if allow == nil || allow(ev) {
+			events = append(events, ev)
+		}
+	}
+
+	s.ch = make(chan Event, b.buffer+len(events))
+	for _, ev := range events {
+		s.ch <- ev
+	}
+	b.subs[&s] = struct{}{}
+
+	return &s, nil
+}
+
+// Close ends every subscription with ErrClosed. Events published afterwards
+// are not sent nor kept.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s, ErrClosed)
+	}
+}
+
+// since returns the events kept after the one with the given ID, and
+// whether some of them are no longer kept.
+func (b *Broker) since(lastEventID string) ([]Event, bool) {
+	if lastEventID == "" {
+		return nil, false
+	}
+
+	// The first event kept has the sequence following the events evicted.
+	first := b.seq - uint64(b.n) + 1
+
+	var seq uint64
+	kept := false
+	if parts := strings.SplitN(lastEventID, "-", 2); len(parts) == 2 && parts[0] == b.epoch {
+		var err error
+		seq, err = strconv.ParseUint(parts[1], 10, 64)
+		kept = err == nil && seq <= b.seq && seq+1 >= first
+	}
+	if !kept {
+		seq = first - 1
+	}
+
+	events := make([]Event, 0, b.seq-seq)
+	for i := seq + 1 - first; i < uint64(b.n); i++ {
+		events = append(events, b.ring[(b.start+int(i))%len(b.ring)])
+	}
+
+	return events, !kept
+}
+
+// drop ends a subscription for the given reason. The lock must be held.
+func (b *Broker) drop(s *Subscription, reason error) {
+	delete(b.subs, s)
+	s.err = reason
+	close(s.ch)
+}
+
+// Subscription receives the events published to a Broker. Missed reports
+// whether events published since the last one the subscriber got were
+// lost, in which case the subscriber should reload the records it follows.
+type Subscription struct {
+	Missed bool
+
+	b     *Broker
+	allow func(Event) bool
+	ch    chan Event
+	err   error
+}
+
+// Events returns the channel the events are received from. It is closed
+// once the subscription ends, and Err tells why.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Err returns ErrLagged or ErrClosed when the subscription was ended by the
+// broker, and nil otherwise.
+func (s *Subscription) Err() error {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	return s.err
+}
+
+// Close ends the subscription. It can be called more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	if _, ok := s.b.subs[s]; ok {
+		delete(s.b.subs, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,177 @@
+package events_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/events"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// received returns the types of the events waiting in a subscription.
+func received(s *events.Subscription) []string {
+	var types []string
+	for {
+		select {
+		case ev, ok := <-s.Events():
+			if !ok {
+				return types
+			}
+			types = append(types, ev.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	t.Log("Given the need to broadcast events to subscribers.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen subscribers are allowed different events.", testID)
+		{
+			b := events.NewBroker(4, 4)
+			defer b.Close()
+
+			all, err := b.Subscribe("", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			mine, err := b.Subscribe("", func(ev events.Event) bool { return ev.TargetID == "1" })
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+
+			b.Publish("a", "1", nil)
+			b.Publish("b", "2", nil)
+
+			if got := strings.Join(received(all), ","); got != "a,b" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive every event: %s", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive every event.", success, testID)
+
+			if got := strings.Join(received(mine), ","); got != "a" {
+				t.Fatalf("\t%s\tTest %d:\tShould only receive the allowed events: %s", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only receive the allowed events.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen resuming from the last event received.", testID)
+		{
+			b := events.NewBroker(3, 4)
+			defer b.Close()
+
+			var ids []string
+			for _, typ := range []string{"a", "b", "c", "d", "e"} {
+				ids = append(ids, b.Publish(typ, "1", nil).ID)
+			}
+
+			s, err := b.Subscribe(ids[2], nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			if got := strings.Join(received(s), ","); got != "d,e" || s.Missed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive the events kept since then: %s %v", failed, testID, got, s.Missed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the events kept since then.", success, testID)
+
+			s, err = b.Subscribe(ids[1], nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			if got := strings.Join(received(s), ","); got != "c,d,e" || s.Missed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive every event kept right after an evicted one: %s %v", failed, testID, got, s.Missed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive every event kept right after an evicted one.", success, testID)
+
+			s, err = b.Subscribe(ids[4], nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			if got := received(s); len(got) != 0 || s.Missed {
+				t.Fatalf("\t%s\tTest %d:\tShould receive nothing when up to date: %v %v", failed, testID, got, s.Missed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive nothing when up to date.", success, testID)
+
+			for _, id := range []string{ids[0], "0-1", "garbage", ids[4] + "0"} {
+				s, err = b.Subscribe(id, nil)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+				}
+				if got := strings.Join(received(s), ","); got != "c,d,e" || !s.Missed {
+					t.Fatalf("\t%s\tTest %d:\tShould report the events missed from %q: %s %v", failed, testID, id, got, s.Missed)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the events no longer kept as missed.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen a subscriber falls behind.", testID)
+		{
+			b := events.NewBroker(8, 2)
+			defer b.Close()
+
+			slow, err := b.Subscribe("", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			fast, err := b.Subscribe("", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+
+			var got []string
+			for _, typ := range []string{"a", "b", "c"} {
+				b.Publish(typ, "1", nil)
+				got = append(got, received(fast)...)
+			}
+
+			if strings.Join(got, ",") != "a,b,c" || fast.Err() != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep sending to subscribers keeping up: %v %v", failed, testID, got, fast.Err())
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep sending to subscribers keeping up.", success, testID)
+
+			if got := strings.Join(received(slow), ","); got != "a,b" || slow.Err() != events.ErrLagged {
+				t.Fatalf("\t%s\tTest %d:\tShould drop the subscriber once its buffer is full: %s %v", failed, testID, got, slow.Err())
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop the subscriber once its buffer is full.", success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the broker is closed.", testID)
+		{
+			b := events.NewBroker(8, 2)
+
+			s, err := b.Subscribe("", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			s.Close()
+			s.Close()
+			if _, ok := <-s.Events(); ok || s.Err() != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould end a subscription closed by its subscriber: %v", failed, testID, s.Err())
+			}
+			t.Logf("\t%s\tTest %d:\tShould end a subscription closed by its subscriber.", success, testID)
+
+			s, err = b.Subscribe("", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			b.Close()
+			if _, ok := <-s.Events(); ok || s.Err() != events.ErrClosed {
+				t.Fatalf("\t%s\tTest %d:\tShould end the subscriptions: %v", failed, testID, s.Err())
+			}
+			t.Logf("\t%s\tTest %d:\tShould end the subscriptions.", success, testID)
+
+			if _, err := b.Subscribe("", nil); err != events.ErrClosed {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse new subscribers: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse new subscribers.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package keystore implements an in memory store of private keys loaded
+// from PEM files, where the file name of each key is used as its key id (KID).
+package keystore
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrKeyNotFound is returned when no key is stored for the requested kid.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyStore represents an in memory store of private keys indexed by kid.
+type KeyStore struct {
+	store    map[string]crypto.Signer
+	modTimes map[string]time.Time
+}
+
+// New constructs an empty KeyStore.
+func New() *KeyStore {
+	return &KeyStore{
+		store:    make(map[string]crypto.Signer),
+		modTimes: make(map[string]time.Time),
+	}
+}
+
+// NewFS constructs a KeyStore from every *.pem file found at the root of
+// the provided file system. A file named "54bb2165.pem" is stored under
+// the kid "54bb2165".
+func NewFS(fsys fs.FS) (*KeyStore, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "reading key directory")
+	}
+
+	ks := New()
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		info, err := fs.Stat(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading key file %s", entry.Name())
+		}
+
+		privatePEM, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading key file %s", entry.Name())
+		}
+
+		privateKey, err := ParsePrivateKey(privatePEM)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing key file %s", entry.Name())
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+		ks.store[kid] = privateKey
+		ks.modTimes[kid] = info.ModTime()
+	}
+
+	return ks, nil
+}
+
+// Keys returns a copy of the stored private keys indexed by kid.
+func (ks *KeyStore) Keys() map[string]crypto.Signer {
+	keys := make(map[string]crypto.Signer, len(ks.store))
+	for kid, privateKey := range ks.store {
+		keys[kid] = privateKey
+	}
+	return keys
+}
+
+// Newest returns the kid of the most recently modified key file, which is
+// the natural candidate for signing after a rotation. Ties are broken by
+// the greatest kid so the choice is stable. It returns an empty string
+// when the store is empty.
+func (ks *KeyStore) Newest() string {
+	var newest string
+	for kid, modTime := range ks.modTimes {
+		switch {
+		case newest == "",
+			modTime.After(ks.modTimes[newest]),
+			modTime.Equal(ks.modTimes[newest]) && kid > newest:
+			newest = kid
+		}
+	}
+	return newest
+}
+
+// PrivateKey returns the private key stored for the given kid.
+func (ks *KeyStore) PrivateKey(kid string) (crypto.Signer, error) {
+	privateKey, ok := ks.store[kid]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return privateKey, nil
+}
+
+// PublicKey returns the public key of the pair stored for the given kid.
+// It satisfies the auth.PublicKeyLookup signature.
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	privateKey, err := ks.PrivateKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return privateKey.Public(), nil
+}
+
+// ParsePrivateKey decodes a PEM encoded RSA, ECDSA or Ed25519 private key.
+// PKCS #1 ("RSA PRIVATE KEY"), SEC 1 ("EC PRIVATE KEY") and PKCS #8
+// ("PRIVATE KEY") encodings are supported.
+func ParsePrivateKey(privatePEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privatePEM)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	return signer, nil
+}
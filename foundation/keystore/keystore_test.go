@@ -0,0 +1,206 @@
+package keystore_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/keystore"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// newPEM generates a private key encoded in PEM form.
+func newPEM(t *testing.T) []byte {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating private key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+}
+
+func TestNewFS(t *testing.T) {
+	t.Log("Given the need to load private keys from a directory.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the directory holds pem files and other files.", testID)
+		{
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a private key: %v", failed, testID, err)
+			}
+
+			privatePEM := pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+			})
+
+			const kid = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+			fsys := fstest.MapFS{
+				kid + ".pem":   &fstest.MapFile{Data: privatePEM},
+				"README.md":    &fstest.MapFile{Data: []byte("not a key")},
+				"old/skip.pem": &fstest.MapFile{Data: []byte("nested files are ignored")},
+			}
+
+			ks, err := keystore.NewFS(fsys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the key store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to load the key store.", success, testID)
+
+			if got := len(ks.Keys()); got != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould load only the pem files at the root: got %d keys", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould load only the pem files at the root.", success, testID)
+
+			publicKey, err := ks.PublicKey(kid)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould find the key by its file name: %v", failed, testID, err)
+			}
+			if !privateKey.PublicKey.Equal(publicKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould return the matching public key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the key by its file name.", success, testID)
+
+			if _, err := ks.PublicKey("unknown"); err != keystore.ErrKeyNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould fail for an unknown kid: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail for an unknown kid.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a pem file is not a private key.", testID)
+		{
+			fsys := fstest.MapFS{
+				"broken.pem": &fstest.MapFile{Data: []byte("garbage")},
+			}
+
+			if _, err := keystore.NewFS(fsys); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to load the key store.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to load the key store.", success, testID)
+		}
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating rsa key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatalf("marshaling ecdsa key: %v", err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	if err != nil {
+		t.Fatalf("marshaling ed25519 key: %v", err)
+	}
+
+	tt := []struct {
+		name  string
+		block pem.Block
+	}{
+		{"RSA", pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}},
+		{"ECDSA", pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}},
+		{"Ed25519", pem.Block{Type: "PRIVATE KEY", Bytes: edDER}},
+	}
+
+	t.Log("Given the need to load different types of private keys.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen parsing a %s key.", testID, tst.name)
+			{
+				if _, err := keystore.ParsePrivateKey(pem.EncodeToMemory(&tst.block)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the key: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to parse the key.", success, testID)
+			}
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Log("Given the need to reload the keys when the directory changes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a new key file is written.", testID)
+		{
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "first.pem"), newPEM(t), 0600); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the first key: %v", failed, testID, err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			reloads := make(chan *keystore.KeyStore, 1)
+			go keystore.Watch(ctx, os.DirFS(dir), 10*time.Millisecond, func(ks *keystore.KeyStore, err error) {
+				if err != nil {
+					t.Errorf("\t%s\tTest %d:\tShould be able to reload the keys: %v", failed, testID, err)
+					return
+				}
+				reloads <- ks
+			})
+
+			// Give the watcher time to take its first look at the directory.
+			time.Sleep(50 * time.Millisecond)
+
+			// The key is written aside and renamed into place, like a deployment
+			// would do. Its modification time is pushed forward so it can not
+			// share the one of the first key on file systems with a coarse clock.
+			tmp := filepath.Join(dir, "second.tmp")
+			if err := os.WriteFile(tmp, newPEM(t), 0600); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the second key: %v", failed, testID, err)
+			}
+			future := time.Now().Add(time.Minute)
+			if err := os.Chtimes(tmp, future, future); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to touch the second key: %v", failed, testID, err)
+			}
+			if err := os.Rename(tmp, filepath.Join(dir, "second.pem")); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to move the second key: %v", failed, testID, err)
+			}
+
+			select {
+			case ks := <-reloads:
+				if got := len(ks.Keys()); got != 2 {
+					t.Fatalf("\t%s\tTest %d:\tShould reload both keys: got %d", failed, testID, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould reload both keys.", success, testID)
+
+				if got := ks.Newest(); got != "second" {
+					t.Fatalf("\t%s\tTest %d:\tShould report the new key as the newest: got %q", failed, testID, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould report the new key as the newest.", success, testID)
+
+			case <-time.After(5 * time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould reload the keys after a change.", failed, testID)
+			}
+		}
+	}
+}
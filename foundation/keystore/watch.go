@@ -0,0 +1,68 @@
+package keystore
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watch polls the file system every interval and calls fn with a freshly
+// loaded KeyStore whenever a *.pem file is added, removed or modified. A
+// failed load is passed to fn as an error, and is retried on the next
+// change. Watch blocks until the context is canceled.
+//
+// Polling is used instead of file system notifications so the same code
+// works for bind mounts and Kubernetes secret volumes, which swap files
+// through symlinks.
+func Watch(ctx context.Context, fsys fs.FS, interval time.Duration, fn func(*KeyStore, error)) {
+	last := snapshot(fsys)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			current := snapshot(fsys)
+			if current == last {
+				continue
+			}
+			last = current
+
+			fn(NewFS(fsys))
+		}
+	}
+}
+
+// snapshot describes the *.pem files of the file system with their size
+// and modification time. Two equal snapshots mean nothing changed.
+func snapshot(fsys fs.FS) string {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		info, err := fs.Stat(fsys, entry.Name())
+		if err != nil {
+			lines = append(lines, entry.Name()+" error: "+err.Error())
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", entry.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
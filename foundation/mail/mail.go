@@ -0,0 +1,175 @@
+// Package mail provides a pluggable way of sending emails, along with an SMTP
+// implementation and implementations for local development and tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidHeader is returned when an address or the subject of a message
+// contains a line break, which would let it inject headers.
+var ErrInvalidHeader = errors.New("invalid message header")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// =============================================================================
+
+// SMTPConfig holds the settings of an SMTP server. Username can be left empty
+// for servers not requiring authentication.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP sends emails through an SMTP server.
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTP constructs a Mailer sending through the configured SMTP server.
+// Credentials are only sent over TLS, which the server must support.
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	s := SMTP{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return &s
+}
+
+// Send delivers the message to the SMTP server.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := format(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, data); err != nil {
+		return errors.Wrapf(err, "sending mail to %s", msg.To)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Log writes emails to a logger instead of sending them. It is meant for
+// local development.
+type Log struct {
+	log  *zap.SugaredLogger
+	from string
+}
+
+// NewLog constructs a Mailer writing to the logger.
+func NewLog(log *zap.SugaredLogger, from string) *Log {
+	return &Log{
+		log:  log,
+		from: from,
+	}
+}
+
+// Send logs the message.
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	if _, err := format(l.from, msg, time.Now()); err != nil {
+		return err
+	}
+
+	l.log.Infow("mail", "from", l.from, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// =============================================================================
+
+// File writes every email to its own file in a folder instead of sending it,
+// so messages can be read back by tests or opened with a mail client.
+type File struct {
+	dir  string
+	from string
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewFile constructs a Mailer writing .eml files to the folder, which must
+// exist.
+func NewFile(dir string, from string) *File {
+	return &File{
+		dir:  dir,
+		from: from,
+	}
+}
+
+// Send writes the message to a new file. Files are named after the time they
+// were written, so listing the folder returns them in order.
+func (f *File) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+
+	data, err := format(f.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.n++
+	name := fmt.Sprintf("%s-%06d.eml", now.UTC().Format("20060102T150405.000000000"), f.n)
+	if err := os.WriteFile(filepath.Join(f.dir, name), data, 0600); err != nil {
+		return errors.Wrapf(err, "writing mail to %s", msg.To)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// format renders the message in the Internet Message Format.
+func format(from string, msg Message, now time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
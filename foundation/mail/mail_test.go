@@ -0,0 +1,74 @@
+package mail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/mail"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestFile(t *testing.T) {
+	t.Log("Given the need to write emails to files.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sending two messages.", testID)
+		{
+			dir := t.TempDir()
+			m := mail.NewFile(dir, "no-reply@example.com")
+
+			for _, subject := range []string{"First", "Second"} {
+				msg := mail.Message{
+					To:      "user@example.com",
+					Subject: subject,
+					Body:    "Hello\nGopher",
+				}
+				if err := m.Send(context.Background(), msg); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to send the message : %s.", failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to send the messages.", success, testID)
+
+			files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+			if err != nil || len(files) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould write a file per message : %v %v.", failed, testID, files, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write a file per message.", success, testID)
+
+			data, err := os.ReadFile(files[1])
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the message : %s.", failed, testID, err)
+			}
+			got := string(data)
+			for _, want := range []string{"From: no-reply@example.com\r\n", "To: user@example.com\r\n", "Subject: Second\r\n", "\r\n\r\nHello\r\nGopher"} {
+				if !strings.Contains(got, want) {
+					t.Fatalf("\t%s\tTest %d:\tShould write the messages in order and format : missing %q in %q.", failed, testID, want, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould write the messages in order and format.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a header contains a line break.", testID)
+		{
+			m := mail.NewFile(t.TempDir(), "no-reply@example.com")
+
+			msg := mail.Message{
+				To:      "user@example.com\r\nBcc: victim@example.com",
+				Subject: "Hello",
+			}
+			if err := m.Send(context.Background(), msg); err != mail.ErrInvalidHeader {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT send the message : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT send the message.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package token generates the secrets handed out to clients, such as refresh
+// tokens and API keys, and hashes them so only their hash is stored.
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// New returns a random token of 256 bits, base64url encoded without padding.
+func New() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Hash returns the hex encoded SHA-256 of a token. Tokens made by New carry
+// 256 bits of entropy, so a fast hash is enough to protect them at rest.
+func Hash(tkn string) string {
+	sum := sha256.Sum256([]byte(tkn))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,47 @@
+package token_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/token"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestToken(t *testing.T) {
+	t.Log("Given the need to hand out secrets and store only their hash.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen generating tokens.", testID)
+		{
+			a, err := token.New()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a token: %v", failed, testID, err)
+			}
+			b, err := token.New()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a token: %v", failed, testID, err)
+			}
+
+			raw, err := base64.RawURLEncoding.DecodeString(a)
+			if err != nil || len(raw) != 32 || a == b {
+				t.Fatalf("\t%s\tTest %d:\tShould get distinct tokens of 256 bits: %q %q %v", failed, testID, a, b, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get distinct tokens of 256 bits.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen hashing tokens.", testID)
+		{
+			const exp = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+			if got := token.Hash("hello"); got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould get the hex encoded SHA-256: %s", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the hex encoded SHA-256.", success, testID)
+		}
+	}
+}
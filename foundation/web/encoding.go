@@ -0,0 +1,357 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The media types of the encoders and decoders registered by default.
+const (
+	JSONType    = "application/json"
+	MsgpackType = "application/msgpack"
+)
+
+// ProtobufType is the media type of protocol buffer messages. Its codecs are
+// not registered by default, since only the values implementing
+// ProtoMarshaler or ProtoUnmarshaler can use them: programs defining such
+// messages opt in by registering EncodeProtobuf and DecodeProtobuf.
+const ProtobufType = "application/x-protobuf"
+
+var (
+	// ErrNotAcceptable is returned when a response can be encoded in none of
+	// the media types the client accepts.
+	ErrNotAcceptable = errors.New("no acceptable media type for the response")
+
+	// ErrUnsupportedMediaType is returned when no decoder is registered for
+	// the media type of a request body.
+	ErrUnsupportedMediaType = errors.New("unsupported media type for the request body")
+
+	// ErrNotEncodable is returned by an Encoder for a value it can not
+	// represent, so Respond can try the next media type the client accepts.
+	ErrNotEncodable = errors.New("value can not be encoded in the media type")
+)
+
+// Encoder encodes the data of a response. It returns ErrNotEncodable for
+// values it has no representation for.
+type Encoder func(val interface{}) ([]byte, error)
+
+// Decoder decodes a request body into the value pointed to by val. Like
+// the json package, it should reject fields val does not have.
+type Decoder func(r io.Reader, val interface{}) error
+
+// ProtoMarshaler is implemented by protocol buffer messages generated with
+// marshaling methods, which are encoded as ProtobufType.
+type ProtoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// ProtoUnmarshaler is implemented by protocol buffer messages generated
+// with unmarshaling methods, which are decoded from ProtobufType.
+type ProtoUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+// Rower is implemented by values which are lists of rows in an envelope,
+// such as pages, so they can be encoded as CSV. Rows returns a slice of
+// structs.
+type Rower interface {
+	Rows() interface{}
+}
+
+// registeredEncoder associates an encoder with its media type.
+type registeredEncoder struct {
+	mediaType string
+	enc       Encoder
+}
+
+// registeredDecoder associates a decoder with its media type.
+type registeredDecoder struct {
+	mediaType string
+	dec       Decoder
+}
+
+// encoders and decoders hold the codecs by media type, in the order they
+// were registered. The first encoder is used when a client accepts any
+// media type, and the first decoder for bodies without a Content-Type.
+var (
+	encoders struct {
+		sync.RWMutex
+		list []registeredEncoder
+	}
+	decoders struct {
+		sync.RWMutex
+		list []registeredDecoder
+	}
+)
+
+func init() {
+	RegisterEncoder(JSONType, json.Marshal)
+	RegisterEncoder(MsgpackType, encodeMsgpack)
+	RegisterEncoder("application/x-msgpack", encodeMsgpack)
+	RegisterEncoder(CSVType, encodeCSV)
+
+	RegisterDecoder(JSONType, decodeJSON)
+	RegisterDecoder(MsgpackType, decodeMsgpack)
+	RegisterDecoder("application/x-msgpack", decodeMsgpack)
+}
+
+// RegisterEncoder sets the encoder Respond uses for a media type. Encoders
+// are meant to be registered once, when a program starts. Registering a
+// media type again replaces its encoder.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	encoders.Lock()
+	defer encoders.Unlock()
+
+	for i, re := range encoders.list {
+		if re.mediaType == mediaType {
+			encoders.list[i].enc = enc
+			return
+		}
+	}
+	encoders.list = append(encoders.list, registeredEncoder{mediaType: mediaType, enc: enc})
+}
+
+// RegisterDecoder sets the decoder Decode uses for a media type. Decoders
+// are meant to be registered once, when a program starts. Registering a
+// media type again replaces its decoder.
+func RegisterDecoder(mediaType string, dec Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+
+	for i, rd := range decoders.list {
+		if rd.mediaType == mediaType {
+			decoders.list[i].dec = dec
+			return
+		}
+	}
+	decoders.list = append(decoders.list, registeredDecoder{mediaType: mediaType, dec: dec})
+}
+
+// encode encodes data in the media type the client prefers among the ones
+// with an encoder, given the value of its Accept header. Media types whose
+// encoder can not represent data are skipped.
+func encode(accept string, data interface{}) (string, []byte, error) {
+	encoders.RLock()
+	offers := make([]string, len(encoders.list))
+	encs := make(map[string]Encoder, len(encoders.list))
+	for i, re := range encoders.list {
+		offers[i] = re.mediaType
+		encs[re.mediaType] = re.enc
+	}
+	encoders.RUnlock()
+
+	for _, mediaType := range negotiate(accept, offers) {
+		body, err := encs[mediaType](data)
+		if errors.Is(err, ErrNotEncodable) {
+			continue
+		}
+		return mediaType, body, err
+	}
+
+	return "", nil, NewRequestError(ErrNotAcceptable, http.StatusNotAcceptable)
+}
+
+// decode decodes a request body with the decoder of its media type.
+func decode(contentType string, r io.Reader, val interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	decoders.RLock()
+	var dec Decoder
+	for i, rd := range decoders.list {
+		if rd.mediaType == mediaType || (contentType == "" && i == 0) {
+			dec = rd.dec
+			break
+		}
+	}
+	decoders.RUnlock()
+
+	if dec == nil {
+		return NewRequestError(ErrUnsupportedMediaType, http.StatusUnsupportedMediaType)
+	}
+	return dec(r, val)
+}
+
+// Negotiate picks the media type a client prefers among offers from the
+// value of its Accept header. Media ranges are tried in order of quality,
+// then of specificity, and offers in the order given. A client sending no
+// Accept header gets the first offer. ok is false when none is acceptable.
+func Negotiate(accept string, offers ...string) (mediaType string, ok bool) {
+	if matches := negotiate(accept, offers); len(matches) > 0 {
+		return matches[0], true
+	}
+	return "", false
+}
+
+// negotiate returns the offers a client accepts, preferred ones first.
+func negotiate(accept string, offers []string) []string {
+	if strings.TrimSpace(accept) == "" {
+		return offers
+	}
+
+	type mediaRange struct {
+		typ     string
+		subtype string
+		q       float64
+	}
+
+	// Ranges with a quality of 0 exclude the offers they cover, unless a
+	// more specific range accepts them.
+	var ranges, excluded []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				q = 0
+			}
+		}
+
+		typ := strings.SplitN(mediaType, "/", 2)
+		if len(typ) != 2 {
+			continue
+		}
+		mr := mediaRange{typ: typ[0], subtype: typ[1], q: q}
+		if q <= 0 {
+			excluded = append(excluded, mr)
+			continue
+		}
+		ranges = append(ranges, mr)
+	}
+
+	specificity := func(mr mediaRange) int {
+		switch {
+		case mr.typ == "*":
+			return 0
+		case mr.subtype == "*":
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i]) > specificity(ranges[j])
+	})
+
+	covers := func(mr mediaRange, typ []string) bool {
+		return (mr.typ == "*" || mr.typ == typ[0]) && (mr.subtype == "*" || mr.subtype == typ[1])
+	}
+
+	var matches []string
+	seen := make(map[string]bool)
+	for _, mr := range ranges {
+	offers:
+		for _, offer := range offers {
+			typ := strings.SplitN(offer, "/", 2)
+			if seen[offer] || len(typ) != 2 || !covers(mr, typ) {
+				continue
+			}
+			for _, ex := range excluded {
+				if covers(ex, typ) && specificity(ex) >= specificity(mr) {
+					continue offers
+				}
+			}
+			matches = append(matches, offer)
+			seen[offer] = true
+		}
+	}
+
+	return matches
+}
+
+// decodeJSON decodes a JSON document, rejecting unknown fields.
+func decodeJSON(r io.Reader, val interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(val)
+}
+
+// EncodeProtobuf encodes protocol buffer messages. It is the Encoder to
+// register for ProtobufType.
+func EncodeProtobuf(val interface{}) ([]byte, error) {
+	m, ok := val.(ProtoMarshaler)
+	if !ok {
+		return nil, ErrNotEncodable
+	}
+	return m.Marshal()
+}
+
+// DecodeProtobuf decodes protocol buffer messages. It is the Decoder to
+// register for ProtobufType.
+func DecodeProtobuf(r io.Reader, val interface{}) error {
+	m, ok := val.(ProtoUnmarshaler)
+	if !ok {
+		return NewRequestError(ErrUnsupportedMediaType, http.StatusUnsupportedMediaType)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return m.Unmarshal(data)
+}
+
+// encodeCSV encodes a struct, a slice of structs or the rows of a Rower as
+// CSV, with a header row.
+func encodeCSV(val interface{}) ([]byte, error) {
+	if rower, ok := val.(Rower); ok {
+		val = rower.Rows()
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(val))
+	var rows []reflect.Value
+	switch rv.Kind() {
+	case reflect.Struct:
+		rows = append(rows, rv)
+	case reflect.Slice, reflect.Array:
+		elem := rv.Type().Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Struct {
+			return nil, ErrNotEncodable
+		}
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, rv.Index(i))
+		}
+
+		// An empty list still gets its header.
+		if len(rows) == 0 {
+			names, _ := rowColumns(reflect.Zero(elem))
+			return []byte(strings.Join(names, ",") + "\n"), nil
+		}
+	default:
+		return nil, ErrNotEncodable
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewRowEncoder(&buf, CSVType)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if err := enc.Encode(row.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
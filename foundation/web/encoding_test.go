@@ -0,0 +1,293 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type gopher struct {
+	Name  string    `json:"name" validate:"required"`
+	Tags  []string  `json:"tags,omitempty"`
+	Age   int       `json:"age"`
+	Born  time.Time `json:"born"`
+	Token string    `json:"-"`
+}
+
+type gopherPage struct {
+	Items []gopher `json:"items"`
+}
+
+func (p gopherPage) Rows() interface{} {
+	return p.Items
+}
+
+// protoGopher stands for a protocol buffer message with generated
+// marshaling methods.
+type protoGopher struct {
+	name string
+}
+
+func (p *protoGopher) Marshal() ([]byte, error) {
+	return append([]byte{0x0a, byte(len(p.name))}, p.name...), nil
+}
+
+func (p *protoGopher) Unmarshal(data []byte) error {
+	if len(data) < 2 || data[0] != 0x0a || int(data[1]) != len(data)-2 {
+		return errors.New("malformed message")
+	}
+	p.name = string(data[2:])
+	return nil
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{web.JSONType, web.CSVType, web.MsgpackType}
+
+	t.Log("Given the need to negotiate the media type of responses.")
+	{
+		for testID, tt := range []struct {
+			accept string
+			want   string
+		}{
+			{"", web.JSONType},
+			{"*/*", web.JSONType},
+			{"text/csv", web.CSVType},
+			{"text/*", web.CSVType},
+			{"text/html, application/msgpack;q=0.9, */*;q=0.1", web.MsgpackType},
+			{"application/*;q=0.5, text/csv;q=0.8", web.CSVType},
+			{"application/*, application/msgpack", web.MsgpackType},
+			{"*/*, application/json;q=0", web.CSVType},
+			{"text/*;q=0, */*", web.JSONType},
+			{"text/*;q=0, application/json;q=0, */*", web.MsgpackType},
+			{"text/*;q=0, text/csv", web.CSVType},
+			{"*/*;q=0, text/*", web.CSVType},
+			{"text/*;q=0", ""},
+			{"text/csv; charset=utf-8", web.CSVType},
+			{"text/html", ""},
+		} {
+			t.Logf("\tTest %d:\tWhen accepting %q.", testID, tt.accept)
+			{
+				got, ok := web.Negotiate(tt.accept, offers...)
+				if got != tt.want || ok != (tt.want != "") {
+					t.Fatalf("\t%s\tTest %d:\tShould pick %q: %q", failed, testID, tt.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould pick %q.", success, testID, tt.want)
+			}
+		}
+	}
+}
+
+func TestRespond(t *testing.T) {
+	// Protocol buffers are opt-in.
+	web.RegisterEncoder(web.ProtobufType, web.EncodeProtobuf)
+	web.RegisterEncoder("text/plain", func(val interface{}) ([]byte, error) {
+		g, ok := val.(gopher)
+		if !ok {
+			return nil, web.ErrNotEncodable
+		}
+		return []byte(g.Name), nil
+	})
+
+	born := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	bob := gopher{Name: "Bob", Tags: []string{"go", "mascot"}, Age: 12, Born: born, Token: "secret"}
+
+	t.Log("Given the need to respond in the media type clients prefer.")
+	{
+		for testID, tt := range []struct {
+			name     string
+			accept   string
+			data     interface{}
+			status   int
+			wantType string
+			want     string
+		}{
+			{
+				"json by default", "", bob, http.StatusOK, web.JSONType,
+				`{"name":"Bob","tags":["go","mascot"],"age":12,"born":"2009-11-10T23:00:00Z"}`,
+			},
+			{
+				"a csv row", "text/csv", bob, http.StatusOK, web.CSVType,
+				"name,tags,age,born\nBob,go;mascot,12,2009-11-10T23:00:00Z\n",
+			},
+			{
+				"the csv rows of a page", "text/csv", gopherPage{Items: []gopher{bob, {Name: "Alice"}}}, http.StatusOK, web.CSVType,
+				"name,tags,age,born\nBob,go;mascot,12,2009-11-10T23:00:00Z\nAlice,,0,0001-01-01T00:00:00Z\n",
+			},
+			{
+				"the csv header of an empty page", "text/csv", gopherPage{}, http.StatusOK, web.CSVType,
+				"name,tags,age,born\n",
+			},
+			{
+				"msgpack", "application/msgpack", map[string]interface{}{"a": -1, "b": []interface{}{true, nil, "x"}, "c": 1.5, "d": 300}, http.StatusOK, web.MsgpackType,
+				"\x84\xa1a\xff\xa1b\x93\xc3\xc0\xa1x\xa1c\xcb\x3f\xf8\x00\x00\x00\x00\x00\x00\xa1d\xcd\x01\x2c",
+			},
+			{
+				"a protobuf message", "application/x-protobuf", &protoGopher{name: "Bob"}, http.StatusOK, web.ProtobufType,
+				"\x0a\x03Bob",
+			},
+			{
+				"a registered encoder", "text/plain", bob, http.StatusOK, "text/plain",
+				"Bob",
+			},
+			{
+				"the next acceptable media type", "text/csv, application/json;q=0.5", []string{"Bob"}, http.StatusOK, web.JSONType,
+				`["Bob"]`,
+			},
+			{
+				"no body", "text/html", nil, http.StatusNoContent, "",
+				"",
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen responding with %s.", testID, tt.name)
+			{
+				v := web.Values{Accept: tt.accept}
+				ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+				w := httptest.NewRecorder()
+
+				if err := web.Respond(ctx, w, tt.data, tt.status); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to respond: %v", failed, testID, err)
+				}
+
+				if w.Code != tt.status || v.StatusCode != tt.status || w.Header().Get("Content-Type") != tt.wantType {
+					t.Fatalf("\t%s\tTest %d:\tShould respond with %q: %d %s", failed, testID, tt.wantType, w.Code, w.Header().Get("Content-Type"))
+				}
+				t.Logf("\t%s\tTest %d:\tShould respond with %q.", success, testID, tt.wantType)
+
+				if diff := cmp.Diff(w.Body.String(), tt.want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould encode the data. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould encode the data.", success, testID)
+			}
+		}
+
+		for testID, tt := range []struct {
+			accept string
+			data   interface{}
+		}{
+			{"text/html", bob},
+			{"application/x-protobuf", bob},
+			{"text/csv", "Bob"},
+		} {
+			testID += 9
+			t.Logf("\tTest %d:\tWhen responding with nothing acceptable for %q.", testID, tt.accept)
+			{
+				v := web.Values{Accept: tt.accept}
+				ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+				w := httptest.NewRecorder()
+
+				err := web.Respond(ctx, w, tt.data, http.StatusOK)
+				webErr, ok := errors.Cause(err).(*web.Error)
+				if !ok || webErr.Status != http.StatusNotAcceptable || webErr.Err != web.ErrNotAcceptable {
+					t.Fatalf("\t%s\tTest %d:\tShould get a not acceptable error: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get a not acceptable error.", success, testID)
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	web.RegisterDecoder(web.ProtobufType, web.DecodeProtobuf)
+
+	born := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to decode request bodies of several media types.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen decoding JSON and MessagePack.", testID)
+		{
+			for _, contentType := range []string{"", "application/json; charset=utf-8"} {
+				r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"Bob","age":12}`))
+				r.Header.Set("Content-Type", contentType)
+
+				var g gopher
+				if err := web.Decode(r, &g); err != nil || g.Name != "Bob" || g.Age != 12 {
+					t.Fatalf("\t%s\tTest %d:\tShould decode JSON with Content-Type %q: %v %+v", failed, testID, contentType, err, g)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould decode JSON.", success, testID)
+
+			// name: "Bob", age: 12, born: timestamp extension of 8 bytes.
+			var body bytes.Buffer
+			body.WriteString("\x83\xa4name\xa3Bob\xa3age\x0c\xa4born\xd7\xff")
+			body.Write([]byte{0, 0, 0, 0, 0x4a, 0xf9, 0xf0, 0x70})
+
+			r := httptest.NewRequest(http.MethodPost, "/", &body)
+			r.Header.Set("Content-Type", web.MsgpackType)
+
+			var g gopher
+			if err := web.Decode(r, &g); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould decode MessagePack: %v", failed, testID, err)
+			}
+			if want := (gopher{Name: "Bob", Age: 12, Born: born}); !cmp.Equal(g, want) {
+				t.Fatalf("\t%s\tTest %d:\tShould decode MessagePack. Diff:\n%s", failed, testID, cmp.Diff(g, want))
+			}
+			t.Logf("\t%s\tTest %d:\tShould decode MessagePack.", success, testID)
+
+			v := web.Values{Accept: web.MsgpackType}
+			ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+			w := httptest.NewRecorder()
+			if err := web.Respond(ctx, w, gopher{Name: "Bob", Tags: []string{"go"}, Age: -300, Born: born}, http.StatusOK); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to respond: %v", failed, testID, err)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/", w.Body)
+			r.Header.Set("Content-Type", web.MsgpackType)
+			g = gopher{}
+			if err := web.Decode(r, &g); err != nil || !cmp.Equal(g, gopher{Name: "Bob", Tags: []string{"go"}, Age: -300, Born: born}) {
+				t.Fatalf("\t%s\tTest %d:\tShould decode the MessagePack it encodes: %v %+v", failed, testID, err, g)
+			}
+			t.Logf("\t%s\tTest %d:\tShould decode the MessagePack it encodes.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen decoding a protobuf message.", testID)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("\x0a\x03Bob"))
+			r.Header.Set("Content-Type", web.ProtobufType)
+
+			var p protoGopher
+			if err := web.Decode(r, &p); err != nil || p.name != "Bob" {
+				t.Fatalf("\t%s\tTest %d:\tShould decode the message: %v %+v", failed, testID, err, p)
+			}
+			t.Logf("\t%s\tTest %d:\tShould decode the message.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen decoding bodies that can not be decoded.", testID)
+		{
+			for _, tt := range []struct {
+				contentType string
+				body        string
+				status      int
+			}{
+				{"text/plain", "Bob", http.StatusUnsupportedMediaType},
+				{web.ProtobufType, "\x0a\x03Bob", http.StatusUnsupportedMediaType},
+				{web.MsgpackType, "\x81\xa4name", http.StatusBadRequest},
+				{web.MsgpackType, "\x81\xa4name\xa3Bob\xc0", http.StatusBadRequest},
+				{web.MsgpackType, "\xdd\xff\xff\xff\xff", http.StatusBadRequest},
+				{web.MsgpackType, "\x81\xa5color\xa4blue", http.StatusBadRequest},
+				{web.MsgpackType, "\x80", http.StatusBadRequest},
+			} {
+				r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+				r.Header.Set("Content-Type", tt.contentType)
+
+				var g gopher
+				err := web.Decode(r, &g)
+				webErr, ok := errors.Cause(err).(*web.Error)
+				if !ok || webErr.Status != tt.status {
+					t.Fatalf("\t%s\tTest %d:\tShould get a %d error for %q: %v", failed, testID, tt.status, tt.body, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject them with the status of the problem.", success, testID)
+		}
+	}
+}
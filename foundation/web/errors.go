@@ -1,6 +1,10 @@
 package web
 
 import (
+	"net/http"
+	"strings"
+	"sync"
+
 	"github.com/pkg/errors"
 )
 
@@ -10,24 +14,42 @@ type FieldError struct {
 	Error string `json:"error"`
 }
 
-// ErrorResponse is for API responses.
-type ErrorResponse struct {
-	Error  string       `json:"error"`
-	Fields []FieldError `json:"fields,omitempty"`
+// ProblemType is the media type of error responses.
+const ProblemType = "application/problem+json"
+
+// CodeValidation is the code of the errors reporting invalid fields.
+const CodeValidation = "validation_failed"
+
+// Problem is the body of error responses, as defined by RFC 7807. Problems
+// are told apart by their Code extension rather than by their Type, which is
+// always "about:blank", so no documentation needs to be served for them.
+// Field errors and the trace ID are extensions as well.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	TraceID  string       `json:"trace_id,omitempty"`
+	Fields   []FieldError `json:"fields,omitempty"`
 }
 
 // Error is used to pass an error during the request through the
-// application with specific context.
+// application with specific context. Code is the stable identifier clients
+// can branch on. When empty, the code registered for Err is used, or one
+// derived from Status.
 type Error struct {
 	Err    error
 	Status int
+	Code   string
 	Fields []FieldError
 }
 
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &Error{err, status, nil}
+	return &Error{Err: err, Status: status}
 }
 
 // Error method implements the error interface. It uses the default message of the
@@ -36,6 +58,65 @@ func (err *Error) Error() string {
 	return err.Err.Error()
 }
 
+// registeredCode associates an error with its code.
+type registeredCode struct {
+	target error
+	code   string
+}
+
+// codes holds the codes registered with RegisterCode.
+var codes struct {
+	sync.RWMutex
+	list []registeredCode
+}
+
+// RegisterCode associates a stable code with target, so every Error
+// wrapping it is reported with that code. Codes are meant to be registered
+// once, when a program starts. Registering target again replaces its code.
+func RegisterCode(target error, code string) {
+	codes.Lock()
+	defer codes.Unlock()
+
+	for i, rc := range codes.list {
+		if rc.target == target {
+			codes.list[i].code = code
+			return
+		}
+	}
+	codes.list = append(codes.list, registeredCode{target: target, code: code})
+}
+
+// ErrorCode returns the code clients see for err: its own code, the code
+// registered for the error it wraps, or a code derived from its status,
+// such as "not_found".
+func (err *Error) ErrorCode() string {
+	if err.Code != "" {
+		return err.Code
+	}
+
+	codes.RLock()
+	defer codes.RUnlock()
+
+	for _, rc := range codes.list {
+		if errors.Is(err.Err, rc.target) {
+			return rc.code
+		}
+	}
+
+	return StatusCode(err.Status)
+}
+
+// StatusCode derives a code from an HTTP status, such as "not_found" for
+// 404.
+func StatusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "unknown_error"
+	}
+	text = strings.NewReplacer("-", "_", "'", "").Replace(text)
+	return strings.ToLower(strings.Join(strings.Fields(text), "_"))
+}
+
 // shutdown is a type used to help with the graceful termination of the service.
 type shutdown struct {
 	Message string
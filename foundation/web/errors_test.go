@@ -0,0 +1,78 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+var errOutOfGophers = errors.New("out of gophers")
+
+func TestRespondError(t *testing.T) {
+	web.RegisterCode(errOutOfGophers, "out_of_gophers")
+
+	t.Log("Given the need to report errors as problem documents.")
+	{
+		for testID, tt := range []struct {
+			name string
+			err  error
+			want web.Problem
+		}{
+			{
+				"an error with its own code",
+				&web.Error{Err: errors.New("bad rows"), Status: http.StatusBadRequest, Code: "bad_rows", Fields: []web.FieldError{{Field: "rows", Error: "rows is required"}}},
+				web.Problem{Title: "Bad Request", Status: http.StatusBadRequest, Detail: "bad rows", Code: "bad_rows", Fields: []web.FieldError{{Field: "rows", Error: "rows is required"}}},
+			},
+			{
+				"an error wrapping a registered error",
+				errors.Wrap(web.NewRequestError(errors.Wrap(errOutOfGophers, "selling"), http.StatusConflict), "handler"),
+				web.Problem{Title: "Conflict", Status: http.StatusConflict, Detail: "selling: out of gophers", Code: "out_of_gophers"},
+			},
+			{
+				"an error without a code",
+				web.NewRequestError(errors.New("no such gopher"), http.StatusNotFound),
+				web.Problem{Title: "Not Found", Status: http.StatusNotFound, Detail: "no such gopher", Code: "not_found"},
+			},
+			{
+				"an untrusted error",
+				errors.New("connection refused"),
+				web.Problem{Title: "Internal Server Error", Status: http.StatusInternalServerError, Code: "internal_server_error"},
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen responding with %s.", testID, tt.name)
+			{
+				v := web.Values{TraceID: "0123456789abcdef", Path: "/v1/gophers"}
+				ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+				w := httptest.NewRecorder()
+
+				if err := web.RespondError(ctx, w, tt.err); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to respond: %v", failed, testID, err)
+				}
+
+				if w.Code != tt.want.Status || v.StatusCode != tt.want.Status || w.Header().Get("Content-Type") != web.ProblemType {
+					t.Fatalf("\t%s\tTest %d:\tShould respond with a problem of status %d: %d %d %s", failed, testID, tt.want.Status, w.Code, v.StatusCode, w.Header().Get("Content-Type"))
+				}
+				t.Logf("\t%s\tTest %d:\tShould respond with a problem of status %d.", success, testID, tt.want.Status)
+
+				var got web.Problem
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to decode the problem: %v", failed, testID, err)
+				}
+
+				tt.want.Type = "about:blank"
+				tt.want.Instance = "/v1/gophers"
+				tt.want.TraceID = "0123456789abcdef"
+				if diff := cmp.Diff(got, tt.want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould describe the problem. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould describe the problem.", success, testID)
+			}
+		}
+	}
+}
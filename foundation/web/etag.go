@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPreconditionFailed is returned when a request is conditioned on a
+// version of a resource that can not be matched.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// VersionTag formats the version of a resource as a strong entity tag.
+func VersionTag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// SetVersion sets the ETag header of the response to the entity tag of the
+// version of the resource being sent.
+func SetVersion(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", VersionTag(version))
+}
+
+// IfMatch returns the version of a resource a request is conditioned on by
+// its If-Match header. ok is false when the request is unconditional or
+// accepts any version with "*". Only the entity tags built by VersionTag are
+// understood, so weak tags, lists and unknown tags can never match and are
+// reported as a 412 error.
+func IfMatch(r *http.Request) (version int, ok bool, err error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, false, nil
+	}
+
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false, NewRequestError(ErrPreconditionFailed, http.StatusPreconditionFailed)
+	}
+
+	version, err = strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return 0, false, NewRequestError(ErrPreconditionFailed, http.StatusPreconditionFailed)
+	}
+
+	return version, true, nil
+}
@@ -0,0 +1,52 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+)
+
+func TestIfMatch(t *testing.T) {
+	t.Log("Given the need to make requests conditional on a resource version.")
+	{
+		for testID, tt := range []struct {
+			header  string
+			version int
+			ok      bool
+			status  int
+		}{
+			{"", 0, false, 0},
+			{"*", 0, false, 0},
+			{web.VersionTag(7), 7, true, 0},
+			{`W/"7"`, 0, false, http.StatusPreconditionFailed},
+			{`"7", "8"`, 0, false, http.StatusPreconditionFailed},
+			{`7`, 0, false, http.StatusPreconditionFailed},
+		} {
+			t.Logf("\tTest %d:\tWhen handling the If-Match header %q.", testID, tt.header)
+			{
+				r := httptest.NewRequest(http.MethodPut, "/", nil)
+				if tt.header != "" {
+					r.Header.Set("If-Match", tt.header)
+				}
+
+				version, ok, err := web.IfMatch(r)
+				if tt.status != 0 {
+					var webErr *web.Error
+					if !errors.As(err, &webErr) || webErr.Status != tt.status {
+						t.Fatalf("\t%s\tTest %d:\tShould fail with status %d : %v", failed, testID, tt.status, err)
+					}
+					t.Logf("\t%s\tTest %d:\tShould fail with status %d.", success, testID, tt.status)
+					continue
+				}
+
+				if err != nil || version != tt.version || ok != tt.ok {
+					t.Fatalf("\t%s\tTest %d:\tShould get version %d (%v) : got %d (%v) %v", failed, testID, tt.version, tt.ok, version, ok, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get version %d (%v).", success, testID, tt.version, tt.ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/pt_BR"
+	ut "github.com/go-playground/universal-translator"
+	validator "gopkg.in/go-playground/validator.v9"
+	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
+	fr_translations "gopkg.in/go-playground/validator.v9/translations/fr"
+	pt_BR_translations "gopkg.in/go-playground/validator.v9/translations/pt_BR"
+)
+
+// These are the locales messages are available in, as BCP 47 language
+// tags. DefaultLocale is used when a client accepts none of them.
+const (
+	LocaleEN   = "en"
+	LocalePTBR = "pt-BR"
+	LocaleES   = "es"
+	LocaleFR   = "fr"
+	LocaleDE   = "de"
+
+	DefaultLocale = LocaleEN
+)
+
+// locales lists the supported locales in the order they are preferred when
+// a client only names a language, so "pt" selects "pt-BR".
+var locales = []string{LocaleEN, LocalePTBR, LocaleES, LocaleFR, LocaleDE}
+
+// translator holds the validation messages of every supported locale.
+var translator *ut.UniversalTranslator
+
+func init() {
+	enLocale := en.New()
+	translator = ut.New(enLocale, enLocale, pt_BR.New(), es.New(), fr.New(), de.New())
+
+	// The validator only ships translations for some of the locales, the
+	// others are maintained in this package.
+	register := map[string]func(*validator.Validate, ut.Translator) error{
+		LocaleEN:   en_translations.RegisterDefaultTranslations,
+		LocalePTBR: pt_BR_translations.RegisterDefaultTranslations,
+		LocaleFR:   fr_translations.RegisterDefaultTranslations,
+		LocaleES:   registerTranslations(esTranslations),
+		LocaleDE:   registerTranslations(deTranslations),
+	}
+	for locale, fn := range register {
+		trans := localeTranslator(locale)
+		if err := fn(validate, trans); err != nil {
+			panic(err)
+		}
+		if err := registerTranslations(requiredTranslations[locale])(validate, trans); err != nil {
+			panic(err)
+		}
+	}
+
+	for locale, msg := range map[string]string{
+		LocaleEN:   "field validation error",
+		LocalePTBR: "erro de validação dos campos",
+		LocaleES:   "error de validación de los campos",
+		LocaleFR:   "erreur de validation des champs",
+		LocaleDE:   "Fehler bei der Validierung der Felder",
+	} {
+		RegisterMessage(CodeValidation, locale, msg)
+	}
+}
+
+// Locale negotiates the locale of the messages sent back for a request
+// from its Accept-Language header.
+func Locale(r *http.Request) string {
+	return NegotiateLocale(r.Header.Get("Accept-Language"))
+}
+
+// NegotiateLocale picks the supported locale a client prefers from the
+// value of an Accept-Language header. Languages are tried in order of
+// quality. A language matches a locale exactly, or by its primary subtag,
+// so "pt-PT" falls back to "pt-BR" and "de-AT" to "de". When nothing
+// matches, DefaultLocale is returned.
+func NegotiateLocale(acceptLanguage string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		prefs = append(prefs, weighted{tag: tag, q: q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, pref := range prefs {
+		if pref.tag == "*" {
+			return DefaultLocale
+		}
+		for _, locale := range locales {
+			if strings.EqualFold(pref.tag, locale) {
+				return locale
+			}
+		}
+		for _, locale := range locales {
+			if strings.EqualFold(primaryTag(pref.tag), primaryTag(locale)) {
+				return locale
+			}
+		}
+	}
+
+	return DefaultLocale
+}
+
+// primaryTag returns the language of a language tag, such as "pt" for
+// "pt-BR".
+func primaryTag(tag string) string {
+	return strings.SplitN(strings.ReplaceAll(tag, "_", "-"), "-", 2)[0]
+}
+
+// localeTranslator returns the translator of the validation messages of a
+// locale, or of DefaultLocale for unsupported ones.
+func localeTranslator(locale string) ut.Translator {
+	trans, found := translator.GetTranslator(strings.ReplaceAll(locale, "-", "_"))
+	if !found {
+		trans, _ = translator.GetTranslator(strings.ReplaceAll(DefaultLocale, "-", "_"))
+	}
+	return trans
+}
+
+// translate returns the message of a validation error in a locale. Errors
+// without a message in that locale fall back to DefaultLocale.
+func translate(fe validator.FieldError, locale string) string {
+	if msg := fe.Translate(localeTranslator(locale)); msg != untranslated(fe) {
+		return msg
+	}
+	return fe.Translate(localeTranslator(DefaultLocale))
+}
+
+// untranslated returns the message the validator falls back to for errors
+// it has no translation for.
+func untranslated(fe validator.FieldError) string {
+	if err, ok := fe.(error); ok {
+		return err.Error()
+	}
+	return ""
+}
+
+// messages holds the messages registered with RegisterMessage by code and
+// locale.
+var messages struct {
+	sync.RWMutex
+	m map[string]map[string]string
+}
+
+// RegisterMessage sets the message of the errors with a code in a locale.
+// It replaces the message of the wrapped error in problem documents, so
+// clients can show it to their users. Messages are meant to be registered
+// once, when a program starts.
+func RegisterMessage(code string, locale string, message string) {
+	messages.Lock()
+	defer messages.Unlock()
+
+	if messages.m == nil {
+		messages.m = make(map[string]map[string]string)
+	}
+	if messages.m[code] == nil {
+		messages.m[code] = make(map[string]string)
+	}
+	messages.m[code][locale] = message
+}
+
+// Message returns the message registered for a code in a locale. Codes
+// without a message in that locale fall back to DefaultLocale, and ok is
+// false when there is none in either.
+func Message(code string, locale string) (message string, ok bool) {
+	messages.RLock()
+	defer messages.RUnlock()
+
+	if message, ok = messages.m[code][locale]; ok {
+		return message, true
+	}
+	message, ok = messages.m[code][DefaultLocale]
+	return message, ok
+}
@@ -0,0 +1,175 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type signup struct {
+	Name    string `json:"name" validate:"required"`
+	Email   string `json:"email" validate:"required,email"`
+	Color   string `json:"color" validate:"omitempty,hexcolor"`
+	Confirm string `json:"confirm" validate:"required_with=Color"`
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	t.Log("Given the need to negotiate a locale from the Accept-Language header.")
+	{
+		for testID, tt := range []struct {
+			header string
+			want   string
+		}{
+			{"", web.LocaleEN},
+			{"pt-BR", web.LocalePTBR},
+			{"PT-br", web.LocalePTBR},
+			{"pt", web.LocalePTBR},
+			{"pt-PT", web.LocalePTBR},
+			{"de-AT", web.LocaleDE},
+			{"es-MX,es;q=0.9", web.LocaleES},
+			{"fr-CA;q=0.5, de;q=0.8", web.LocaleDE},
+			{"ja, fr;q=0.3", web.LocaleFR},
+			{"ja, zh-CN", web.LocaleEN},
+			{"de;q=0, es;q=0.1", web.LocaleES},
+			{"*, de;q=0.5", web.LocaleEN},
+			{"de;q=x", web.LocaleEN},
+		} {
+			t.Logf("\tTest %d:\tWhen accepting %q.", testID, tt.header)
+			{
+				if got := web.NegotiateLocale(tt.header); got != tt.want {
+					t.Fatalf("\t%s\tTest %d:\tShould pick %s: %s", failed, testID, tt.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould pick %s.", success, testID, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecodeLocale(t *testing.T) {
+	t.Log("Given the need to report validation errors in the locale of the client.")
+	{
+		for testID, tt := range []struct {
+			header string
+			want   []web.FieldError
+		}{
+			{
+				"",
+				[]web.FieldError{
+					{Field: "name", Error: "name is a required field"},
+					{Field: "email", Error: "email must be a valid email address"},
+					{Field: "color", Error: "color must be a valid HEX color"},
+					{Field: "confirm", Error: "confirm is required when Color is present"},
+				},
+			},
+			{
+				"pt-BR",
+				[]web.FieldError{
+					{Field: "name", Error: "name é um campo requerido"},
+					{Field: "email", Error: "email deve ser um endereço de e-mail válido"},
+					{Field: "color", Error: "color deve ser uma cor HEX válida"},
+					{Field: "confirm", Error: "confirm é obrigatório quando Color está presente"},
+				},
+			},
+			{
+				"es-MX",
+				[]web.FieldError{
+					{Field: "name", Error: "name es un campo requerido"},
+					{Field: "email", Error: "email debe ser una dirección de correo electrónico válida"},
+					{Field: "color", Error: "color must be a valid HEX color"},
+					{Field: "confirm", Error: "confirm es obligatorio cuando Color está presente"},
+				},
+			},
+			{
+				"fr",
+				[]web.FieldError{
+					{Field: "name", Error: "name est un champ obligatoire"},
+					{Field: "email", Error: "email doit être une adresse email valide"},
+					{Field: "color", Error: "color doit être une couleur au format HEX valide"},
+					{Field: "confirm", Error: "confirm est obligatoire lorsque Color est présent"},
+				},
+			},
+			{
+				"de-DE, en;q=0.5",
+				[]web.FieldError{
+					{Field: "name", Error: "name ist ein Pflichtfeld"},
+					{Field: "email", Error: "email muss eine gültige E-Mail-Adresse sein"},
+					{Field: "color", Error: "color must be a valid HEX color"},
+					{Field: "confirm", Error: "confirm ist erforderlich, wenn Color angegeben ist"},
+				},
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen accepting %q.", testID, tt.header)
+			{
+				r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"gopher","color":"blue"}`))
+				r.Header.Set("Accept-Language", tt.header)
+
+				var s signup
+				err := web.Decode(r, &s)
+				webErr, ok := errors.Cause(err).(*web.Error)
+				if !ok || webErr.Status != http.StatusBadRequest {
+					t.Fatalf("\t%s\tTest %d:\tShould get a bad request error: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get a bad request error.", success, testID)
+
+				if diff := cmp.Diff(webErr.Fields, tt.want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould translate the messages, falling back to english. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould translate the messages, falling back to english.", success, testID)
+			}
+		}
+	}
+}
+
+var errNoGophers = errors.New("no gophers left")
+
+func TestRespondErrorLocale(t *testing.T) {
+	web.RegisterCode(errNoGophers, "no_gophers")
+	web.RegisterMessage("no_gophers", web.LocaleEN, "there are no gophers left")
+	web.RegisterMessage("no_gophers", web.LocaleFR, "il n'y a plus de gophers")
+
+	t.Log("Given the need to describe problems in the locale of the client.")
+	{
+		for testID, tt := range []struct {
+			locale string
+			err    error
+			want   string
+		}{
+			{web.LocaleFR, errNoGophers, "il n'y a plus de gophers"},
+			{web.LocaleDE, errNoGophers, "there are no gophers left"},
+			{web.LocalePTBR, errors.New("no such gopher"), "no such gopher"},
+			{web.LocaleES, &web.Error{Err: errors.New("bad gopher"), Status: http.StatusBadRequest, Code: web.CodeValidation}, "error de validación de los campos"},
+		} {
+			t.Logf("\tTest %d:\tWhen responding with %q in %s.", testID, tt.err, tt.locale)
+			{
+				v := web.Values{TraceID: "0123456789abcdef", Locale: tt.locale}
+				ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+				w := httptest.NewRecorder()
+
+				var webErr *web.Error
+				err := tt.err
+				if !errors.As(err, &webErr) {
+					err = web.NewRequestError(err, http.StatusConflict)
+				}
+				if err := web.RespondError(ctx, w, err); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to respond: %v", failed, testID, err)
+				}
+
+				var got web.Problem
+				if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to decode the problem: %v", failed, testID, err)
+				}
+				if got.Detail != tt.want || w.Header().Get("Content-Language") != tt.locale {
+					t.Fatalf("\t%s\tTest %d:\tShould describe the problem as %q: %q %s", failed, testID, tt.want, got.Detail, w.Header().Get("Content-Language"))
+				}
+				t.Logf("\t%s\tTest %d:\tShould describe the problem as %q.", success, testID, tt.want)
+			}
+		}
+	}
+}
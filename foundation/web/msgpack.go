@@ -0,0 +1,49 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encodeMsgpack encodes val as MessagePack. Fields are named by their json
+// tags, so clients see the same fields in both formats, and map keys are
+// sorted so the output is deterministic.
+func encodeMsgpack(val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+
+	if err := enc.Encode(val); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeMsgpack decodes a MessagePack document into val. Like JSON request
+// bodies, fields are matched by their json tags, unknown fields are
+// rejected and nothing may follow the document.
+func decodeMsgpack(r io.Reader, val interface{}) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	body := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(body)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+
+	if err := dec.Decode(val); err != nil {
+		return err
+	}
+	if body.Len() > 0 {
+		return errors.New("msgpack: unexpected data after the document")
+	}
+	return nil
+}
@@ -0,0 +1,417 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The media types of the patch documents understood by DecodePatch.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// ErrUnsupportedPatch is returned when the body of a PATCH request is not in
+// one of the supported patch media types.
+var ErrUnsupportedPatch = errors.New("patch must be " + MergePatchType + " or " + JSONPatchType)
+
+// errTestFailed is returned when a JSON Patch test operation does not hold.
+var errTestFailed = errors.New("test operation failed")
+
+// DecodePatch applies the patch in the request body to the JSON
+// representation of doc and decodes the result into val. The Content-Type of
+// the request tells a JSON Merge Patch (RFC 7386) from a JSON Patch (RFC
+// 6902). Like Decode, unknown fields are rejected and val is checked for
+// validation tags, so a patch can not leave behind a document a full update
+// would be refused for.
+//
+// A malformed patch is a 400 error. A JSON Patch that can not be applied is a
+// 422 error, unless one of its test operations failed, which is a 409.
+func DecodePatch(r *http.Request, doc interface{}, val interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchType && mediaType != JSONPatchType {
+		return NewRequestError(ErrUnsupportedPatch, http.StatusUnsupportedMediaType)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var target interface{}
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case MergePatchType:
+		var patch interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			return NewRequestError(err, http.StatusBadRequest)
+		}
+		target = mergePatch(target, patch)
+
+	case JSONPatchType:
+		var ops []patchOp
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&ops); err != nil {
+			return NewRequestError(err, http.StatusBadRequest)
+		}
+		if target, err = applyPatch(target, ops); err != nil {
+			return err
+		}
+	}
+
+	if raw, err = json.Marshal(target); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(val); err != nil {
+		return NewRequestError(err, http.StatusBadRequest)
+	}
+
+	return validateStruct(val, Locale(r))
+}
+
+// mergePatch applies a JSON Merge Patch to target. Members of the patch set
+// to null are removed from the target, objects are merged recursively and
+// any other value replaces the target.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatch(t[key], value)
+	}
+
+	return t
+}
+
+// patchOp is a single operation of a JSON Patch. Value is kept raw so an
+// explicit null can be told from a missing value.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyPatch applies the operations of a JSON Patch to doc in order. Either
+// all of them apply, or an error tells which one did not.
+func applyPatch(doc interface{}, ops []patchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = op.apply(doc)
+		if err != nil {
+			err = fmt.Errorf("operation %d: %w", i, err)
+
+			switch {
+			case errors.Is(err, errTestFailed):
+				return nil, NewRequestError(err, http.StatusConflict)
+			case errors.As(err, new(*patchSyntaxError)):
+				return nil, NewRequestError(err, http.StatusBadRequest)
+			default:
+				return nil, NewRequestError(err, http.StatusUnprocessableEntity)
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// patchSyntaxError reports an operation that is malformed no matter the
+// document it is applied to.
+type patchSyntaxError struct {
+	msg string
+}
+
+func (e *patchSyntaxError) Error() string {
+	return e.msg
+}
+
+// apply applies a single operation to doc.
+func (op patchOp) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "remove":
+		doc, _, err := pointerRemove(doc, path)
+		return doc, err
+
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, _, err = pointerRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, &patchSyntaxError{fmt.Sprintf("can not move %q into itself", op.From)}
+		}
+		doc, value, err := pointerRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, deepCopy(value))
+
+	case "test":
+		want, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return nil, fmt.Errorf("%w: %q", errTestFailed, op.Path)
+		}
+		return doc, nil
+	}
+
+	return nil, &patchSyntaxError{fmt.Sprintf("unknown op %q", op.Op)}
+}
+
+// value decodes the value of the operation.
+func (op patchOp) value() (interface{}, error) {
+	if len(op.Value) == 0 {
+		return nil, &patchSyntaxError{fmt.Sprintf("%s requires a value", op.Op)}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, &patchSyntaxError{err.Error()}
+	}
+	return v, nil
+}
+
+// pointerUnescaper unescapes the reference tokens of a JSON Pointer in a
+// single pass, so "~01" is read as "~1".
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped
+// reference tokens. The empty pointer references the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, &patchSyntaxError{fmt.Sprintf("invalid pointer %q", pointer)}
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses a reference token into an index of an array of length
+// n. When appending, "-" and n itself reference the end of the array.
+func arrayIndex(token string, n int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return n, nil
+	}
+
+	max := n - 1
+	if appending {
+		max = n
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, &patchSyntaxError{fmt.Sprintf("invalid array index %q", token)}
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+// pointerGet returns the value referenced by path in doc.
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// pointerAdd returns doc with value added at path. Members of objects are
+// set and elements of arrays are inserted, shifting the ones after them.
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, rest := path[0], path[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		child, err := pointerAdd(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		child, err := pointerAdd(node[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("member %q not found", token)
+}
+
+// pointerRemove returns doc without the value at path, along with the value
+// that was removed.
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, &patchSyntaxError{"can not remove the whole document"}
+	}
+
+	token, rest := path[0], path[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q not found", token)
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := pointerRemove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+		child, removed, err := pointerRemove(node[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		node[i] = child
+		return node, removed, nil
+	}
+
+	return nil, nil, fmt.Errorf("member %q not found", token)
+}
+
+// deepCopy returns a copy of a decoded JSON value that shares no memory
+// with it.
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			m[key] = deepCopy(child)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, child := range v {
+			s[i] = deepCopy(child)
+		}
+		return s
+	}
+	return value
+}
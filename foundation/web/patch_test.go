@@ -0,0 +1,119 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type account struct {
+	Name  string   `json:"name" validate:"required"`
+	Tags  []string `json:"tags"`
+	Notes *string  `json:"notes"`
+}
+
+func TestDecodePatch(t *testing.T) {
+	notes := "gopher"
+	doc := account{Name: "Ann", Tags: []string{"a", "b", "c"}, Notes: &notes}
+
+	t.Log("Given the need to apply patches to a document.")
+	{
+		for testID, tt := range []struct {
+			name        string
+			contentType string
+			patch       string
+			want        account
+		}{
+			{
+				"merge patch",
+				web.MergePatchType,
+				`{"name": "Bob", "notes": null}`,
+				account{Name: "Bob", Tags: []string{"a", "b", "c"}},
+			},
+			{
+				"merge patch replacing an array",
+				web.MergePatchType + "; charset=utf-8",
+				`{"tags": ["z"]}`,
+				account{Name: "Ann", Tags: []string{"z"}, Notes: &notes},
+			},
+			{
+				"json patch",
+				web.JSONPatchType,
+				`[
+					{"op": "test", "path": "/tags/1", "value": "b"},
+					{"op": "remove", "path": "/tags/1"},
+					{"op": "add", "path": "/tags/-", "value": "d"},
+					{"op": "add", "path": "/tags/0", "value": "z"},
+					{"op": "replace", "path": "/name", "value": "Bob"},
+					{"op": "copy", "from": "/name", "path": "/notes"}
+				]`,
+				account{Name: "Bob", Tags: []string{"z", "a", "c", "d"}, Notes: stringPointer("Bob")},
+			},
+			{
+				"json patch moving values",
+				web.JSONPatchType,
+				`[{"op": "move", "from": "/tags/0", "path": "/tags/2"}]`,
+				account{Name: "Ann", Tags: []string{"b", "c", "a"}, Notes: &notes},
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen applying a %s.", testID, tt.name)
+			{
+				r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.patch))
+				r.Header.Set("Content-Type", tt.contentType)
+
+				var got account
+				if err := web.DecodePatch(r, doc, &got); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to apply the patch: %v", failed, testID, err)
+				}
+				if diff := cmp.Diff(got, tt.want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould get the patched document. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the patched document.", success, testID)
+			}
+		}
+
+		for testID, tt := range []struct {
+			name        string
+			contentType string
+			patch       string
+			status      int
+		}{
+			{"an unsupported media type", "application/json", `{"name": "Bob"}`, http.StatusUnsupportedMediaType},
+			{"a patch failing validation", web.MergePatchType, `{"name": null}`, http.StatusBadRequest},
+			{"a patch adding unknown fields", web.MergePatchType, `{"admin": true}`, http.StatusBadRequest},
+			{"an unknown op", web.JSONPatchType, `[{"op": "swap", "path": "/name"}]`, http.StatusBadRequest},
+			{"an op without a value", web.JSONPatchType, `[{"op": "add", "path": "/name"}]`, http.StatusBadRequest},
+			{"a missing path", web.JSONPatchType, `[{"op": "remove", "path": "/tags/3"}]`, http.StatusUnprocessableEntity},
+			{"a failed test", web.JSONPatchType, `[{"op": "test", "path": "/name", "value": "Bob"}]`, http.StatusConflict},
+		} {
+			t.Logf("\tTest %d:\tWhen applying %s.", testID, tt.name)
+			{
+				r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.patch))
+				r.Header.Set("Content-Type", tt.contentType)
+
+				var got account
+				err := web.DecodePatch(r, doc, &got)
+
+				var webErr *web.Error
+				if !errors.As(err, &webErr) || webErr.Status != tt.status {
+					t.Fatalf("\t%s\tTest %d:\tShould fail with status %d : %v", failed, testID, tt.status, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould fail with status %d.", success, testID, tt.status)
+			}
+		}
+
+		if doc.Name != "Ann" || len(doc.Tags) != 3 || *doc.Notes != "gopher" {
+			t.Fatalf("\t%s\tShould leave the original document untouched : %+v", failed, doc)
+		}
+		t.Logf("\t%s\tShould leave the original document untouched.", success)
+	}
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
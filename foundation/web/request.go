@@ -1,34 +1,24 @@
 package web
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
-	en "github.com/go-playground/locales/en"
-	ut "github.com/go-playground/universal-translator"
 	validator "gopkg.in/go-playground/validator.v9"
-	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
 )
 
 // validate holds the settings for validating request struct values.
 var validate = validator.New()
 
-var translator *ut.UniversalTranslator
-
 func init() {
 
-	enLocale := en.New()
-
-	translator = ut.New(enLocale, enLocale)
-
-	// Register the english error messages for validation errors.
-	lang, _ := translator.GetTranslator("en")
-	en_translations.RegisterDefaultTranslations(validate, lang)
-
 	// Use JSON tag names for errors instead of Go struct names.
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
@@ -45,15 +35,26 @@ func Param(r *http.Request, key string) string {
 	return m[key]
 }
 
-// Decode gets the JSON value from the request body and decode it.
-// If the provided value is a struct then it is checked for validation tags.
+// Decode gets the value from the request body and decode it, with the
+// Decoder registered for its Content-Type. Bodies without a Content-Type are
+// decoded as JSON, and other media types are a 415 error. If the provided
+// value is a struct then it is checked for validation tags, with messages in
+// the locale negotiated for the request.
 func Decode(r *http.Request, val interface{}) error {
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(val); err != nil {
+	if err := decode(r.Header.Get("Content-Type"), r.Body, val); err != nil {
+		var webErr *Error
+		if errors.As(err, &webErr) {
+			return err
+		}
 		return NewRequestError(err, http.StatusBadRequest)
 	}
 
+	return validateStruct(val, Locale(r))
+}
+
+// validateStruct checks val for validation tags. The validation errors are
+// translated to the locale and returned as field errors of a trusted *Error.
+func validateStruct(val interface{}, locale string) error {
 	if err := validate.Struct(val); err != nil {
 
 		// Use a type assertion to get the real error value.
@@ -62,15 +63,11 @@ func Decode(r *http.Request, val interface{}) error {
 			return err // untrusted error
 		}
 
-		// lang controls the language of the error messages. Look at the
-		// Accept-Language header for supporting multiple languages.
-		lang, _ := translator.GetTranslator("en")
-
 		var fields []FieldError
 		for _, verror := range verrors {
 			field := FieldError{
 				Field: verror.Field(),
-				Error: verror.Translate(lang),
+				Error: translate(verror, locale),
 			}
 			fields = append(fields, field)
 		}
@@ -78,9 +75,119 @@ func Decode(r *http.Request, val interface{}) error {
 		return &Error{
 			Err:    errors.New("field validation error"),
 			Status: http.StatusBadRequest,
+			Code:   CodeValidation,
 			Fields: fields,
 		}
 	}
 
 	return nil
 }
+
+// DecodeQuery copies the URL query parameters into the struct pointed to by
+// val and checks it for validation tags. Parameters are matched to fields by
+// their json tag name, the same way Decode does for request bodies. Supported
+// field types are string, int, bool, time.Time (RFC 3339 or YYYY-MM-DD) and
+// pointers to them. Unknown parameters are reported as field errors.
+func DecodeQuery(r *http.Request, val interface{}) error {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("must provide a pointer to a struct")
+	}
+
+	fields := make(map[string]reflect.Value)
+	queryFields(rv.Elem(), fields)
+
+	var ferrs []FieldError
+	for key, values := range r.URL.Query() {
+		field, ok := fields[key]
+		if !ok {
+			ferrs = append(ferrs, FieldError{Field: key, Error: key + " is not a known field"})
+			continue
+		}
+		if err := setQueryField(field, values[len(values)-1]); err != nil {
+			ferrs = append(ferrs, FieldError{Field: key, Error: err.Error()})
+		}
+	}
+
+	if ferrs != nil {
+		sort.Slice(ferrs, func(i, j int) bool { return ferrs[i].Field < ferrs[j].Field })
+		return &Error{
+			Err:    errors.New("field validation error"),
+			Status: http.StatusBadRequest,
+			Code:   CodeValidation,
+			Fields: ferrs,
+		}
+	}
+
+	return validateStruct(val, Locale(r))
+}
+
+// queryFields indexes the settable fields of a struct by json tag name. The
+// fields of embedded structs are promoted like encoding/json does.
+func queryFields(v reflect.Value, fields map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name := strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			queryFields(v.Field(i), fields)
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = v.Field(i)
+	}
+}
+
+// setQueryField parses raw according to the type of field and stores it.
+func setQueryField(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setQueryField(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				field.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid date", raw)
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid number", raw)
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid boolean", raw)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package web_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/pkg/errors"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type filter struct {
+	Role  *string    `json:"role"`
+	Since *time.Time `json:"since"`
+}
+
+type query struct {
+	filter
+	Rows int `json:"rows" validate:"omitempty,min=1"`
+}
+
+func TestDecodeQuery(t *testing.T) {
+	t.Log("Given the need to decode query parameters into a struct.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling known parameters.", testID)
+		{
+			r := httptest.NewRequest("GET", "/?role=ADMIN&since=2021-10-28&rows=5", nil)
+
+			var q query
+			if err := web.DecodeQuery(r, &q); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the query: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the query.", success, testID)
+
+			if q.Role == nil || *q.Role != "ADMIN" || q.Rows != 5 {
+				t.Fatalf("\t%s\tTest %d:\tShould set the matching fields: %+v", failed, testID, q)
+			}
+			t.Logf("\t%s\tTest %d:\tShould set the matching fields.", success, testID)
+
+			if exp := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC); q.Since == nil || !q.Since.Equal(exp) {
+				t.Fatalf("\t%s\tTest %d:\tShould parse dates: %v", failed, testID, q.Since)
+			}
+			t.Logf("\t%s\tTest %d:\tShould parse dates.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen handling unknown or invalid parameters.", testID)
+		{
+			r := httptest.NewRequest("GET", "/?password=x&rows=many", nil)
+
+			var q query
+			err := web.DecodeQuery(r, &q)
+			webErr, ok := errors.Cause(err).(*web.Error)
+			if !ok || webErr.Status != 400 {
+				t.Fatalf("\t%s\tTest %d:\tShould get a bad request error: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a bad request error.", success, testID)
+
+			if len(webErr.Fields) != 2 || webErr.Fields[0].Field != "password" || webErr.Fields[1].Field != "rows" {
+				t.Fatalf("\t%s\tTest %d:\tShould get a field error per parameter: %+v", failed, testID, webErr.Fields)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a field error per parameter.", success, testID)
+
+			r = httptest.NewRequest("GET", "/?rows=-1", nil)
+			err = web.DecodeQuery(r, &q)
+			if webErr, ok := errors.Cause(err).(*web.Error); !ok || len(webErr.Fields) != 1 || webErr.Fields[0].Field != "rows" {
+				t.Fatalf("\t%s\tTest %d:\tShould apply validation tags: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould apply validation tags.", success, testID)
+		}
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Encode business layer outputs to send back to clients.
+// Encode business layer outputs to send back to clients, in the media type
+// they prefer among the ones with a registered Encoder. When none is
+// acceptable, or none can encode data, a 406 error is returned.
 func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
 
 	// Set the status code for the request logger middleware.
@@ -18,47 +20,83 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 	if !ok {
 		return NewShutdownError("web value missing from context")
 	}
-	v.StatusCode = statusCode
 
-	jsonData, err := json.Marshal(data)
+	// Responses without a body have nothing to negotiate.
+	if data == nil || statusCode == http.StatusNoContent {
+		v.StatusCode = statusCode
+		w.WriteHeader(statusCode)
+		return nil
+	}
+
+	mediaType, body, err := encode(v.Accept, data)
 	if err != nil {
 		return err
 	}
+	v.StatusCode = statusCode
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(body); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// RespondError sends an error response back to clients.
+// RespondError sends an error response back to clients as a problem
+// document. Once a response has started, as when a stream fails midway, its
+// status can no longer change and nothing is sent.
 func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+
+	if v.StatusCode != 0 {
+		return nil
+	}
 
 	// If the error was of the type *Error, the handler has
 	// a specific status code and error to return. That means,
 	// it is a trusted error, so we can return it back to clients.
+	// Otherwise the handler sent back a 500 status code, and the
+	// trace ID is all clients get for reporting it. Codes with a
+	// registered message are described in the locale of the request.
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusInternalServerError),
+		Status:   http.StatusInternalServerError,
+		Instance: v.Path,
+		Code:     StatusCode(http.StatusInternalServerError),
+		TraceID:  v.TraceID,
+	}
 	if webErr, ok := errors.Cause(err).(*Error); ok {
-		erRes := ErrorResponse{
-			Error:  webErr.Err.Error(),
-			Fields: webErr.Fields,
-		}
-		if err := Respond(ctx, w, erRes, webErr.Status); err != nil {
-			return err
-		}
+		p.Title = http.StatusText(webErr.Status)
+		p.Status = webErr.Status
+		p.Detail = webErr.Err.Error()
+		p.Code = webErr.ErrorCode()
+		p.Fields = webErr.Fields
+	}
+	if msg, ok := Message(p.Code, v.Locale); ok {
+		p.Detail = msg
+	}
 
-		return nil
+	v.StatusCode = p.Status
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
 	}
 
-	// If is not a trusted error, the handler sent back a 500 status code within
-	// a boilerplate message
-	erRes := ErrorResponse{
-		Error: http.StatusText(http.StatusInternalServerError),
+	w.Header().Set("Content-Type", ProblemType)
+	if v.Locale != "" {
+		w.Header().Set("Content-Language", v.Locale)
 	}
-	if err := Respond(ctx, w, erRes, http.StatusInternalServerError); err != nil {
+	w.WriteHeader(p.Status)
+
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
 
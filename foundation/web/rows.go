@@ -0,0 +1,349 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The media types of the streams of rows understood by RowDecoder and
+// RowEncoder.
+const (
+	CSVType    = "text/csv"
+	NDJSONType = "application/x-ndjson"
+)
+
+// listSeparator separates the elements of a list within a CSV cell.
+const listSeparator = ";"
+
+// ErrUnsupportedRows is returned when a stream of rows is not in one of the
+// supported media types.
+var ErrUnsupportedRows = errors.New("rows must be " + CSVType + " or " + NDJSONType)
+
+// RowError reports a single row of a stream that can not be decoded or
+// fails validation. Decoding can go on with the next row.
+type RowError struct {
+	Row    int
+	Fields []FieldError
+}
+
+// Error implements the error interface.
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, strings.Join(e.Reasons(), "; "))
+}
+
+// Reasons returns the messages of the field errors of the row.
+func (e *RowError) Reasons() []string {
+	reasons := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		reasons[i] = field.Error
+	}
+	return reasons
+}
+
+// RowDecoder decodes a stream of rows one at a time, so a large request
+// body is never held in memory. The first line of a CSV stream is a header
+// naming the column of each field by its json tag. Lists are separated by
+// semicolons within a cell, and empty cells leave fields at their zero
+// value. NDJSON streams hold one JSON object per line.
+type RowDecoder struct {
+	// Locale is the locale of the validation messages. DefaultLocale is
+	// used when it is empty.
+	Locale string
+
+	row    int
+	csv    *csv.Reader
+	header []string
+	lines  *bufio.Reader
+}
+
+// NewRowDecoder constructs a RowDecoder reading rows of the media type
+// given by contentType from r. Other media types are a 415 error.
+func NewRowDecoder(r io.Reader, contentType string) (*RowDecoder, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case CSVType:
+		cr := csv.NewReader(r)
+		cr.TrimLeadingSpace = true
+		return &RowDecoder{csv: cr}, nil
+
+	case NDJSONType:
+		return &RowDecoder{lines: bufio.NewReader(r)}, nil
+	}
+
+	return nil, NewRequestError(ErrUnsupportedRows, http.StatusUnsupportedMediaType)
+}
+
+// Decode decodes the next row into the struct pointed to by val and checks
+// it for validation tags. It returns io.EOF after the last row, and a
+// *RowError for a row that is rejected. A malformed stream is a 400 error.
+func (d *RowDecoder) Decode(val interface{}) error {
+	if d.csv != nil {
+		return d.decodeCSV(val)
+	}
+	return d.decodeNDJSON(val)
+}
+
+// decodeNDJSON decodes the next non blank line.
+func (d *RowDecoder) decodeNDJSON(val interface{}) error {
+	var line []byte
+	for len(bytes.TrimSpace(line)) == 0 {
+		var err error
+		line, err = d.lines.ReadBytes('\n')
+		if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+			return io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	d.row++
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(val); err != nil {
+		return &RowError{Row: d.row, Fields: []FieldError{{Error: err.Error()}}}
+	}
+
+	return d.validate(val)
+}
+
+// decodeCSV decodes the next record, reading the header first.
+func (d *RowDecoder) decodeCSV(val interface{}) error {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("must provide a pointer to a struct")
+	}
+
+	fields := make(map[string]reflect.Value)
+	queryFields(rv.Elem(), fields)
+
+	if d.header == nil {
+		header, err := d.csv.Read()
+		if err != nil {
+			return csvError(err)
+		}
+
+		var ferrs []FieldError
+		for _, name := range header {
+			if _, ok := fields[name]; !ok {
+				ferrs = append(ferrs, FieldError{Field: name, Error: name + " is not a known field"})
+			}
+		}
+		if ferrs != nil {
+			return &Error{
+				Err:    errors.New("invalid csv header"),
+				Status: http.StatusBadRequest,
+				Code:   CodeValidation,
+				Fields: ferrs,
+			}
+		}
+		d.header = header
+	}
+
+	record, err := d.csv.Read()
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return csvError(err)
+	}
+	d.row++
+
+	if err != nil {
+		return &RowError{Row: d.row, Fields: []FieldError{{Error: fmt.Sprintf("expected %d columns, got %d", len(d.header), len(record))}}}
+	}
+
+	var ferrs []FieldError
+	for i, raw := range record {
+		if raw == "" {
+			continue
+		}
+		if err := setRowField(fields[d.header[i]], raw); err != nil {
+			ferrs = append(ferrs, FieldError{Field: d.header[i], Error: d.header[i] + ": " + err.Error()})
+		}
+	}
+	if ferrs != nil {
+		return &RowError{Row: d.row, Fields: ferrs}
+	}
+
+	return d.validate(val)
+}
+
+// validate checks a decoded row for validation tags.
+func (d *RowDecoder) validate(val interface{}) error {
+	err := validateStruct(val, d.Locale)
+
+	var webErr *Error
+	if errors.As(err, &webErr) {
+		return &RowError{Row: d.row, Fields: webErr.Fields}
+	}
+	return err
+}
+
+// csvError turns the errors of a malformed CSV stream into 400 errors.
+func csvError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return NewRequestError(err, http.StatusBadRequest)
+	}
+	return err
+}
+
+// setRowField parses a CSV cell according to the type of field and stores
+// it. Lists of strings are split on semicolons.
+func setRowField(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+		list := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, s := range strings.Split(raw, listSeparator) {
+			if s = strings.TrimSpace(s); s != "" {
+				list = reflect.Append(list, reflect.ValueOf(s).Convert(field.Type().Elem()))
+			}
+		}
+		field.Set(list)
+		return nil
+	}
+
+	return setQueryField(field, raw)
+}
+
+// RowEncoder encodes values as a stream of rows, in the same format
+// RowDecoder reads. For CSV, the header is written along with the first
+// row. Flush must be called once all rows are encoded.
+type RowEncoder struct {
+	csv         *csv.Writer
+	json        *json.Encoder
+	wroteHeader bool
+}
+
+// NewRowEncoder constructs a RowEncoder writing rows of the media type given
+// by contentType to w. Other media types are a 406 error.
+func NewRowEncoder(w io.Writer, contentType string) (*RowEncoder, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case CSVType:
+		return &RowEncoder{csv: csv.NewWriter(w)}, nil
+	case NDJSONType:
+		return &RowEncoder{json: json.NewEncoder(w)}, nil
+	}
+
+	return nil, NewRequestError(ErrUnsupportedRows, http.StatusNotAcceptable)
+}
+
+// Encode writes val as the next row. For CSV, val must be a struct and
+// fields hidden from its JSON representation are left out.
+func (e *RowEncoder) Encode(val interface{}) error {
+	if e.json != nil {
+		return e.json.Encode(val)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(val))
+	if rv.Kind() != reflect.Struct {
+		return errors.New("must provide a struct")
+	}
+
+	names, values := rowColumns(rv)
+	if !e.wroteHeader {
+		if err := e.csv.Write(names); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatRowField(value)
+	}
+	return e.csv.Write(record)
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (e *RowEncoder) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// rowColumns lists the fields of a struct in declaration order along with
+// their json tag names. The fields of embedded structs are promoted.
+func rowColumns(v reflect.Value) ([]string, []reflect.Value) {
+	var names []string
+	var values []reflect.Value
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name := strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			n, vs := rowColumns(v.Field(i))
+			names, values = append(names, n...), append(values, vs...)
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		names, values = append(names, name), append(values, v.Field(i))
+	}
+
+	return names, values
+}
+
+// formatRowField formats a field as a CSV cell. Nil pointers are empty
+// cells and lists of strings are joined with semicolons. Other composite
+// values are written as JSON.
+func formatRowField(field reflect.Value) string {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
+	}
+
+	if t, ok := field.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return field.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool())
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String {
+			list := make([]string, field.Len())
+			for i := range list {
+				list[i] = field.Index(i).String()
+			}
+			return strings.Join(list, listSeparator)
+		}
+		fallthrough
+	case reflect.Map, reflect.Struct, reflect.Array, reflect.Interface:
+		if data, err := json.Marshal(field.Interface()); err == nil {
+			return string(data)
+		}
+	}
+
+	return fmt.Sprint(field.Interface())
+}
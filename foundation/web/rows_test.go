@@ -0,0 +1,160 @@
+package web_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type member struct {
+	Name   string     `json:"name" validate:"required"`
+	Roles  []string   `json:"roles"`
+	Age    int        `json:"age"`
+	Joined *time.Time `json:"joined"`
+	Secret string     `json:"-"`
+}
+
+func TestRowDecoder(t *testing.T) {
+	t.Log("Given the need to decode a stream of rows.")
+	{
+		for testID, tt := range []struct {
+			contentType string
+			body        string
+		}{
+			{
+				web.CSVType,
+				"name,roles,age\nAnn,ADMIN;USER,30\n,USER,1\nBob,,x\nCid\nDan,,40\n",
+			},
+			{
+				web.NDJSONType + "; charset=utf-8",
+				`{"name": "Ann", "roles": ["ADMIN", "USER"], "age": 30}` + "\n" +
+					`{"roles": ["USER"], "age": 1}` + "\n" +
+					`{"name": "Bob", "age": "x"}` + "\n\n" +
+					`{"name": "Cid", "admin": true}` + "\n" +
+					`{"name": "Dan", "age": 40}`,
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen decoding %s.", testID, tt.contentType)
+			{
+				d, err := web.NewRowDecoder(strings.NewReader(tt.body), tt.contentType)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to construct the decoder: %v", failed, testID, err)
+				}
+
+				var got []member
+				var rejected []int
+				for {
+					var m member
+					err := d.Decode(&m)
+					if err == io.EOF {
+						break
+					}
+
+					var rowErr *web.RowError
+					switch {
+					case errors.As(err, &rowErr):
+						rejected = append(rejected, rowErr.Row)
+						continue
+					case err != nil:
+						t.Fatalf("\t%s\tTest %d:\tShould be able to decode the rows: %v", failed, testID, err)
+					}
+					got = append(got, m)
+				}
+
+				want := []member{
+					{Name: "Ann", Roles: []string{"ADMIN", "USER"}, Age: 30},
+					{Name: "Dan", Age: 40},
+				}
+				if diff := cmp.Diff(got, want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould decode the valid rows. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould decode the valid rows.", success, testID)
+
+				if diff := cmp.Diff(rejected, []int{2, 3, 4}); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the invalid rows. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould reject the invalid rows.", success, testID)
+			}
+		}
+
+		testID := 2
+		t.Logf("\tTest %d:\tWhen decoding a malformed stream.", testID)
+		{
+			if _, err := web.NewRowDecoder(strings.NewReader(""), "application/json"); !hasStatus(err, http.StatusUnsupportedMediaType) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse unsupported media types: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse unsupported media types.", success, testID)
+
+			d, err := web.NewRowDecoder(strings.NewReader("name,password\nAnn,x\n"), web.CSVType)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the decoder: %v", failed, testID, err)
+			}
+			var m member
+			if err := d.Decode(&m); !hasStatus(err, http.StatusBadRequest) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse unknown columns: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse unknown columns.", success, testID)
+		}
+	}
+}
+
+func TestRowEncoder(t *testing.T) {
+	joined := time.Date(2021, time.October, 28, 0, 0, 0, 0, time.UTC)
+	members := []member{
+		{Name: "Ann", Roles: []string{"ADMIN", "USER"}, Age: 30, Joined: &joined, Secret: "x"},
+		{Name: "Bob, Jr"},
+	}
+
+	t.Log("Given the need to encode a stream of rows.")
+	{
+		for testID, tt := range []struct {
+			contentType string
+			want        string
+		}{
+			{
+				web.CSVType,
+				"name,roles,age,joined\nAnn,ADMIN;USER,30,2021-10-28T00:00:00Z\n\"Bob, Jr\",,0,\n",
+			},
+			{
+				web.NDJSONType,
+				`{"name":"Ann","roles":["ADMIN","USER"],"age":30,"joined":"2021-10-28T00:00:00Z"}` + "\n" +
+					`{"name":"Bob, Jr","roles":null,"age":0,"joined":null}` + "\n",
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen encoding %s.", testID, tt.contentType)
+			{
+				var buf bytes.Buffer
+				e, err := web.NewRowEncoder(&buf, tt.contentType)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to construct the encoder: %v", failed, testID, err)
+				}
+				for _, m := range members {
+					if err := e.Encode(m); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to encode a row: %v", failed, testID, err)
+					}
+				}
+				if err := e.Flush(); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to flush the rows: %v", failed, testID, err)
+				}
+
+				if diff := cmp.Diff(buf.String(), tt.want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould encode every row. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould encode every row.", success, testID)
+			}
+		}
+	}
+}
+
+// hasStatus reports whether err is a *web.Error with the given status.
+func hasStatus(err error, status int) bool {
+	var webErr *web.Error
+	return errors.As(err, &webErr) && webErr.Status == status
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EventStreamType is the media type of Server-Sent Events.
+const EventStreamType = "text/event-stream"
+
+// EventStream writes Server-Sent Events to a client. Every event is flushed
+// as soon as it is written. It is meant for handlers registered with
+// HandleStream, which are not subject to the write timeout of the server.
+type EventStream struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// NewEventStream starts an event stream response. The status is sent right
+// away, so the client knows it is subscribed before any event comes, and
+// can no longer change: failures past this point are for the logs only.
+func NewEventStream(ctx context.Context, w http.ResponseWriter) (*EventStream, error) {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return nil, NewShutdownError("web value missing from context")
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("response writer does not support flushing")
+	}
+
+	// Proxies must not cache nor buffer the stream.
+	w.Header().Set("Content-Type", EventStreamType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	v.StatusCode = http.StatusOK
+	flusher.Flush()
+
+	return &EventStream{w: w, flusher: flusher}, nil
+}
+
+// Send writes an event of the given type with data marshaled as JSON. The
+// id is what the client sends back in the Last-Event-ID header when it
+// reconnects. Empty ids and types are left out.
+func (s *EventStream) Send(id string, event string, data interface{}) error {
+	if strings.ContainsAny(id, "\r\n") || strings.ContainsAny(event, "\r\n") {
+		return errors.New("event id and type must be single lines")
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", payload)
+
+	return s.write(b.String())
+}
+
+// Heartbeat writes a comment, which clients ignore, so proxies and clients
+// do not take an idle stream for a dead connection.
+func (s *EventStream) Heartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+// write writes a message and flushes it.
+func (s *EventStream) write(msg string) error {
+	if _, err := io.WriteString(s.w, msg); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,137 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+)
+
+func TestEventStream(t *testing.T) {
+	t.Log("Given the need to push events to clients for as long as they stay connected.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sending events.", testID)
+		{
+			v := web.Values{}
+			ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+			w := flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+			s, err := web.NewEventStream(ctx, &w)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to start a stream: %v", failed, testID, err)
+			}
+			if w.Header().Get("Content-Type") != web.EventStreamType || v.StatusCode != http.StatusOK || w.flushes != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send the status right away: %s %d %d", failed, testID, w.Header().Get("Content-Type"), v.StatusCode, w.flushes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the status right away.", success, testID)
+
+			if err := s.Send("1-1", "gopher.created", gopher{Name: "Bob"}); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to send an event: %v", failed, testID, err)
+			}
+			if err := s.Heartbeat(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to send a heartbeat: %v", failed, testID, err)
+			}
+			if err := s.Send("", "", nil); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to send an event: %v", failed, testID, err)
+			}
+
+			want := "id: 1-1\nevent: gopher.created\n" + `data: {"name":"Bob","age":0,"born":"0001-01-01T00:00:00Z"}` + "\n\n" +
+				": heartbeat\n\n" +
+				"data: null\n\n"
+			if w.Body.String() != want || w.flushes != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould flush every message: %q %d", failed, testID, w.Body.String(), w.flushes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould flush every message.", success, testID)
+
+			if err := s.Send("1\n2", "", nil); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse ids spanning lines.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse ids spanning lines.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a stream outlives the write timeout of the server.", testID)
+		{
+			app := web.NewApp(make(chan os.Signal, 1))
+			app.HandleStream(http.MethodGet, "/events", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				s, err := web.NewEventStream(ctx, w)
+				if err != nil {
+					return err
+				}
+
+				ticker := time.NewTicker(50 * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-ticker.C:
+						if err := s.Heartbeat(); err != nil {
+							return err
+						}
+					}
+				}
+			})
+
+			srv := httptest.NewUnstartedServer(app)
+			srv.Config.ConnContext = web.ConnContext
+			srv.Config.ReadTimeout = 100 * time.Millisecond
+			srv.Config.WriteTimeout = 100 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/events")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe: %v", failed, testID, err)
+			}
+			defer resp.Body.Close()
+
+			lines := make(chan string)
+			go func() {
+				defer close(lines)
+				scanner := bufio.NewScanner(resp.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+
+			heartbeats := 0
+			timeout := time.After(time.Second)
+			for heartbeats < 8 {
+				select {
+				case line, ok := <-lines:
+					if !ok {
+						t.Fatalf("\t%s\tTest %d:\tShould keep streaming past the timeouts: %d heartbeats", failed, testID, heartbeats)
+					}
+					if strings.HasPrefix(line, ":") {
+						heartbeats++
+					}
+				case <-timeout:
+					t.Fatalf("\t%s\tTest %d:\tShould keep streaming past the timeouts: %d heartbeats", failed, testID, heartbeats)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep streaming past the timeouts.", success, testID)
+
+			app.StopStreams()
+			app.StopStreams()
+
+			timeout = time.After(time.Second)
+			for ended := false; !ended; {
+				select {
+				case _, ok := <-lines:
+					ended = !ok
+				case <-timeout:
+					t.Fatalf("\t%s\tTest %d:\tShould end the streams when asked to.", failed, testID)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould end the streams when asked to.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// streamFlushRows is the number of values written between two flushes of a
+// stream whose values keep coming.
+const streamFlushRows = 100
+
+// errNotReady is returned when a channel has no value ready and the stream
+// asked not to wait for one.
+var errNotReady = errors.New("no value ready")
+
+// Iterator yields the values of a stream one at a time. Next returns io.EOF
+// once there are no more values.
+type Iterator interface {
+	Next() (interface{}, error)
+}
+
+// IteratorFunc adapts a function to an Iterator.
+type IteratorFunc func() (interface{}, error)
+
+// Next calls f.
+func (f IteratorFunc) Next() (interface{}, error) {
+	return f()
+}
+
+// RespondStream sends back the values yielded by src, an Iterator or a
+// channel, as they come, so a large collection is never held in memory. The
+// values are written as NDJSON, as a JSON array or as CSV rows, in the
+// media type the client prefers, and flushed every hundred values or
+// whenever a channel has none ready. Producers sending to a channel must
+// close it once done.
+//
+// The first value is read before anything is written, so its failure is
+// returned like any other handler error. Past that point the status can no
+// longer change: a failure ends the stream early and is returned for the
+// logs only. When the client goes away, the stream stops and nil is
+// returned.
+func RespondStream(ctx context.Context, w http.ResponseWriter, src interface{}, statusCode int) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+
+	next, err := streamSource(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	mediaType, ok := Negotiate(v.Accept, NDJSONType, JSONType, CSVType)
+	if !ok {
+		return NewRequestError(ErrNotAcceptable, http.StatusNotAcceptable)
+	}
+
+	val, err := next(true)
+	if err != nil && err != io.EOF {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	var enc streamEncoder = &arrayEncoder{w: w}
+	if mediaType != JSONType {
+		if enc, err = NewRowEncoder(w, mediaType); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(statusCode)
+	v.StatusCode = statusCode
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() error {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	for n := 1; err != io.EOF; n++ {
+		if err := enc.Encode(val); err != nil {
+			return err
+		}
+		if n%streamFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		val, err = next(false)
+		if err == errNotReady {
+			if err := flush(); err != nil {
+				return err
+			}
+			val, err = next(true)
+		}
+		if err != nil && err != io.EOF {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+
+	if a, ok := enc.(*arrayEncoder); ok {
+		if err := a.Close(); err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// streamSource returns a function yielding the values of an Iterator or a
+// channel. Unless asked to wait, it returns errNotReady when a channel has
+// no value ready. Receiving from a channel stops when ctx is done.
+func streamSource(ctx context.Context, src interface{}) (func(wait bool) (interface{}, error), error) {
+	if it, ok := src.(Iterator); ok {
+		return func(bool) (interface{}, error) {
+			return it.Next()
+		}, nil
+	}
+
+	ch := reflect.ValueOf(src)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, errors.New("must provide an iterator or a channel")
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectDefault},
+	}
+
+	return func(wait bool) (interface{}, error) {
+		cs := cases
+		if wait {
+			cs = cases[:2]
+		}
+
+		chosen, val, ok := reflect.Select(cs)
+		switch {
+		case chosen == 0:
+			return nil, ctx.Err()
+		case chosen == 2:
+			return nil, errNotReady
+		case !ok:
+			return nil, io.EOF
+		}
+		return val.Interface(), nil
+	}, nil
+}
+
+// streamEncoder writes the values of a stream.
+type streamEncoder interface {
+	Encode(val interface{}) error
+	Flush() error
+}
+
+// arrayEncoder writes the values of a stream as the elements of a JSON
+// array, one per line. Close must be called to end the array.
+type arrayEncoder struct {
+	w io.Writer
+	n int
+}
+
+// Encode writes val as the next element of the array.
+func (e *arrayEncoder) Encode(val interface{}) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.n == 0 {
+		sep = "[\n"
+	}
+	e.n++
+
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// Flush has nothing to do, as elements are written right away.
+func (e *arrayEncoder) Flush() error {
+	return nil
+}
+
+// Close ends the array.
+func (e *arrayEncoder) Close() error {
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
@@ -0,0 +1,202 @@
+package web_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danielmbirochi/go-sample-service/foundation/web"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// sliceIter yields the gophers of a slice, then err.
+func sliceIter(gophers []gopher, err error) web.Iterator {
+	return web.IteratorFunc(func() (interface{}, error) {
+		if len(gophers) == 0 {
+			return nil, err
+		}
+		g := gophers[0]
+		gophers = gophers[1:]
+		return g, nil
+	})
+}
+
+// flushRecorder counts the flushes of a response.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes++
+	r.ResponseRecorder.Flush()
+}
+
+func TestRespondStream(t *testing.T) {
+	bob := gopher{Name: "Bob", Age: 12}
+	alice := gopher{Name: "Alice", Tags: []string{"go"}}
+
+	t.Log("Given the need to stream collections to clients.")
+	{
+		for testID, tt := range []struct {
+			name     string
+			accept   string
+			src      func() interface{}
+			wantType string
+			want     string
+		}{
+			{
+				"NDJSON by default", "", func() interface{} { return sliceIter([]gopher{bob, alice}, io.EOF) }, web.NDJSONType,
+				`{"name":"Bob","age":12,"born":"0001-01-01T00:00:00Z"}` + "\n" +
+					`{"name":"Alice","tags":["go"],"age":0,"born":"0001-01-01T00:00:00Z"}` + "\n",
+			},
+			{
+				"a JSON array", "application/json", func() interface{} { return sliceIter([]gopher{bob, alice}, io.EOF) }, web.JSONType,
+				"[\n" + `{"name":"Bob","age":12,"born":"0001-01-01T00:00:00Z"}` + ",\n" +
+					`{"name":"Alice","tags":["go"],"age":0,"born":"0001-01-01T00:00:00Z"}` + "\n]\n",
+			},
+			{
+				"an empty JSON array", "application/json", func() interface{} { return sliceIter(nil, io.EOF) }, web.JSONType,
+				"[]\n",
+			},
+			{
+				"CSV rows", "text/csv", func() interface{} { return sliceIter([]gopher{bob, alice}, io.EOF) }, web.CSVType,
+				"name,tags,age,born\nBob,,12,0001-01-01T00:00:00Z\nAlice,go,0,0001-01-01T00:00:00Z\n",
+			},
+			{
+				"a channel", "application/x-ndjson", func() interface{} {
+					ch := make(chan gopher)
+					go func() {
+						ch <- bob
+						ch <- alice
+						close(ch)
+					}()
+					return ch
+				}, web.NDJSONType,
+				`{"name":"Bob","age":12,"born":"0001-01-01T00:00:00Z"}` + "\n" +
+					`{"name":"Alice","tags":["go"],"age":0,"born":"0001-01-01T00:00:00Z"}` + "\n",
+			},
+		} {
+			t.Logf("\tTest %d:\tWhen streaming %s.", testID, tt.name)
+			{
+				v := web.Values{Accept: tt.accept}
+				ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+				w := flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+				if err := web.RespondStream(ctx, &w, tt.src(), http.StatusOK); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to stream: %v", failed, testID, err)
+				}
+
+				if w.Code != http.StatusOK || v.StatusCode != http.StatusOK || w.Header().Get("Content-Type") != tt.wantType {
+					t.Fatalf("\t%s\tTest %d:\tShould respond with %q: %d %d %s", failed, testID, tt.wantType, w.Code, v.StatusCode, w.Header().Get("Content-Type"))
+				}
+				t.Logf("\t%s\tTest %d:\tShould respond with %q.", success, testID, tt.wantType)
+
+				if diff := cmp.Diff(w.Body.String(), tt.want); diff != "" {
+					t.Fatalf("\t%s\tTest %d:\tShould write every value. Diff:\n%s", failed, testID, diff)
+				}
+				t.Logf("\t%s\tTest %d:\tShould write every value.", success, testID)
+
+				if w.flushes == 0 {
+					t.Fatalf("\t%s\tTest %d:\tShould flush the stream.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould flush the stream.", success, testID)
+			}
+		}
+
+		testID := 5
+		t.Logf("\tTest %d:\tWhen streaming many values.", testID)
+		{
+			gophers := make([]gopher, 250)
+			v := web.Values{}
+			ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+			w := flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+			if err := web.RespondStream(ctx, &w, sliceIter(gophers, io.EOF), http.StatusOK); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to stream: %v", failed, testID, err)
+			}
+			if w.flushes != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould flush every hundred values and at the end: %d", failed, testID, w.flushes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould flush every hundred values and at the end.", success, testID)
+		}
+
+		testID = 6
+		t.Logf("\tTest %d:\tWhen the stream fails.", testID)
+		{
+			errBroken := errors.New("broken pipe")
+
+			v := web.Values{}
+			ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+			w := httptest.NewRecorder()
+
+			err := web.RespondStream(ctx, w, sliceIter(nil, web.NewRequestError(errBroken, http.StatusConflict)), http.StatusOK)
+			if webErr, ok := errors.Cause(err).(*web.Error); !ok || webErr.Status != http.StatusConflict || v.StatusCode != 0 || w.Body.Len() != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould return a failure of the first value before responding: %v %d", failed, testID, err, v.StatusCode)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return a failure of the first value before responding.", success, testID)
+
+			v = web.Values{}
+			ctx = context.WithValue(context.Background(), web.KeyValues, &v)
+			w = httptest.NewRecorder()
+
+			err = web.RespondStream(ctx, w, sliceIter([]gopher{bob}, errBroken), http.StatusOK)
+			if err != errBroken || v.StatusCode != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould return a failure midway once the status is sent: %v %d", failed, testID, err, v.StatusCode)
+			}
+			t.Logf("\t%s\tTest %d:\tShould return a failure midway once the status is sent.", success, testID)
+
+			if err := web.RespondError(ctx, w, err); err != nil || w.Body.String() != `{"name":"Bob","age":12,"born":"0001-01-01T00:00:00Z"}`+"\n" {
+				t.Fatalf("\t%s\tTest %d:\tShould not report the failure in the stream: %v %q", failed, testID, err, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould not report the failure in the stream.", success, testID)
+		}
+
+		testID = 7
+		t.Logf("\tTest %d:\tWhen the client goes away.", testID)
+		{
+			v := web.Values{}
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), web.KeyValues, &v))
+			w := httptest.NewRecorder()
+
+			ch := make(chan gopher, 1)
+			ch <- bob
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+			}()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- web.RespondStream(ctx, w, ch, http.StatusOK)
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould stop streaming quietly: %v", failed, testID, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould stop streaming.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould stop streaming quietly.", success, testID)
+		}
+
+		testID = 8
+		t.Logf("\tTest %d:\tWhen nothing acceptable can be streamed.", testID)
+		{
+			v := web.Values{Accept: "text/html"}
+			ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+
+			err := web.RespondStream(ctx, httptest.NewRecorder(), sliceIter(nil, io.EOF), http.StatusOK)
+			if webErr, ok := errors.Cause(err).(*web.Error); !ok || webErr.Status != http.StatusNotAcceptable {
+				t.Fatalf("\t%s\tTest %d:\tShould get a not acceptable error: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a not acceptable error.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"reflect"
+
+	ut "github.com/go-playground/universal-translator"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// translation is the message of a validation tag, where {0} is the field
+// and {1} the parameter of the tag. Tags comparing a field to a quantity
+// have a message per kind of field, with a singular form for a quantity of
+// one. Tags with a single message only set text.
+type translation struct {
+	tag      string
+	text     string
+	textOne  string
+	number   string
+	items    string
+	itemsOne string
+}
+
+// registerTranslations returns a function registering translations with a
+// validator, shaped like the ones shipped with it.
+func registerTranslations(translations []translation) func(*validator.Validate, ut.Translator) error {
+	return func(v *validator.Validate, trans ut.Translator) error {
+		for _, t := range translations {
+			t := t
+
+			keys := map[string]string{t.tag: t.text}
+			if t.number != "" {
+				keys = map[string]string{
+					t.tag + "-string":     t.text,
+					t.tag + "-string-one": t.textOne,
+					t.tag + "-number":     t.number,
+					t.tag + "-items":      t.items,
+					t.tag + "-items-one":  t.itemsOne,
+				}
+			}
+
+			register := func(trans ut.Translator) error {
+				for key, text := range keys {
+					if text == "" {
+						continue
+					}
+					if err := trans.Add(key, text, true); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			if err := v.RegisterTranslation(t.tag, trans, register, t.translate); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// translate formats the message of a validation error, choosing the message
+// for the kind of the field when there is one.
+func (t translation) translate(trans ut.Translator, fe validator.FieldError) string {
+	key := t.tag
+	if t.number != "" {
+		switch fe.Kind() {
+		case reflect.String:
+			key += "-string"
+		case reflect.Slice, reflect.Map, reflect.Array:
+			key += "-items"
+		default:
+			key += "-number"
+		}
+		if fe.Param() == "1" && key != t.tag+"-number" {
+			if _, err := trans.T(key+"-one", fe.Field()); err == nil {
+				key += "-one"
+			}
+		}
+	}
+
+	msg, err := trans.T(key, fe.Field(), fe.Param())
+	if err != nil {
+		return untranslated(fe)
+	}
+	return msg
+}
+
+// esTranslations are the Spanish messages of the validation tags in use,
+// which the validator does not ship.
+var esTranslations = []translation{
+	{tag: "required", text: "{0} es un campo requerido"},
+	{
+		tag:      "len",
+		text:     "{0} debe tener {1} caracteres",
+		textOne:  "{0} debe tener 1 carácter",
+		number:   "{0} debe ser igual a {1}",
+		items:    "{0} debe contener {1} elementos",
+		itemsOne: "{0} debe contener 1 elemento",
+	},
+	{
+		tag:      "min",
+		text:     "{0} debe tener al menos {1} caracteres",
+		textOne:  "{0} debe tener al menos 1 carácter",
+		number:   "{0} debe ser {1} o más",
+		items:    "{0} debe contener al menos {1} elementos",
+		itemsOne: "{0} debe contener al menos 1 elemento",
+	},
+	{
+		tag:      "max",
+		text:     "{0} debe tener como máximo {1} caracteres",
+		textOne:  "{0} debe tener como máximo 1 carácter",
+		number:   "{0} debe ser {1} o menos",
+		items:    "{0} debe contener como máximo {1} elementos",
+		itemsOne: "{0} debe contener como máximo 1 elemento",
+	},
+	{
+		tag:      "lt",
+		text:     "{0} debe tener menos de {1} caracteres",
+		textOne:  "{0} debe tener menos de 1 carácter",
+		number:   "{0} debe ser menor que {1}",
+		items:    "{0} debe contener menos de {1} elementos",
+		itemsOne: "{0} debe contener menos de 1 elemento",
+	},
+	{
+		tag:      "lte",
+		text:     "{0} debe tener como máximo {1} caracteres",
+		textOne:  "{0} debe tener como máximo 1 carácter",
+		number:   "{0} debe ser {1} o menos",
+		items:    "{0} debe contener como máximo {1} elementos",
+		itemsOne: "{0} debe contener como máximo 1 elemento",
+	},
+	{
+		tag:      "gt",
+		text:     "{0} debe tener más de {1} caracteres",
+		textOne:  "{0} debe tener más de 1 carácter",
+		number:   "{0} debe ser mayor que {1}",
+		items:    "{0} debe contener más de {1} elementos",
+		itemsOne: "{0} debe contener más de 1 elemento",
+	},
+	{
+		tag:      "gte",
+		text:     "{0} debe tener al menos {1} caracteres",
+		textOne:  "{0} debe tener al menos 1 carácter",
+		number:   "{0} debe ser {1} o más",
+		items:    "{0} debe contener al menos {1} elementos",
+		itemsOne: "{0} debe contener al menos 1 elemento",
+	},
+	{tag: "eqfield", text: "{0} debe ser igual a {1}"},
+	{tag: "email", text: "{0} debe ser una dirección de correo electrónico válida"},
+	{tag: "uuid", text: "{0} debe ser un UUID válido"},
+	{tag: "oneof", text: "{0} debe ser uno de [{1}]"},
+}
+
+// deTranslations are the German messages of the validation tags in use,
+// which the validator does not ship.
+var deTranslations = []translation{
+	{tag: "required", text: "{0} ist ein Pflichtfeld"},
+	{
+		tag:      "len",
+		text:     "{0} muss {1} Zeichen lang sein",
+		number:   "{0} muss gleich {1} sein",
+		items:    "{0} muss {1} Elemente enthalten",
+		itemsOne: "{0} muss 1 Element enthalten",
+	},
+	{
+		tag:      "min",
+		text:     "{0} muss mindestens {1} Zeichen lang sein",
+		number:   "{0} muss {1} oder größer sein",
+		items:    "{0} muss mindestens {1} Elemente enthalten",
+		itemsOne: "{0} muss mindestens 1 Element enthalten",
+	},
+	{
+		tag:      "max",
+		text:     "{0} darf höchstens {1} Zeichen lang sein",
+		number:   "{0} muss {1} oder kleiner sein",
+		items:    "{0} darf höchstens {1} Elemente enthalten",
+		itemsOne: "{0} darf höchstens 1 Element enthalten",
+	},
+	{
+		tag:      "lt",
+		text:     "{0} muss weniger als {1} Zeichen lang sein",
+		number:   "{0} muss kleiner als {1} sein",
+		items:    "{0} muss weniger als {1} Elemente enthalten",
+		itemsOne: "{0} muss weniger als 1 Element enthalten",
+	},
+	{
+		tag:      "lte",
+		text:     "{0} darf höchstens {1} Zeichen lang sein",
+		number:   "{0} muss {1} oder kleiner sein",
+		items:    "{0} darf höchstens {1} Elemente enthalten",
+		itemsOne: "{0} darf höchstens 1 Element enthalten",
+	},
+	{
+		tag:      "gt",
+		text:     "{0} muss mehr als {1} Zeichen lang sein",
+		number:   "{0} muss größer als {1} sein",
+		items:    "{0} muss mehr als {1} Elemente enthalten",
+		itemsOne: "{0} muss mehr als 1 Element enthalten",
+	},
+	{
+		tag:      "gte",
+		text:     "{0} muss mindestens {1} Zeichen lang sein",
+		number:   "{0} muss {1} oder größer sein",
+		items:    "{0} muss mindestens {1} Elemente enthalten",
+		itemsOne: "{0} muss mindestens 1 Element enthalten",
+	},
+	{tag: "eqfield", text: "{0} muss gleich {1} sein"},
+	{tag: "email", text: "{0} muss eine gültige E-Mail-Adresse sein"},
+	{tag: "uuid", text: "{0} muss eine gültige UUID sein"},
+	{tag: "oneof", text: "{0} muss einer von [{1}] sein"},
+}
+
+// requiredTranslations are the messages of the conditional required tags,
+// which the validator ships in no locale.
+var requiredTranslations = map[string][]translation{
+	LocaleEN: {
+		{tag: "required_with", text: "{0} is required when {1} is present"},
+		{tag: "required_without", text: "{0} is required when {1} is missing"},
+	},
+	LocalePTBR: {
+		{tag: "required_with", text: "{0} é obrigatório quando {1} está presente"},
+		{tag: "required_without", text: "{0} é obrigatório quando {1} está ausente"},
+	},
+	LocaleES: {
+		{tag: "required_with", text: "{0} es obligatorio cuando {1} está presente"},
+		{tag: "required_without", text: "{0} es obligatorio cuando falta {1}"},
+	},
+	LocaleFR: {
+		{tag: "required_with", text: "{0} est obligatoire lorsque {1} est présent"},
+		{tag: "required_without", text: "{0} est obligatoire lorsque {1} est absent"},
+	},
+	LocaleDE: {
+		{tag: "required_with", text: "{0} ist erforderlich, wenn {1} angegeben ist"},
+		{tag: "required_without", text: "{0} ist erforderlich, wenn {1} fehlt"},
+	},
+}
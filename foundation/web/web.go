@@ -3,8 +3,10 @@ package web
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,9 +21,19 @@ type ctxKey int
 // KeyValues is how request metadata (type Values) are stored/retrieved.
 const KeyValues ctxKey = 1
 
+// keyConn is how the connection of a request is stored/retrieved.
+const keyConn ctxKey = 2
+
 // Values represent metadata attached to requests for debugging purposes.
+// Path is the path of the request URL, used as the instance of the
+// problems reported for it. Locale is the locale negotiated from the
+// Accept-Language header for the messages sent back, and Accept the media
+// types the client accepts for responses.
 type Values struct {
 	TraceID    string
+	Path       string
+	Locale     string
+	Accept     string
 	Now        time.Time
 	StatusCode int
 }
@@ -32,10 +44,12 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 // Type App is the entrypoint into the web application, it configures context for http handlers and hooks up
 // os.Signal from application inner layers. This can be extended for further behaviors.
 type App struct {
-	mux      *httptreemux.ContextMux
-	otmux    http.Handler
-	shutdown chan os.Signal
-	mw       []Middleware
+	mux         *httptreemux.ContextMux
+	otmux       http.Handler
+	shutdown    chan os.Signal
+	mw          []Middleware
+	streams     chan struct{}
+	stopStreams sync.Once
 }
 
 // Factory method for creating concrete App that handles http routes handling
@@ -48,12 +62,44 @@ func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
 		otmux:    otelhttp.NewHandler(mux, "request"),
 		shutdown: shutdown,
 		mw:       mw,
+		streams:  make(chan struct{}),
 	}
 }
 
 // Handle encapsulates concrete http.HandleFunc calls
 // to abstract requests observability and error handling
 func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) {
+	a.handle(method, path, false, handler, mw)
+}
+
+// HandleStream is like Handle for handlers streaming to clients for as long
+// as they stay connected, such as event streams. Their connection is not
+// subject to the read and write timeouts of the server, provided its
+// ConnContext is set to ConnContext, and their context is canceled by
+// StopStreams.
+func (a *App) HandleStream(method string, path string, handler Handler, mw ...Middleware) {
+	a.handle(method, path, true, handler, mw)
+}
+
+// StopStreams cancels the context of the handlers registered with
+// HandleStream, so a graceful shutdown does not wait for their clients to go
+// away. It is meant to be registered with http.Server.RegisterOnShutdown.
+func (a *App) StopStreams() {
+	a.stopStreams.Do(func() {
+		close(a.streams)
+	})
+}
+
+// ConnContext stores the connection of a request in its context, which lets
+// HandleStream lift the timeouts of the server for a stream. It is meant to
+// be set as the ConnContext of the http.Server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, keyConn, c)
+}
+
+// handle registers a handler, lifting the timeouts of the connection for
+// streams.
+func (a *App) handle(method string, path string, stream bool, handler Handler, mw []Middleware) {
 
 	// handler is the most inner handler to be executed
 	handler = wrapMiddleware(mw, handler)
@@ -70,10 +116,35 @@ func (a *App) Handle(method string, path string, handler Handler, mw ...Middlewa
 		// Injects the spanned traceID & timestamp into request context to be processed
 		v := Values{
 			TraceID: span.SpanContext().TraceID().String(),
+			Path:    r.URL.Path,
+			Locale:  Locale(r),
+			Accept:  r.Header.Get("Accept"),
 			Now:     time.Now(),
 		}
 		ctx = context.WithValue(ctx, KeyValues, &v)
 
+		// The deadlines set by the server only apply to the current request,
+		// so clearing them leaves the next requests of the connection alone.
+		// The read deadline goes too, since the server cancels the context
+		// of the request when it expires.
+		if stream {
+			if conn, ok := r.Context().Value(keyConn).(net.Conn); ok {
+				conn.SetDeadline(time.Time{})
+			}
+
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+
+			go func() {
+				select {
+				case <-a.streams:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+		}
+
 		// Starts the execution of the Middleware chain
 		if err := handler(ctx, w, r); err != nil {
 			a.SignalShutdown()